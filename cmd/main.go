@@ -15,12 +15,29 @@ func main() {
 		app.Version = "v0.1.1-alpha"
 		app.Desc = "iOSBox"
 		app.ExitOnEnd = false
+		app.GOptsBinder = handlers.BindGlobalOptions
 		app.On(gcli.EvtAppInit, func(data ...interface{}) (stop bool) {
 			// fmt.Println("init app")
 			return false
 		})
 	})
 
+	app.On(gcli.EvtGOptionsParsed, func(data ...interface{}) (stop bool) {
+		// gcli ignores stop here, so a bad --lang exits before any command
+		// runs.
+		if err := handlers.CheckLanguage(); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		if err := handlers.StartTrace(); err != nil {
+			fmt.Println(err)
+		}
+		if err := handlers.StartRecord(); err != nil {
+			fmt.Println(err)
+		}
+		return false
+	})
+
 	app.On(gcli.EvtAppRunError, func(data ...interface{}) (stop bool) {
 		fmt.Println(data[1])
 		return false
@@ -39,6 +56,9 @@ func main() {
 	})
 
 	app.Add(
+		handlers.DevicesCommand,
+		handlers.PairCommand,
+		handlers.PairRecordCommand,
 		handlers.DeviceInfoCommand,
 		handlers.AppListCommand,
 		handlers.AppInstallCommand,
@@ -57,7 +77,16 @@ func main() {
 		handlers.DebugCommand,
 		handlers.LLDBCommand,
 		handlers.FridaCommand,
+		handlers.WatchCommand,
+		handlers.FSCommand,
+		handlers.CrashCommand,
 	)
 
-	os.Exit(app.Run(nil))
+	code := app.Run(nil)
+	handlers.CloseDevice()
+	if err := handlers.StopRecord(); err != nil {
+		fmt.Println(err)
+	}
+	handlers.StopTrace()
+	os.Exit(code)
 }
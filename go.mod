@@ -3,7 +3,7 @@ module github.com/gofmt/iOSBox
 go 1.16
 
 require (
-	github.com/gdamore/tcell/v2 v2.3.11 // indirect
+	github.com/gdamore/tcell/v2 v2.3.11
 	github.com/gookit/color v1.4.2
 	github.com/gookit/gcli/v3 v3.0.0
 	github.com/jroimartin/gocui v0.4.0 // indirect
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"text/tabwriter"
 
+	"github.com/gofmt/iOSBox/pkg/i18n"
 	"github.com/gofmt/iOSBox/pkg/idevice"
 
 	"github.com/gookit/gcli/v3"
@@ -15,19 +17,24 @@ import (
 
 var AppListCommand = &gcli.Command{
 	Name:    "apps",
-	Desc:    "显示当前设备应用列表",
+	Desc:    i18n.T("cmd.apps.desc"),
 	Aliases: []string{"as"},
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "应用名称")
+		c.AddArg("arg0", i18n.T("cmd.apps.arg.name"))
 	},
 	Func: func(c *gcli.Command, args []string) error {
-		device, err := idevice.GetDevice()
+		format, err := outputFormat()
 		if err != nil {
-			return xerrors.Errorf("连接iOS设备错误: %w", err)
+			return err
 		}
-		conn, err := idevice.NewAppManagerService(device)
+
+		device, err := openDevice()
 		if err != nil {
-			return xerrors.Errorf("连接服务错误：%w", err)
+			return wrapErr(err, "err.connect_device")
+		}
+		conn, err := device.Apps()
+		if err != nil {
+			return wrapErr(err, "err.connect_service")
 		}
 		defer conn.Close()
 
@@ -36,6 +43,17 @@ var AppListCommand = &gcli.Command{
 			return err
 		}
 
+		if format != outputTable {
+			apps := make([]idevice.AppInfo, 0, len(appList))
+			for _, info := range appList {
+				if len(args) == 1 && args[0] != info.CFBundleDisplayName {
+					continue
+				}
+				apps = append(apps, info)
+			}
+			return writeOutput(format, apps)
+		}
+
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 0, 0, 1, ' ', 0)
 		_, _ = fmt.Fprintln(w, "--------------------------------------------------------------")
@@ -58,26 +76,33 @@ var AppListCommand = &gcli.Command{
 	},
 }
 
+var installOpts = struct {
+	resume bool
+	verify bool
+}{}
+
 var AppInstallCommand = &gcli.Command{
 	Name:     "install",
 	Aliases:  []string{"ins", "i"},
-	Desc:     "安装应用",
+	Desc:     i18n.T("cmd.install.desc"),
 	Examples: "{$binName} {$cmd} $HOME/Downloads/example.ipa",
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "IPA文件路径", true)
+		c.BoolOpt(&installOpts.resume, "resume", "", false, i18n.T("cmd.install.opt.resume"))
+		c.BoolOpt(&installOpts.verify, "verify", "", false, i18n.T("cmd.install.opt.verify"))
+		c.AddArg("arg0", i18n.T("cmd.install.arg.ipa"), true)
 	},
 	Func: func(c *gcli.Command, args []string) error {
 		if len(args) == 0 {
-			return xerrors.Errorf("未传入IPA文件路径")
+			return xerrors.New(i18n.T("err.missing_ipa"))
 		}
 
-		device, err := idevice.GetDevice()
+		device, err := openDevice()
 		if err != nil {
-			return xerrors.Errorf("连接iOS设备错误: %w", err)
+			return wrapErr(err, "err.connect_device")
 		}
-		fservice, err := idevice.NewFileManagerService(device)
+		fservice, err := device.AFC()
 		if err != nil {
-			return xerrors.Errorf("连接服务错误：%w", err)
+			return wrapErr(err, "err.connect_service")
 		}
 		defer fservice.Close()
 
@@ -91,25 +116,40 @@ var AppInstallCommand = &gcli.Command{
 			_ = file.Close()
 		}(lfile)
 
-		fi, _ := lfile.Stat()
-		total := fi.Size() / int64(idevice.DefaultChunkSize)
+		fi, err := lfile.Stat()
+		if err != nil {
+			return err
+		}
 
 		cs := progress.BarStyles[3]
 		p := progress.CustomBar(40, cs)
-		p.MaxSteps = uint(total)
+		p.MaxSteps = uint(fi.Size())
 		// p.Format = progress.FullBarFormat
-		p.AddMessage("正在上传...", "")
+		p.AddMessage(i18n.T("msg.uploading"), "")
 		p.Start()
-		if err := fservice.FileUpload(lfile, remotePath, func(count int) {
-			p.Advance()
-		}); err != nil {
-			return xerrors.Errorf("IPA文件上传错误：%w", err)
+		var opts []idevice.UploadOption
+		if installOpts.resume {
+			opts = append(opts, idevice.WithResume())
+		}
+		if err := fservice.FileUpload(lfile, remotePath, func(written int64) {
+			p.AdvanceTo(uint(written))
+		}, opts...); err != nil {
+			return wrapErr(err, "err.upload_ipa")
 		}
 		p.Finish()
 
-		aservice, err := idevice.NewAppManagerService(device)
+		if installOpts.verify {
+			if _, err := lfile.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := fservice.Verify(lfile, remotePath); err != nil {
+				return wrapErr(err, "err.verify_ipa")
+			}
+		}
+
+		aservice, err := device.Apps()
 		if err != nil {
-			return xerrors.Errorf("连接服务错误: %w", err)
+			return wrapErr(err, "err.connect_service")
 		}
 		defer aservice.Close()
 
@@ -120,7 +160,7 @@ var AppInstallCommand = &gcli.Command{
 		if err := aservice.Install(remotePath, func(ret idevice.AppInstallResponse) {
 			p.AdvanceTo(uint(ret.PercentComplete))
 		}); err != nil {
-			return xerrors.Errorf("安装应用错误：%w", err)
+			return wrapErr(err, "err.install")
 		}
 		p.Finish()
 
@@ -130,33 +170,33 @@ var AppInstallCommand = &gcli.Command{
 
 var AppUninstallCommand = &gcli.Command{
 	Name:     "uninstall",
-	Desc:     "卸载应用",
+	Desc:     i18n.T("cmd.uninstall.desc"),
 	Aliases:  []string{"uns", "u"},
 	Examples: "{$binName} {$cmd} com.xxx.xxx",
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "应用BundleID", true)
+		c.AddArg("arg0", i18n.T("cmd.uninstall.arg.id"), true)
 	},
 	Func: func(c *gcli.Command, args []string) error {
 		if len(args) == 0 {
-			return xerrors.Errorf("未传入应用BundleID")
+			return xerrors.New(i18n.T("err.missing_bundle_id"))
 		}
 
-		device, err := idevice.GetDevice()
+		device, err := openDevice()
 		if err != nil {
-			return xerrors.Errorf("连接iOS设备错误: %w", err)
+			return wrapErr(err, "err.connect_device")
 		}
 
-		aservice, err := idevice.NewAppManagerService(device)
+		aservice, err := device.Apps()
 		if err != nil {
-			return xerrors.Errorf("连接服务错误: %w", err)
+			return wrapErr(err, "err.connect_service")
 		}
 		defer aservice.Close()
 
 		if err := aservice.Uninstall(args[0]); err != nil {
-			return xerrors.Errorf("卸载应用[%s]错误：%w", args[0], err)
+			return wrapErr(err, "err.uninstall", args[0])
 		}
 
-		c.Println("应用卸载完成")
+		c.Println(i18n.T("msg.uninstalled"))
 
 		return nil
 	},
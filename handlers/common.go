@@ -1,35 +1,239 @@
 package handlers
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/trace"
+
 	"github.com/gookit/gcli/v3"
+	"golang.org/x/xerrors"
 )
 
+var globalOpts = struct {
+	udid   string
+	conn   string
+	output string
+	lang   string
+	trace  string
+	record string
+}{}
+
+// BindGlobalOptions registers the options shared by every command.
+func BindGlobalOptions(gf *gcli.Flags) {
+	gf.StrOpt(&idevice.SocketAddress, "socket", "", idevice.SocketAddress,
+		i18n.T("opt.socket"))
+	gf.StrOpt(&globalOpts.udid, "udid", "u", os.Getenv("IOSBOX_UDID"),
+		i18n.T("opt.udid"))
+	gf.StrOpt(&globalOpts.conn, "conn", "", "any",
+		i18n.T("opt.conn"))
+	gf.StrOpt(&globalOpts.output, "output", "o", outputTable,
+		i18n.T("opt.output"))
+	// The language was already picked from os.Args when the i18n package was
+	// initialised, so that command descriptions use it; CheckLanguage
+	// rejects values it ignored.
+	gf.StrOpt(&globalOpts.lang, "lang", "", i18n.Language(), i18n.T("opt.lang"))
+	gf.StrOpt(&globalOpts.trace, "trace", "", os.Getenv("IOSBOX_TRACE"),
+		i18n.T("opt.trace"))
+	gf.StrOpt(&globalOpts.record, "record", "", "",
+		i18n.T("opt.record"))
+}
+
+// CheckLanguage selects the language given with --lang, and fails for one
+// without a catalog. It is called once the global options are parsed.
+func CheckLanguage() error {
+	if err := i18n.SetLanguage(globalOpts.lang); err != nil {
+		return xerrors.New(i18n.T("err.unsupported_lang", globalOpts.lang, strings.Join(i18n.Languages(), ", ")))
+	}
+
+	return nil
+}
+
+// StartTrace starts the protocol trace selected with --trace. It is called
+// once the global options are parsed.
+func StartTrace() error {
+	if globalOpts.trace == "" {
+		return nil
+	}
+	if err := trace.Open(globalOpts.trace); err != nil {
+		return wrapErr(err, "err.open_trace")
+	}
+
+	return nil
+}
+
+// StopTrace closes the protocol trace.
+func StopTrace() {
+	_ = trace.Close()
+}
+
+// stopRecord ends the session recording started by StartRecord.
+var stopRecord func() error
+
+// StartRecord records every usbmuxd connection of the run to the file
+// selected with --record, for replaying in pkg/idevice tests. It is called
+// once the global options are parsed.
+func StartRecord() error {
+	if globalOpts.record == "" {
+		return nil
+	}
+	f, err := os.Create(globalOpts.record)
+	if err != nil {
+		return wrapErr(err, "err.open_record")
+	}
+
+	stop := idevice.Record(f)
+	stopRecord = func() error {
+		err := stop()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// StopRecord finishes the session recording. It is called once the device
+// is closed, so the recording ends with every connection closed.
+func StopRecord() error {
+	if stopRecord == nil {
+		return nil
+	}
+	err := stopRecord()
+	stopRecord = nil
+	if err != nil {
+		return wrapErr(err, "err.write_record")
+	}
+
+	return nil
+}
+
+// deviceFilter builds the device filter from --udid and --conn.
+func deviceFilter() (idevice.DeviceFilter, error) {
+	connType, err := idevice.ParseConnectionType(globalOpts.conn)
+	if err != nil {
+		return idevice.DeviceFilter{}, err
+	}
+
+	return idevice.DeviceFilter{UDID: globalOpts.udid, ConnectionType: connType}, nil
+}
+
+// getDevice returns the device selected with --udid and --conn.
+func getDevice() (*idevice.DeviceEntry, error) {
+	filter, err := deviceFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := idevice.FindDevice(filter)
+	if xerrors.Is(err, idevice.ErrDeviceNotFound) {
+		return nil, deviceNotFound(filter, err)
+	}
+
+	return entry, err
+}
+
+// deviceNotFound gives the ErrDeviceNotFound of err a message in the
+// selected language.
+func deviceNotFound(filter idevice.DeviceFilter, err error) error {
+	msg := i18n.T("device.none")
+	switch {
+	case filter.UDID != "":
+		msg = i18n.T("device.not_found", filter.UDID)
+	case filter.ConnectionType != "":
+		msg = i18n.T("device.no_conn_type", filter.ConnectionType)
+	}
+
+	return &localizedError{msg: msg, err: err}
+}
+
+// localizedError replaces the message of a library error, which is English,
+// and still matches it with errors.Is.
+type localizedError struct {
+	msg string
+	err error
+}
+
+func (e *localizedError) Error() string {
+	return e.msg
+}
+
+func (e *localizedError) Unwrap() error {
+	return e.err
+}
+
+// openedDevice is the device shared by the commands of one run.
+var openedDevice *idevice.Device
+
+// openDevice returns the device selected with --udid and --conn. It is
+// opened once per run, so commands that run other commands (respring runs
+// kill) share its lockdown session and SSH connection.
+func openDevice() (*idevice.Device, error) {
+	if openedDevice == nil {
+		entry, err := getDevice()
+		if err != nil {
+			return nil, err
+		}
+		openedDevice = idevice.NewDevice(entry)
+	}
+
+	return openedDevice, nil
+}
+
+// CloseDevice releases the connections held by the device opened during the
+// run. It is called once the command has finished.
+func CloseDevice() {
+	if openedDevice != nil {
+		openedDevice.Close()
+		openedDevice = nil
+	}
+}
+
+// interruptContext returns a context that is cancelled by Ctrl-C, which
+// interrupts any device I/O waiting on it.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
+
+// wrapErr prefixes err with the message id in the selected language.
+func wrapErr(err error, id string, args ...interface{}) error {
+	return xerrors.Errorf("%s: %w", i18n.T(id, args...), err)
+}
+
 func init() {
-	gcli.AppHelpTemplate = `{{.Desc}} (版本: <info>{{.Version}}</>)
------------------------------------------------------{{range $cmdName, $c := .Cs}}
-  <info>{{$c.Name | paddingName }}</> {{$c.HelpDesc}}{{if $c.Aliases}} (别名: <green>{{ join $c.Aliases ","}}</>){{end}}{{end}}
-  <info>{{ paddingName "help" }}</> 显示帮助信息
+	gcli.AppHelpTemplate = `{{.Desc}} (` + i18n.T("help.version") + `: <info>{{.Version}}</>)
+-----------------------------------------------------
+<comment>` + i18n.T("help.global_options") + `</>
+{{.GOpts}}
+<comment>` + i18n.T("help.commands") + `</>{{range $cmdName, $c := .Cs}}
+  <info>{{$c.Name | paddingName }}</> {{$c.HelpDesc}}{{if $c.Aliases}} (` + i18n.T("help.aliases") + `: <green>{{ join $c.Aliases ","}}</>){{end}}{{end}}
+  <info>{{ paddingName "help" }}</> ` + i18n.T("help.help") + `
 
-使用 "<cyan>{$binName} COMMAND -h</>" 查看命令的其他帮助信息
+` + i18n.T("help.more") + `
 `
 
 	gcli.CmdHelpTemplate = `{{.Desc}}
 	
-<comment>用法:</>
+<comment>` + i18n.T("help.usage") + `</>
   {$binName} [global options] {{if .Cmd.NotStandalone}}<cyan>{{.Cmd.Path}}</> {{end}}[--options ...] [arguments ...]{{ if .Subs }}
   {$binName} [global options] {{if .Cmd.NotStandalone}}<cyan>{{.Cmd.Path}}</> {{end}}<cyan>SUBCOMMAND</> [--options ...] [arguments ...]{{end}}
 {{if .Options}}
-<comment>Options:</>
+<comment>` + i18n.T("help.options") + `</>
 {{.Options}}{{end}}{{if .Cmd.Args}}
-<comment>Arguments:</>{{range $a := .Cmd.Args}}
+<comment>` + i18n.T("help.arguments") + `</>{{range $a := .Cmd.Args}}
   <info>{{$a.HelpName | printf "%-12s"}}</>{{$a.Desc | ucFirst}}{{if $a.Required}}<red>*</>{{end}}{{end}}
 {{end}}{{ if .Subs }}
-<comment>命令列表:</>{{range $n,$c := .Subs}}
-  <info>{{$c.Name | paddingName }}</> {{$c.HelpDesc}}{{if $c.Aliases}} (alias: <green>{{ join $c.Aliases ","}}</>){{end}}{{end}}
+<comment>` + i18n.T("help.commands") + `</>{{range $n,$c := .Subs}}
+  <info>{{$c.Name | paddingName }}</> {{$c.HelpDesc}}{{if $c.Aliases}} (` + i18n.T("help.aliases") + `: <green>{{ join $c.Aliases ","}}</>){{end}}{{end}}
 {{end}}{{if .Cmd.Examples}}
-<comment>Examples:</>
+<comment>` + i18n.T("help.examples") + `</>
 {{.Cmd.Examples}}{{end}}{{if .Cmd.Help}}
-<comment>Help:</>
+<comment>` + i18n.T("help.help_section") + `</>
 {{.Cmd.Help}}{{end}}`
 }
 
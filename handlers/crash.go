@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/crashlog"
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+
+	"github.com/gookit/gcli/v3"
+	"golang.org/x/xerrors"
+)
+
+var crashOpts = struct {
+	process  string
+	since    string
+	delete   bool
+	interval int
+	dsyms    gcli.Strings
+}{}
+
+var CrashCommand = &gcli.Command{
+	Name: "crash",
+	Desc: i18n.T("cmd.crash.desc"),
+	Examples: `{$binName} {$cmd} ls -p MyApp -s 24h
+{$binName} {$cmd} pull -p MyApp --delete ./crashes
+{$binName} {$cmd} clear
+{$binName} {$cmd} watch -p MyApp ./crashes
+{$binName} {$cmd} symbolicate --dsym MyApp.app.dSYM ./crashes/MyApp-2023-05-01-101010.ips`,
+	Subs: []*gcli.Command{
+		CrashListCommand,
+		CrashPullCommand,
+		CrashClearCommand,
+		CrashWatchCommand,
+		CrashSymbolicateCommand,
+	},
+}
+
+// crashFilterOpts registers the options that select reports.
+func crashFilterOpts(c *gcli.Command) {
+	c.StrOpt(&crashOpts.process, "process", "p", "", i18n.T("cmd.crash.opt.process"))
+	c.StrOpt(&crashOpts.since, "since", "s", "", i18n.T("cmd.crash.opt.since"))
+}
+
+var CrashListCommand = &gcli.Command{
+	Name: "ls",
+	Desc: i18n.T("cmd.crash.ls.desc"),
+	Config: func(c *gcli.Command) {
+		crashFilterOpts(c)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		crashes, err := crashService()
+		if err != nil {
+			return err
+		}
+		defer crashes.Close()
+
+		reports, err := listCrashReports(crashes)
+		if err != nil {
+			return err
+		}
+
+		if format != outputTable {
+			if reports == nil {
+				reports = []idevice.CrashReport{}
+			}
+			return writeOutput(format, reports)
+		}
+
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, r := range reports {
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", r.Time.Format("2006-01-02 15:04:05"), r.Size, r.Process, r.Name)
+		}
+
+		return w.Flush()
+	},
+}
+
+var CrashPullCommand = &gcli.Command{
+	Name: "pull",
+	Desc: i18n.T("cmd.crash.pull.desc"),
+	Config: func(c *gcli.Command) {
+		crashFilterOpts(c)
+		c.BoolOpt(&crashOpts.delete, "delete", "d", false, i18n.T("cmd.crash.opt.delete"))
+		c.AddArg("dir", i18n.T("cmd.crash.arg.dir"))
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		crashes, err := crashService()
+		if err != nil {
+			return err
+		}
+		defer crashes.Close()
+
+		reports, err := listCrashReports(crashes)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range reports {
+			dst, err := pullCrashReport(crashes, r, dir, crashOpts.delete)
+			if err != nil {
+				return err
+			}
+			fmt.Println(dst)
+		}
+		fmt.Println(i18n.T("msg.crash_pulled", len(reports), dir))
+
+		return nil
+	},
+}
+
+var CrashClearCommand = &gcli.Command{
+	Name: "clear",
+	Desc: i18n.T("cmd.crash.clear.desc"),
+	Config: func(c *gcli.Command) {
+		crashFilterOpts(c)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		crashes, err := crashService()
+		if err != nil {
+			return err
+		}
+		defer crashes.Close()
+
+		reports, err := listCrashReports(crashes)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range reports {
+			if err := crashes.Remove(r.Name); err != nil {
+				return wrapErr(err, "err.crash_remove", r.Name)
+			}
+		}
+		fmt.Println(i18n.T("msg.crash_cleared", len(reports)))
+
+		return nil
+	},
+}
+
+var CrashWatchCommand = &gcli.Command{
+	Name: "watch",
+	Desc: i18n.T("cmd.crash.watch.desc"),
+	Config: func(c *gcli.Command) {
+		crashFilterOpts(c)
+		c.BoolOpt(&crashOpts.delete, "delete", "d", false, i18n.T("cmd.crash.opt.delete"))
+		c.IntOpt(&crashOpts.interval, "interval", "i", 5, i18n.T("cmd.crash.watch.opt.interval"))
+		c.AddArg("dir", i18n.T("cmd.crash.watch.arg.dir"))
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := streamFormat()
+		if err != nil {
+			return err
+		}
+		if crashOpts.interval <= 0 {
+			return xerrors.New(i18n.T("err.crash_interval"))
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		device, err := openDevice()
+		if err != nil {
+			return wrapErr(err, "err.connect_device")
+		}
+		crashes, err := device.CrashReportsContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return wrapErr(err, "err.connect_service")
+		}
+		defer crashes.Close()
+
+		// Only reports written after the watch started are new.
+		filter, err := crashFilter()
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]bool)
+		reports, err := crashes.ListContext(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return wrapErr(err, "err.crash_list")
+		}
+		for _, r := range reports {
+			seen[r.Name] = true
+		}
+
+		ticker := time.NewTicker(time.Duration(crashOpts.interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+
+			if err := crashes.MoveContext(ctx); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return wrapErr(err, "err.connect_service")
+			}
+			reports, err := crashes.ListContext(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return wrapErr(err, "err.crash_list")
+			}
+
+			for _, r := range reports {
+				if seen[r.Name] {
+					continue
+				}
+				seen[r.Name] = true
+				if !filter(r) {
+					continue
+				}
+
+				if len(args) > 0 {
+					if _, err := pullCrashReport(crashes, r, args[0], crashOpts.delete); err != nil {
+						return err
+					}
+				}
+
+				if format == outputJSON {
+					if err := writeRecord(r); err != nil {
+						return err
+					}
+					continue
+				}
+				fmt.Printf("[%s] %s %s (%s)\n", r.Time.Format("15:04:05"), r.Process, r.Name, i18n.T("fmt.bytes", r.Size))
+			}
+		}
+	},
+}
+
+var CrashSymbolicateCommand = &gcli.Command{
+	Name: "symbolicate",
+	Desc: i18n.T("cmd.crash.symbolicate.desc"),
+	Config: func(c *gcli.Command) {
+		c.VarOpt(&crashOpts.dsyms, "dsym", "", i18n.T("cmd.crash.symbolicate.opt.dsym"))
+		c.AddArg("reports", i18n.T("cmd.crash.symbolicate.arg.reports"), true, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		sym := crashlog.NewSymbolicator()
+		defer sym.Close()
+		for _, p := range crashOpts.dsyms {
+			if err := sym.Add(p); err != nil {
+				return wrapErr(err, "err.crash_dsym", p)
+			}
+		}
+
+		var reports []*crashlog.Report
+		for _, name := range args {
+			r, err := crashlog.ParseFile(name)
+			if err != nil {
+				return wrapErr(err, "err.crash_parse", name)
+			}
+			sym.Symbolicate(r)
+			if img := missingDSYM(sym, r); img != nil {
+				_, _ = fmt.Fprintln(os.Stderr, i18n.T("msg.crash_no_dsym", name, img.Name, img.UUID))
+			}
+			reports = append(reports, r)
+		}
+
+		if format != outputTable {
+			if len(reports) == 1 {
+				return writeOutput(format, reports[0])
+			}
+			return writeOutput(format, reports)
+		}
+
+		for i, r := range reports {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := r.WriteText(os.Stdout); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+// missingDSYM returns the image of the crashed process when no dSYM was
+// given for it, which is what users most likely forgot.
+func missingDSYM(sym *crashlog.Symbolicator, r *crashlog.Report) *crashlog.Image {
+	known := make(map[string]bool)
+	for _, uuid := range sym.UUIDs() {
+		known[uuid] = true
+	}
+	for i := range r.Images {
+		img := &r.Images[i]
+		if img.Name == r.Process && img.UUID != "" && !known[img.UUID] {
+			return img
+		}
+	}
+
+	return nil
+}
+
+// crashService opens the crash reports of the selected device. The caller
+// closes it.
+func crashService() (*idevice.CrashReportService, error) {
+	device, err := openDevice()
+	if err != nil {
+		return nil, wrapErr(err, "err.connect_device")
+	}
+
+	crashes, err := device.CrashReports()
+	if err != nil {
+		return nil, wrapErr(err, "err.connect_service")
+	}
+
+	return crashes, nil
+}
+
+// listCrashReports returns the reports selected by --process and --since.
+func listCrashReports(crashes *idevice.CrashReportService) ([]idevice.CrashReport, error) {
+	filter, err := crashFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := crashes.List()
+	if err != nil {
+		return nil, wrapErr(err, "err.crash_list")
+	}
+
+	var reports []idevice.CrashReport
+	for _, r := range all {
+		if filter(r) {
+			reports = append(reports, r)
+		}
+	}
+
+	return reports, nil
+}
+
+// crashFilter matches reports against --process, a case insensitive
+// substring of the process name, and --since.
+func crashFilter() (func(idevice.CrashReport) bool, error) {
+	since, err := parseSince(crashOpts.since, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	process := strings.ToLower(crashOpts.process)
+
+	return func(r idevice.CrashReport) bool {
+		if process != "" && !strings.Contains(strings.ToLower(r.Process), process) {
+			return false
+		}
+
+		return r.Time.IsZero() || !r.Time.Before(since)
+	}, nil
+}
+
+// parseSince parses --since, either a duration before now such as "24h" or
+// a local date such as "2006-01-02" or "2006-01-02 15:04". An empty value
+// selects everything.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, xerrors.New(i18n.T("err.crash_since", s))
+}
+
+// pullCrashReport copies r into dir, keeping its place under the crash
+// report directory and its modification time, and deletes it from the
+// device when remove is set. It returns the local path.
+func pullCrashReport(crashes *idevice.CrashReportService, r idevice.CrashReport, dir string, remove bool) (string, error) {
+	dst := filepath.Join(dir, filepath.FromSlash(r.Name))
+	if err := copyCrashReport(crashes, r, dst); err != nil {
+		return "", wrapErr(err, "err.crash_pull", r.Name)
+	}
+	if remove {
+		if err := crashes.Remove(r.Name); err != nil {
+			return "", wrapErr(err, "err.crash_remove", r.Name)
+		}
+	}
+
+	return dst, nil
+}
+
+func copyCrashReport(crashes *idevice.CrashReportService, r idevice.CrashReport, dst string) error {
+	src, err := crashes.Open(r.Name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if r.Time.IsZero() {
+		return nil
+	}
+
+	return os.Chtimes(dst, r.Time, r.Time)
+}
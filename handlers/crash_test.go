@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2023, 5, 2, 12, 0, 0, 0, time.Local)
+	for in, want := range map[string]time.Time{
+		"":                 {},
+		"24h":              now.Add(-24 * time.Hour),
+		"2023-05-01":       time.Date(2023, 5, 1, 0, 0, 0, 0, time.Local),
+		"2023-05-01 10:30": time.Date(2023, 5, 1, 10, 30, 0, 0, time.Local),
+	} {
+		got, err := parseSince(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseSince(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := parseSince("yesterday", now); err == nil {
+		t.Error("parseSince(\"yesterday\") succeeded")
+	}
+}
+
+func TestCrashPull(t *testing.T) {
+	const udid = "00008020-001A2B3C4D5E6F01"
+	dev := idevicetest.NewDevice(udid)
+	mover := idevicetest.NewCrashReportMover()
+	mover.Crash("Retired/MyApp-2023-05-01-101010.ips", []byte("crash"))
+	mover.Crash("SpringBoard-2023-05-01-101010.ips", []byte("other"))
+	dev.AddService(idevice.CrashReportMoverServiceName, mover)
+	dev.AddService(idevice.CrashReportCopyServiceName, mover.Reports)
+
+	srv, err := idevicetest.NewServer(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	device, err := idevice.NewClient(idevice.WithSocketAddress(srv.Addr)).Device(idevice.DeviceFilter{UDID: udid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(device.Close)
+	crashes, err := device.CrashReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(crashes.Close)
+
+	crashOpts.process = "myapp"
+	t.Cleanup(func() { crashOpts.process = "" })
+	reports, err := listCrashReports(crashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Process != "MyApp" {
+		t.Fatalf("listCrashReports = %+v", reports)
+	}
+
+	dir := t.TempDir()
+	dst, err := pullCrashReport(crashes, reports[0], dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "Retired", "MyApp-2023-05-01-101010.ips"); dst != want {
+		t.Fatalf("pullCrashReport = %s, want %s", dst, want)
+	}
+	data, err := ioutil.ReadFile(dst)
+	if err != nil || string(data) != "crash" {
+		t.Fatalf("pulled %q, %v", data, err)
+	}
+	if mover.Reports.Exists("Retired/MyApp-2023-05-01-101010.ips") {
+		t.Fatal("report was not deleted after the download")
+	}
+	if !mover.Reports.Exists("SpringBoard-2023-05-01-101010.ips") {
+		t.Fatal("a report that was not selected was deleted")
+	}
+}
@@ -1,40 +1,44 @@
 package handlers
 
-import "github.com/gookit/gcli/v3"
+import (
+	"github.com/gofmt/iOSBox/pkg/i18n"
+
+	"github.com/gookit/gcli/v3"
+)
 
 var CydiaCommand = &gcli.Command{
 	Name:    "cydia",
-	Desc:    "Cydia 插件仓库",
+	Desc:    i18n.T("cmd.cydia.desc"),
 	Aliases: []string{"ca"},
 	Subs: []*gcli.Command{
 		{
 			Name:    "view",
-			Desc:    "展示插件列表",
+			Desc:    i18n.T("cmd.cydia.view.desc"),
 			Aliases: []string{"v"},
 		},
 		{
 			Name:    "search",
-			Desc:    "搜索插件",
+			Desc:    i18n.T("cmd.cydia.search.desc"),
 			Aliases: []string{"s"},
 		},
 		{
 			Name:    "install",
-			Desc:    "安装插件",
+			Desc:    i18n.T("cmd.cydia.install.desc"),
 			Aliases: []string{"i"},
 		},
 		{
 			Name:    "uninstall",
-			Desc:    "卸载插件",
+			Desc:    i18n.T("cmd.cydia.uninstall.desc"),
 			Aliases: []string{"u"},
 		},
 		{
 			Name:    "upgrade",
-			Desc:    "升级插件",
+			Desc:    i18n.T("cmd.cydia.upgrade.desc"),
 			Aliases: []string{"p"},
 		},
 		{
 			Name:    "publish",
-			Desc:    "发布插件",
+			Desc:    i18n.T("cmd.cydia.publish.desc"),
 			Aliases: []string{"pub"},
 		},
 	},
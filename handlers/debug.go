@@ -1,13 +1,14 @@
 package handlers
 
 import (
+	"github.com/gofmt/iOSBox/pkg/i18n"
 	"github.com/gofmt/iOSBox/pkg/lldb"
 	"github.com/gookit/gcli/v3"
 )
 
 var DebugCommand = &gcli.Command{
 	Name: "dbgserver",
-	Desc: "启动设备上的 debug-server",
+	Desc: i18n.T("cmd.dbgserver.desc"),
 	Func: func(c *gcli.Command, args []string) error {
 
 		return nil
@@ -16,8 +17,8 @@ var DebugCommand = &gcli.Command{
 
 var LLDBCommand = &gcli.Command{
 	Name: "lldb",
-	Desc: "多窗口 lldb 调试",
+	Desc: i18n.T("cmd.lldb.desc"),
 	Func: func(c *gcli.Command, args []string) error {
-		return lldb.Run()
+		return lldb.Run(i18n.T("lldb.title"), i18n.T("lldb.quit"))
 	},
 }
@@ -1,63 +1,195 @@
 package handlers
 
 import (
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 
-	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/i18n"
 
 	"github.com/gookit/gcli/v3"
-	"golang.org/x/xerrors"
 )
 
+var deviceInfoOpts = struct {
+	domain string
+	key    string
+}{}
+
+// deviceInfoKeys are the global domain values shown by a bare `info`.
+var deviceInfoKeys = []string{
+	"UniqueDeviceID",
+	"DeviceName",
+	"ProductName",
+	"ProductType",
+	"ProductVersion",
+	"CPUArchitecture",
+	"BuildVersion",
+	"SerialNumber",
+	"MLBSerialNumber",
+	"BluetoothAddress",
+	"WiFiAddress",
+	"EthernetAddress",
+	"DeviceColor",
+	"FirmwareVersion",
+	"ActivationState",
+	"HardwareModel",
+	"HardwarePlatform",
+	"UniqueChipID",
+	"WirelessBoardSerialNumber",
+}
+
 var DeviceInfoCommand = &gcli.Command{
 	Name:    "info",
-	Desc:    "显示当前设备信息",
+	Desc:    i18n.T("cmd.info.desc"),
 	Aliases: []string{"in"},
+	Config: func(c *gcli.Command) {
+		c.StrOpt(&deviceInfoOpts.domain, "domain", "d", "", i18n.T("cmd.info.opt.domain"))
+		c.StrOpt(&deviceInfoOpts.key, "key", "k", "", i18n.T("cmd.info.opt.key"))
+	},
+	Examples: `{$binName} {$cmd}
+{$binName} {$cmd} --key ProductVersion
+{$binName} {$cmd} --domain com.apple.mobile.battery`,
 	Func: func(c *gcli.Command, args []string) error {
-		device, err := idevice.GetDevice()
+		format, err := outputFormat()
 		if err != nil {
-			return xerrors.Errorf("获取iOS设备错误: %w", err)
+			return err
 		}
 
-		lockdown, err := idevice.ConnectLockdownWithSession(device)
+		device, err := openDevice()
 		if err != nil {
-			return err
+			return wrapErr(err, "err.get_device")
 		}
 
-		ret, err := lockdown.GetValues()
+		value, err := device.GetValue(deviceInfoOpts.domain, deviceInfoOpts.key)
 		if err != nil {
-			return xerrors.Errorf("获取设备信息错误：%w", err)
+			return wrapErr(err, "err.device_info")
 		}
 
-		info := ret["Value"].(map[string]interface{})
+		// Machine readable output always carries the full value, so a bare
+		// info emits every global domain value rather than the summary.
+		if format != outputTable {
+			return writeOutput(format, value)
+		}
+
+		if deviceInfoOpts.domain != "" || deviceInfoOpts.key != "" {
+			printValue(os.Stdout, value)
+			return nil
+		}
+
+		info, _ := value.(map[string]interface{})
 
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 0, 0, 1, ' ', 0)
 
-		_, _ = fmt.Fprintln(w, "- UDID\t: "+info["UniqueDeviceID"].(string))
-		_, _ = fmt.Fprintln(w, "- DeviceName\t: "+info["DeviceName"].(string))
-		_, _ = fmt.Fprintln(w, "- ProductName\t: "+info["ProductName"].(string))
-		_, _ = fmt.Fprintln(w, "- ProductType\t: "+info["ProductType"].(string))
-		_, _ = fmt.Fprintln(w, "- ProductVersion\t: "+info["ProductVersion"].(string))
-		_, _ = fmt.Fprintln(w, "- CPUArchitecture\t: "+info["CPUArchitecture"].(string))
-		_, _ = fmt.Fprintln(w, "- BuildVersion\t: "+info["BuildVersion"].(string))
-		_, _ = fmt.Fprintln(w, "- SerialNumber\t: "+info["SerialNumber"].(string))
-		_, _ = fmt.Fprintln(w, "- MLBSerialNumber\t: "+info["MLBSerialNumber"].(string))
-		_, _ = fmt.Fprintln(w, "- BluetoothAddress\t: "+info["BluetoothAddress"].(string))
-		_, _ = fmt.Fprintln(w, "- WiFiAddress\t: "+info["WiFiAddress"].(string))
-		_, _ = fmt.Fprintln(w, "- EthernetAddress\t: "+info["EthernetAddress"].(string))
-		_, _ = fmt.Fprintln(w, "- DeviceColor\t: "+info["DeviceColor"].(string))
-		_, _ = fmt.Fprintln(w, "- FirmwareVersion\t: "+info["FirmwareVersion"].(string))
-		_, _ = fmt.Fprintln(w, "- ActivationState\t: "+info["ActivationState"].(string))
-		_, _ = fmt.Fprintln(w, "- HardwareModel\t: "+info["HardwareModel"].(string))
-		_, _ = fmt.Fprintln(w, "- HardwarePlatform\t: "+info["HardwarePlatform"].(string))
-		_, _ = fmt.Fprintln(w, "- UniqueChipID\t: "+fmt.Sprintf("%d", info["UniqueChipID"]))
-		_, _ = fmt.Fprintln(w, "- WirelessBoardSerialNumber\t: "+info["WirelessBoardSerialNumber"].(string))
+		for _, key := range deviceInfoKeys {
+			name := key
+			if key == "UniqueDeviceID" {
+				name = "UDID"
+			}
+			v, ok := info[key]
+			if !ok {
+				v = "-"
+			}
+			_, _ = fmt.Fprintf(w, "- %s\t: %s\n", name, formatScalar(v))
+		}
 
 		_ = w.Flush()
 
 		return nil
 	},
 }
+
+// printValue prints a plist value tree: dictionaries sorted by key, arrays by
+// index and nested containers indented below their key.
+func printValue(w io.Writer, v interface{}) {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		printTree(w, v, 0)
+	default:
+		_, _ = fmt.Fprintln(w, formatScalar(v))
+	}
+}
+
+func printTree(w io.Writer, v interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	line := func(name string, child interface{}) {
+		switch c := child.(type) {
+		case map[string]interface{}:
+			if len(c) == 0 {
+				_, _ = fmt.Fprintf(w, "%s%s: {}\n", indent, name)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "%s%s:\n", indent, name)
+			printTree(w, c, depth+1)
+		case []interface{}:
+			if len(c) == 0 {
+				_, _ = fmt.Fprintf(w, "%s%s: []\n", indent, name)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "%s%s:\n", indent, name)
+			printTree(w, c, depth+1)
+		default:
+			_, _ = fmt.Fprintf(w, "%s%s: %s\n", indent, name, formatScalar(c))
+		}
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line(k, t[k])
+		}
+	case []interface{}:
+		for i, item := range t {
+			line(fmt.Sprintf("[%d]", i), item)
+		}
+	}
+}
+
+// formatScalar renders a leaf value. Data blobs are shown as text when they
+// are printable, otherwise as a size and a hex prefix.
+func formatScalar(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return t
+	case []byte:
+		if len(t) > 0 && utf8.Valid(t) && isPrintable(string(t)) {
+			return fmt.Sprintf("%s %q", i18n.T("fmt.data", len(t)), t)
+		}
+		if len(t) > 32 {
+			return fmt.Sprintf("%s %s...", i18n.T("fmt.data", len(t)), hex.EncodeToString(t[:32]))
+		}
+		return fmt.Sprintf("%s %s", i18n.T("fmt.data", len(t)), hex.EncodeToString(t))
+	case time.Time:
+		return t.Format(time.RFC3339)
+	case map[string]interface{}:
+		return i18n.T("fmt.dict", len(t))
+	case []interface{}:
+		return i18n.T("fmt.array", len(t))
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if r == utf8.RuneError || (r < 0x20 && r != '\n' && r != '\t' && r != '\r') || r == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
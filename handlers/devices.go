@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+
+	"github.com/gookit/gcli/v3"
+)
+
+// deviceRecord is one entry of the devices output.
+type deviceRecord struct {
+	DeviceID        int
+	UDID            string
+	ConnectionType  string
+	ProductID       int
+	USBSerialNumber string
+	Address         string
+}
+
+func newDeviceRecord(entry idevice.DeviceEntry) deviceRecord {
+	props := entry.Properties
+	rec := deviceRecord{
+		DeviceID:       entry.DeviceID,
+		UDID:           props.SerialNumber,
+		ConnectionType: props.ConnectionType,
+	}
+	if entry.IsNetwork() {
+		if ip := props.IPAddress(); ip != nil {
+			rec.Address = ip.String()
+		}
+	} else {
+		rec.ProductID = props.ProductID
+		rec.USBSerialNumber = props.USBSerialNumber
+	}
+
+	return rec
+}
+
+var DevicesCommand = &gcli.Command{
+	Name:    "devices",
+	Desc:    i18n.T("cmd.devices.desc"),
+	Aliases: []string{"ds"},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		filter, err := deviceFilter()
+		if err != nil {
+			return err
+		}
+
+		list, err := idevice.GetDevices()
+		if err != nil {
+			return wrapErr(err, "err.list_devices")
+		}
+		list = idevice.FilterDevices(list, filter)
+
+		records := make([]deviceRecord, 0, len(list))
+		for _, entry := range list {
+			records = append(records, newDeviceRecord(entry))
+		}
+
+		if format != outputTable {
+			return writeOutput(format, records)
+		}
+
+		if len(records) == 0 {
+			c.Println(i18n.T("device.none"))
+			return nil
+		}
+
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ID\tUDID\tConnectionType\tProductID\tSerialNumber\tAddress")
+		for _, rec := range records {
+			productId, serial, address := "-", "-", "-"
+			if rec.ProductID != 0 {
+				productId = fmt.Sprintf("0x%04x", rec.ProductID)
+			}
+			if rec.USBSerialNumber != "" {
+				serial = rec.USBSerialNumber
+			}
+			if rec.Address != "" {
+				address = rec.Address
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				rec.DeviceID,
+				rec.UDID,
+				rec.ConnectionType,
+				productId,
+				serial,
+				address,
+			)
+		}
+		_ = w.Flush()
+
+		return nil
+	},
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"github.com/gofmt/iOSBox/pkg/i18n"
+
 	"github.com/gookit/gcli/v3"
 )
 
 var DonationCommand = &gcli.Command{
 	Name: "donation",
-	Desc: "捐助作者，保持更新能力",
+	Desc: i18n.T("cmd.donation.desc"),
 	Func: func(c *gcli.Command, args []string) error {
 
 		return nil
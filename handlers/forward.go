@@ -6,23 +6,23 @@ import (
 	"os/signal"
 	"strconv"
 
-	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/i18n"
 
 	"github.com/gookit/gcli/v3"
 )
 
 var ForwardCommand = &gcli.Command{
 	Name: "forward",
-	Desc: "映射设备端口到本地端口(替代iproxy)",
+	Desc: i18n.T("cmd.forward.desc"),
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "本机端口", true)
-		c.AddArg("arg1", "设备端口", true)
+		c.AddArg("arg0", i18n.T("cmd.forward.arg.local"), true)
+		c.AddArg("arg1", i18n.T("cmd.forward.arg.remote"), true)
 	},
-	Examples: "{$binName} {$cmd} 本机端口 设备端口",
+	Examples: i18n.T("cmd.forward.example"),
 	Func: func(c *gcli.Command, args []string) error {
-		device, err := idevice.GetDevice()
+		device, err := openDevice()
 		if err != nil {
-			return err
+			return wrapErr(err, "err.connect_device")
 		}
 
 		localPort, err := strconv.Atoi(args[0])
@@ -35,19 +35,14 @@ var ForwardCommand = &gcli.Command{
 			return err
 		}
 
-		service := idevice.NewForwardService(device)
-		err = service.Start(uint16(localPort), uint16(remotePort), func(s string, err error) {
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println(s)
-			}
-		})
+		service, err := device.Forward(uint16(localPort), uint16(remotePort))
 		if err != nil {
 			return err
 		}
 		defer service.Close()
 
+		fmt.Println(i18n.T("msg.forwarding", localPort, remotePort))
+
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, os.Interrupt)
 		<-quit
@@ -1,23 +1,27 @@
 package handlers
 
-import "github.com/gookit/gcli/v3"
+import (
+	"github.com/gofmt/iOSBox/pkg/i18n"
+
+	"github.com/gookit/gcli/v3"
+)
 
 var FridaCommand = &gcli.Command{
 	Name:    "frida",
-	Desc:    "Frida 脚本仓库",
+	Desc:    i18n.T("cmd.frida.desc"),
 	Aliases: []string{"fa"},
 	Subs: []*gcli.Command{
 		{
 			Name: "list",
-			Desc: "脚本列表",
+			Desc: i18n.T("cmd.frida.list.desc"),
 		},
 		{
 			Name: "search",
-			Desc: "搜索脚本",
+			Desc: i18n.T("cmd.frida.search.desc"),
 		},
 		{
 			Name: "get",
-			Desc: "下载脚本",
+			Desc: i18n.T("cmd.frida.get.desc"),
 		},
 	},
 	Func: func(c *gcli.Command, args []string) error {
@@ -0,0 +1,968 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+
+	"github.com/gookit/gcli/v3"
+	"github.com/gookit/gcli/v3/progress"
+	"golang.org/x/xerrors"
+)
+
+var fsOpts = struct {
+	app       string
+	documents bool
+	recursive bool
+	long      bool
+	verify    bool
+	resume    bool
+}{}
+
+var FSCommand = &gcli.Command{
+	Name: "fs",
+	Desc: i18n.T("cmd.fs.desc"),
+	Examples: `{$binName} {$cmd} ls -l /DCIM/100APPLE
+{$binName} {$cmd} pull "/DCIM/100APPLE/*.JPG" ./photos
+{$binName} {$cmd} push -r ./docs /Downloads
+{$binName} {$cmd} rm -r /Downloads/docs
+{$binName} {$cmd} --app com.foo.bar pull -r /Library/Caches ./caches
+{$binName} {$cmd} --app com.foo.bar --documents ls /Documents`,
+	Config: func(c *gcli.Command) {
+		c.StrOpt(&fsOpts.app, "app", "a", "", i18n.T("cmd.fs.opt.app"))
+		c.BoolOpt(&fsOpts.documents, "documents", "", false, i18n.T("cmd.fs.opt.documents"))
+	},
+	Subs: []*gcli.Command{
+		FSListCommand,
+		FSPullCommand,
+		FSPushCommand,
+		FSRemoveCommand,
+		FSMakeDirCommand,
+		FSMoveCommand,
+		FSStatCommand,
+		FSTreeCommand,
+	},
+}
+
+var FSListCommand = &gcli.Command{
+	Name: "ls",
+	Desc: i18n.T("cmd.fs.ls.desc"),
+	Config: func(c *gcli.Command) {
+		c.BoolOpt(&fsOpts.long, "long", "l", false, i18n.T("cmd.fs.ls.opt.long"))
+		c.AddArg("path", i18n.T("cmd.fs.arg.path"), false, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+		fsys := fm.FS()
+
+		if len(args) == 0 {
+			args = []string{"/"}
+		}
+		names, err := expandRemote(fsys, args)
+		if err != nil {
+			return err
+		}
+
+		var entries []fsEntry
+		for _, name := range names {
+			fi, err := fsys.Stat(name)
+			if err != nil {
+				return wrapErr(err, "err.fs_list", remotePath(name))
+			}
+			if !fi.IsDir() {
+				entries = append(entries, newFSEntry(name, fi))
+				continue
+			}
+
+			list, err := fsys.ReadDir(name)
+			if err != nil {
+				return wrapErr(err, "err.fs_list", remotePath(name))
+			}
+			for _, d := range list {
+				info, err := d.Info()
+				if err != nil {
+					return wrapErr(err, "err.fs_list", remotePath(name))
+				}
+				entries = append(entries, newFSEntry(path.Join(name, d.Name()), info))
+			}
+		}
+
+		if format != outputTable {
+			return writeOutput(format, entries)
+		}
+
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, e := range entries {
+			if fsOpts.long {
+				_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.mode, e.Size, e.ModTime.Format("2006-01-02 15:04"), e.display())
+			} else {
+				_, _ = fmt.Fprintln(w, e.display())
+			}
+		}
+
+		return w.Flush()
+	},
+}
+
+var FSPullCommand = &gcli.Command{
+	Name: "pull",
+	Desc: i18n.T("cmd.fs.pull.desc"),
+	Config: func(c *gcli.Command) {
+		c.BoolOpt(&fsOpts.recursive, "recursive", "r", false, i18n.T("cmd.fs.opt.recursive"))
+		c.BoolOpt(&fsOpts.verify, "verify", "", false, i18n.T("cmd.fs.opt.verify"))
+		c.AddArg("paths", i18n.T("cmd.fs.pull.arg.paths"), true, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		if len(args) < 2 {
+			return xerrors.New(i18n.T("err.fs_args", 2))
+		}
+
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+		fsys := fm.FS()
+
+		srcs, err := expandRemote(fsys, args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+		items, err := planPull(fsys, srcs, args[len(args)-1], fsOpts.recursive)
+		if err != nil {
+			return err
+		}
+
+		bar := newTransferBar(items)
+		err = runPull(fsys, items, bar.advance)
+		bar.finish()
+		if err != nil {
+			return err
+		}
+
+		printTransferred(items)
+		if fsOpts.verify {
+			return verifyItems(fm, items, true)
+		}
+
+		return nil
+	},
+}
+
+var FSPushCommand = &gcli.Command{
+	Name: "push",
+	Desc: i18n.T("cmd.fs.push.desc"),
+	Config: func(c *gcli.Command) {
+		c.BoolOpt(&fsOpts.recursive, "recursive", "r", false, i18n.T("cmd.fs.opt.recursive"))
+		c.BoolOpt(&fsOpts.verify, "verify", "", false, i18n.T("cmd.fs.opt.verify"))
+		c.BoolOpt(&fsOpts.resume, "resume", "", false, i18n.T("cmd.fs.push.opt.resume"))
+		c.AddArg("paths", i18n.T("cmd.fs.push.arg.paths"), true, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		if len(args) < 2 {
+			return xerrors.New(i18n.T("err.fs_args", 2))
+		}
+
+		srcs, err := expandLocal(args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+
+		items, err := planPush(fm.FS(), srcs, args[len(args)-1], fsOpts.recursive)
+		if err != nil {
+			return err
+		}
+
+		var opts []idevice.UploadOption
+		if fsOpts.resume {
+			opts = append(opts, idevice.WithResume())
+		}
+
+		bar := newTransferBar(items)
+		err = runPush(fm, items, bar.advance, opts...)
+		bar.finish()
+		if err != nil {
+			return err
+		}
+
+		printTransferred(items)
+		if fsOpts.verify {
+			return verifyItems(fm, items, false)
+		}
+
+		return nil
+	},
+}
+
+var FSRemoveCommand = &gcli.Command{
+	Name: "rm",
+	Desc: i18n.T("cmd.fs.rm.desc"),
+	Config: func(c *gcli.Command) {
+		c.BoolOpt(&fsOpts.recursive, "recursive", "r", false, i18n.T("cmd.fs.opt.recursive"))
+		c.AddArg("path", i18n.T("cmd.fs.arg.path"), true, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+
+		names, err := expandRemote(fm.FS(), args)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			if name == "." {
+				return xerrors.New(i18n.T("err.fs_root"))
+			}
+			if fsOpts.recursive {
+				err = fm.RemovePathAndContents(remotePath(name))
+			} else {
+				_, err = fm.RemovePath(remotePath(name))
+			}
+			if err != nil {
+				return wrapErr(err, "err.fs_remove", remotePath(name))
+			}
+		}
+
+		return nil
+	},
+}
+
+var FSMakeDirCommand = &gcli.Command{
+	Name: "mkdir",
+	Desc: i18n.T("cmd.fs.mkdir.desc"),
+	Config: func(c *gcli.Command) {
+		c.AddArg("path", i18n.T("cmd.fs.arg.path"), true, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+
+		for _, arg := range args {
+			p := remotePath(remoteName(arg))
+			if _, err := fm.MakeDir(p); err != nil {
+				return wrapErr(err, "err.fs_mkdir", p)
+			}
+		}
+
+		return nil
+	},
+}
+
+var FSMoveCommand = &gcli.Command{
+	Name: "mv",
+	Desc: i18n.T("cmd.fs.mv.desc"),
+	Config: func(c *gcli.Command) {
+		c.AddArg("paths", i18n.T("cmd.fs.mv.arg.paths"), true, true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		if len(args) < 2 {
+			return xerrors.New(i18n.T("err.fs_args", 2))
+		}
+
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+		fsys := fm.FS()
+
+		srcs, err := expandRemote(fsys, args[:len(args)-1])
+		if err != nil {
+			return err
+		}
+		dst := args[len(args)-1]
+		intoDir, err := remoteIsDir(fsys, dst, len(srcs) > 1)
+		if err != nil {
+			return err
+		}
+
+		for _, src := range srcs {
+			target := remoteName(dst)
+			if intoDir {
+				target = path.Join(target, path.Base(src))
+			}
+			if err := fm.RenamePath(remotePath(src), remotePath(target)); err != nil {
+				return wrapErr(err, "err.fs_move", remotePath(src))
+			}
+		}
+
+		return nil
+	},
+}
+
+var FSStatCommand = &gcli.Command{
+	Name: "stat",
+	Desc: i18n.T("cmd.fs.stat.desc"),
+	Config: func(c *gcli.Command) {
+		c.AddArg("path", i18n.T("cmd.fs.arg.path"), true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+
+		p := remotePath(remoteName(args[0]))
+		info, err := fm.GetFileInfo(p)
+		if err != nil {
+			return wrapErr(err, "err.fs_stat", p)
+		}
+
+		if format != outputTable {
+			return writeOutput(format, info)
+		}
+
+		keys := make([]string, 0, len(info))
+		for key := range info {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 0, 1, ' ', 0)
+		for _, key := range keys {
+			_, _ = fmt.Fprintf(w, "- %s\t: %v\n", key, info[key])
+		}
+
+		return w.Flush()
+	},
+}
+
+var FSTreeCommand = &gcli.Command{
+	Name: "tree",
+	Desc: i18n.T("cmd.fs.tree.desc"),
+	Config: func(c *gcli.Command) {
+		c.AddArg("path", i18n.T("cmd.fs.arg.path"), false)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		fm, err := fsService()
+		if err != nil {
+			return err
+		}
+		defer fm.Close()
+
+		root := "."
+		if len(args) > 0 {
+			root = remoteName(args[0])
+		}
+
+		fmt.Println(remotePath(root))
+		dirs, files, err := printFSTree(os.Stdout, fm.FS(), root, "")
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Println(i18n.T("msg.fs_tree_summary", dirs, files))
+
+		return nil
+	},
+}
+
+// fsService connects to the AFC service of the selected device, or to the
+// sandbox of the app given with --app. The caller closes it.
+func fsService() (*idevice.FileManagerService, error) {
+	device, err := openDevice()
+	if err != nil {
+		return nil, wrapErr(err, "err.connect_device")
+	}
+
+	var fm *idevice.FileManagerService
+	switch {
+	case fsOpts.app == "":
+		fm, err = device.AFC()
+	case fsOpts.documents:
+		fm, err = device.AppDocuments(fsOpts.app)
+	default:
+		fm, err = device.AppContainer(fsOpts.app)
+	}
+	if err != nil {
+		if fsOpts.app != "" {
+			return nil, wrapErr(err, "err.fs_app", fsOpts.app)
+		}
+		return nil, wrapErr(err, "err.connect_service")
+	}
+
+	return fm, nil
+}
+
+// remoteName turns a device path such as "/DCIM/100APPLE" into a name of
+// idevice.AFCFS. Device paths are relative to the media root either way.
+func remoteName(p string) string {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		return "."
+	}
+
+	return name
+}
+
+// remotePath is the device path of an AFCFS name.
+func remotePath(name string) string {
+	if name == "." {
+		return "/"
+	}
+
+	return "/" + name
+}
+
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[`)
+}
+
+// expandRemote expands the wildcards in device paths into AFCFS names.
+// Quote the paths so that the local shell leaves them alone.
+func expandRemote(fsys *idevice.AFCFS, args []string) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		name := remoteName(arg)
+		if !hasGlobMeta(name) {
+			names = append(names, name)
+			continue
+		}
+
+		matches, err := fs.Glob(fsys, name)
+		if err != nil {
+			return nil, wrapErr(err, "err.fs_list", arg)
+		}
+		if len(matches) == 0 {
+			return nil, xerrors.New(i18n.T("err.fs_no_match", arg))
+		}
+		names = append(names, matches...)
+	}
+
+	return names, nil
+}
+
+// expandLocal expands wildcards the shell left alone, as cmd.exe does.
+func expandLocal(args []string) ([]string, error) {
+	var names []string
+	for _, arg := range args {
+		if !hasGlobMeta(arg) {
+			names = append(names, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, xerrors.New(i18n.T("err.fs_no_match", arg))
+		}
+		names = append(names, matches...)
+	}
+
+	return names, nil
+}
+
+// remoteIsDir reports whether the destination dst names a directory that
+// sources are copied into. many is set when there are several sources, in
+// which case it has to be one.
+func remoteIsDir(fsys *idevice.AFCFS, dst string, many bool) (bool, error) {
+	fi, err := fsys.Stat(remoteName(dst))
+	switch {
+	case err == nil && fi.IsDir():
+		return true, nil
+	case err != nil && !xerrors.Is(err, fs.ErrNotExist):
+		return false, wrapErr(err, "err.fs_stat", dst)
+	case many:
+		return false, xerrors.New(i18n.T("err.fs_not_dir", dst))
+	}
+
+	return strings.HasSuffix(dst, "/"), nil
+}
+
+// localIsDir is remoteIsDir for a host path.
+func localIsDir(dst string, many bool) (bool, error) {
+	fi, err := os.Stat(dst)
+	switch {
+	case err == nil && fi.IsDir():
+		return true, nil
+	case err != nil && !os.IsNotExist(err):
+		return false, err
+	case many:
+		return false, xerrors.New(i18n.T("err.fs_not_dir", dst))
+	}
+
+	return strings.HasSuffix(dst, "/") || strings.HasSuffix(dst, string(filepath.Separator)), nil
+}
+
+// fsEntry is a file listed by fs ls.
+type fsEntry struct {
+	Name       string
+	Path       string
+	Type       string
+	Size       int64
+	ModTime    time.Time
+	LinkTarget string `json:",omitempty" plist:",omitempty"`
+
+	mode fs.FileMode
+}
+
+func newFSEntry(name string, fi fs.FileInfo) fsEntry {
+	e := fsEntry{
+		Name:    fi.Name(),
+		Path:    remotePath(name),
+		Type:    "file",
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		mode:    fi.Mode(),
+	}
+	switch {
+	case fi.IsDir():
+		e.Type = "dir"
+	case fi.Mode()&fs.ModeSymlink != 0:
+		e.Type = "symlink"
+	}
+	if info, ok := fi.(*idevice.AFCFileInfo); ok {
+		e.LinkTarget = info.LinkTarget()
+	}
+
+	return e
+}
+
+// display is the name shown by fs ls, marked like ls -F.
+func (e fsEntry) display() string {
+	switch e.Type {
+	case "dir":
+		return e.Name + "/"
+	case "symlink":
+		return e.Name + " -> " + e.LinkTarget
+	}
+
+	return e.Name
+}
+
+// printFSTree writes the entries under name like tree(1) and counts them.
+func printFSTree(w io.Writer, fsys *idevice.AFCFS, name, indent string) (dirs, files int, err error) {
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		return 0, 0, wrapErr(err, "err.fs_list", remotePath(name))
+	}
+
+	for i, d := range entries {
+		branch, next := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return dirs, files, wrapErr(err, "err.fs_list", remotePath(name))
+		}
+		_, _ = fmt.Fprintln(w, indent+branch+newFSEntry(path.Join(name, d.Name()), info).display())
+
+		if !d.IsDir() {
+			files++
+			continue
+		}
+		dirs++
+		subDirs, subFiles, err := printFSTree(w, fsys, path.Join(name, d.Name()), indent+next)
+		dirs, files = dirs+subDirs, files+subFiles
+		if err != nil {
+			return dirs, files, err
+		}
+	}
+
+	return dirs, files, nil
+}
+
+// transferItem is one file, directory or symbolic link to copy. src and dst
+// are AFCFS names on the device side and host paths on the other.
+type transferItem struct {
+	src   string
+	dst   string
+	mode  fs.FileMode
+	size  int64
+	mtime time.Time
+	link  string
+}
+
+func (t transferItem) isDir() bool {
+	return t.mode.IsDir()
+}
+
+func (t transferItem) isLink() bool {
+	return t.mode&fs.ModeSymlink != 0
+}
+
+// planPull lists what copying srcs from the device to the host path dst
+// involves, walking directories when recursive is set.
+func planPull(fsys *idevice.AFCFS, srcs []string, dst string, recursive bool) ([]transferItem, error) {
+	intoDir, err := localIsDir(dst, len(srcs) > 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []transferItem
+	for _, src := range srcs {
+		fi, err := fsys.Stat(src)
+		if err != nil {
+			return nil, wrapErr(err, "err.fs_stat", remotePath(src))
+		}
+
+		target := dst
+		if intoDir && src != "." {
+			target = filepath.Join(dst, path.Base(src))
+		}
+
+		if !fi.IsDir() {
+			items = append(items, pullItem(src, target, fi))
+			continue
+		}
+		if !recursive {
+			return nil, xerrors.New(i18n.T("err.fs_is_dir", remotePath(src)))
+		}
+
+		err = fs.WalkDir(fsys, src, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel := strings.TrimPrefix(strings.TrimPrefix(p, src), "/")
+			if src == "." {
+				rel = p
+			}
+			items = append(items, pullItem(p, filepath.Join(target, filepath.FromSlash(rel)), info))
+			return nil
+		})
+		if err != nil {
+			return nil, wrapErr(err, "err.fs_list", remotePath(src))
+		}
+	}
+
+	return items, nil
+}
+
+func pullItem(src, dst string, fi fs.FileInfo) transferItem {
+	item := transferItem{src: src, dst: dst, mode: fi.Mode(), size: fi.Size(), mtime: fi.ModTime()}
+	if info, ok := fi.(*idevice.AFCFileInfo); ok {
+		item.link = info.LinkTarget()
+	}
+	if item.isDir() || item.isLink() {
+		item.size = 0
+	}
+
+	return item
+}
+
+// runPull copies items from the device and then gives them the device's
+// modification times. advance is called with the name and byte count of
+// every chunk.
+func runPull(fsys *idevice.AFCFS, items []transferItem, advance func(name string, n int)) error {
+	for _, item := range items {
+		var err error
+		switch {
+		case item.isDir():
+			err = os.MkdirAll(item.dst, 0755)
+		case item.isLink():
+			_ = os.Remove(item.dst)
+			err = os.Symlink(item.link, item.dst)
+		default:
+			err = pullFile(fsys, item, advance)
+		}
+		if err != nil {
+			return wrapErr(err, "err.fs_pull", remotePath(item.src))
+		}
+	}
+
+	return setTimes(items, func(item transferItem) error {
+		return os.Chtimes(item.dst, item.mtime, item.mtime)
+	})
+}
+
+func pullFile(fsys *idevice.AFCFS, item transferItem, advance func(name string, n int)) error {
+	src, err := fsys.Open(item.src)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(item.dst)
+	if err != nil {
+		return err
+	}
+	if err := copyChunks(dst, src, item.src, advance); err != nil {
+		_ = dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// planPush lists what copying the host paths srcs to the device path dst
+// involves, walking directories when recursive is set.
+func planPush(fsys *idevice.AFCFS, srcs []string, dst string, recursive bool) ([]transferItem, error) {
+	intoDir, err := remoteIsDir(fsys, dst, len(srcs) > 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []transferItem
+	for _, src := range srcs {
+		fi, err := os.Lstat(src)
+		if err != nil {
+			return nil, err
+		}
+
+		target := remoteName(dst)
+		if intoDir {
+			target = path.Join(target, filepath.Base(src))
+		}
+
+		if !fi.IsDir() {
+			item, err := pushItem(src, target, fi)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		if !recursive {
+			return nil, xerrors.New(i18n.T("err.fs_is_dir", src))
+		}
+
+		err = filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src, p)
+			if err != nil {
+				return err
+			}
+			item, err := pushItem(p, path.Join(target, filepath.ToSlash(rel)), info)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func pushItem(src, dst string, fi os.FileInfo) (transferItem, error) {
+	item := transferItem{src: src, dst: dst, mode: fi.Mode(), size: fi.Size(), mtime: fi.ModTime()}
+	if item.isLink() {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return item, err
+		}
+		item.link = link
+	}
+	if item.isDir() || item.isLink() {
+		item.size = 0
+	}
+
+	return item, nil
+}
+
+// runPush copies items to the device with opts and then gives them the
+// host's modification times.
+func runPush(fm *idevice.FileManagerService, items []transferItem, advance func(name string, n int), opts ...idevice.UploadOption) error {
+	for _, item := range items {
+		var err error
+		switch {
+		case item.isDir():
+			_, err = fm.MakeDir(remotePath(item.dst))
+		case item.isLink():
+			_, _ = fm.RemovePath(remotePath(item.dst))
+			err = fm.MakeLink(idevice.AFC_SYMLINK, item.link, remotePath(item.dst))
+		default:
+			err = pushFile(fm, item, advance, opts...)
+		}
+		if err != nil {
+			return wrapErr(err, "err.fs_push", item.src)
+		}
+	}
+
+	return setTimes(items, func(item transferItem) error {
+		return fm.SetFileModTime(remotePath(item.dst), item.mtime)
+	})
+}
+
+func pushFile(fm *idevice.FileManagerService, item transferItem, advance func(name string, n int), opts ...idevice.UploadOption) error {
+	src, err := os.Open(item.src)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var done int64
+	return fm.FileUpload(src, remotePath(item.dst), func(written int64) {
+		if advance != nil {
+			advance(item.src, int(written-done))
+		}
+		done = written
+	}, opts...)
+}
+
+// verifyItems compares the files of items, once copied, on both sides. pulled
+// tells whether they were copied from the device.
+func verifyItems(fm *idevice.FileManagerService, items []transferItem, pulled bool) error {
+	files := 0
+	for _, item := range items {
+		if item.isDir() || item.isLink() {
+			continue
+		}
+		local, remote := item.src, remotePath(item.dst)
+		if pulled {
+			local, remote = item.dst, remotePath(item.src)
+		}
+		if err := verifyFile(fm, local, remote); err != nil {
+			return wrapErr(err, "err.fs_verify", remote)
+		}
+		files++
+	}
+
+	fmt.Println(i18n.T("msg.fs_verified", files))
+
+	return nil
+}
+
+func verifyFile(fm *idevice.FileManagerService, local, remote string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return fm.Verify(f, remote)
+}
+
+// setTimes applies the modification times once everything is copied, so
+// that creating files doesn't bump the times of their directories. Links
+// are skipped, as both sides would set the time of the target.
+func setTimes(items []transferItem, set func(item transferItem) error) error {
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.isLink() {
+			continue
+		}
+		if err := set(item); err != nil {
+			return wrapErr(err, "err.fs_mtime", item.dst)
+		}
+	}
+
+	return nil
+}
+
+// copyChunks copies src to dst in AFC sized chunks, reporting each one to
+// advance.
+func copyChunks(dst io.Writer, src io.Reader, name string, advance func(name string, n int)) error {
+	buf := make([]byte, idevice.DefaultChunkSize)
+	for {
+		nr, err := src.Read(buf)
+		if nr > 0 {
+			if _, werr := dst.Write(buf[:nr]); werr != nil {
+				return werr
+			}
+			if advance != nil {
+				advance(name, nr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// transferBar is the progress bar of fs pull and push, counted in bytes.
+type transferBar struct {
+	p *progress.Progress
+}
+
+func newTransferBar(items []transferItem) *transferBar {
+	var total int64
+	for _, item := range items {
+		total += item.size
+	}
+	if total == 0 {
+		return &transferBar{}
+	}
+
+	p := progress.CustomBar(40, progress.BarStyles[3])
+	p.MaxSteps = uint(total)
+	p.Format = progress.BarFormat
+	p.AddMessage("message", "")
+	p.Start()
+
+	return &transferBar{p: p}
+}
+
+func (b *transferBar) advance(name string, n int) {
+	if b.p == nil {
+		return
+	}
+
+	b.p.AddMessage("message", " "+name)
+	b.p.Advance(uint(n))
+}
+
+func (b *transferBar) finish() {
+	if b.p != nil {
+		b.p.Finish()
+	}
+}
+
+func printTransferred(items []transferItem) {
+	var files int
+	var bytes int64
+	for _, item := range items {
+		if !item.isDir() && !item.isLink() {
+			files++
+			bytes += item.size
+		}
+	}
+
+	fmt.Println(i18n.T("msg.fs_copied", files, bytes))
+}
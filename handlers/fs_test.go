@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func newTestAFC(t *testing.T) (*idevicetest.AFC, *idevice.FileManagerService) {
+	t.Helper()
+
+	const udid = "00008020-001A2B3C4D5E6F01"
+	dev := idevicetest.NewDevice(udid)
+	afc := idevicetest.NewAFC()
+	dev.AddService(idevice.FileManagerServiceName, afc)
+
+	srv, err := idevicetest.NewServer(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+
+	device, err := idevice.NewClient(idevice.WithSocketAddress(srv.Addr)).Device(idevice.DeviceFilter{UDID: udid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(device.Close)
+
+	fm, err := device.AFC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fm.Close)
+
+	return afc, fm
+}
+
+func TestRemoteName(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                ".",
+		"/":               ".",
+		"/DCIM/100APPLE/": "DCIM/100APPLE",
+		"DCIM/../Books":   "Books",
+		"../../etc":       "etc",
+	} {
+		if got := remoteName(in); got != want {
+			t.Errorf("remoteName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFSPushPull(t *testing.T) {
+	afc, fm := newTestAFC(t)
+	afc.WriteFile("DCIM/100APPLE/IMG_0001.JPG", []byte("one"))
+	afc.WriteFile("DCIM/100APPLE/IMG_0002.JPG", []byte("two"))
+	afc.WriteFile("DCIM/100APPLE/IMG_0002.MOV", []byte("movie"))
+
+	names, err := expandRemote(fm.FS(), []string{"/DCIM/*/*.JPG"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"DCIM/100APPLE/IMG_0001.JPG", "DCIM/100APPLE/IMG_0002.JPG"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("expandRemote = %v, want %v", names, want)
+	}
+
+	local := t.TempDir()
+	src := filepath.Join(local, "docs")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "a.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := planPush(fm.FS(), []string{src}, "/Downloads", false); err == nil {
+		t.Fatal("expected an error pushing a directory without -r")
+	}
+	if _, err := fm.MakeDir("/Downloads"); err != nil {
+		t.Fatal(err)
+	}
+	items, err := planPush(fm.FS(), []string{src}, "/Downloads", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pushed int
+	if err := runPush(fm, items, func(name string, n int) { pushed += n }); err != nil {
+		t.Fatal(err)
+	}
+	if pushed != 10 {
+		t.Fatalf("progress counted %d bytes, want 10", pushed)
+	}
+	if data, _ := afc.ReadFile("Downloads/docs/sub/b.txt"); string(data) != "world" {
+		t.Fatalf("pushed b.txt = %q", data)
+	}
+	if got, _ := afc.ModTime("Downloads/docs/a.txt"); !got.Equal(mtime) {
+		t.Fatalf("pushed mtime = %v, want %v", got, mtime)
+	}
+
+	// An interrupted push left part of a.txt.
+	afc.WriteFile("Downloads/docs/a.txt", []byte("hel"))
+	pushed = 0
+	if err := runPush(fm, items, func(name string, n int) { pushed += n }, idevice.WithResume()); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := afc.ReadFile("Downloads/docs/a.txt"); string(data) != "hello" {
+		t.Fatalf("resumed a.txt = %q", data)
+	}
+
+	dst := filepath.Join(local, "pulled")
+	items, err = planPull(fm.FS(), []string{"Downloads/docs"}, dst, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runPull(fm.FS(), items, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(dst, "sub", "b.txt")); string(data) != "world" {
+		t.Fatalf("pulled b.txt = %q", data)
+	}
+	fi, err := os.Stat(filepath.Join(dst, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("pulled mtime = %v, want %v", fi.ModTime(), mtime)
+	}
+	if err := verifyItems(fm, items, true); err != nil {
+		t.Fatal(err)
+	}
+	afc.WriteFile("Downloads/docs/sub/b.txt", []byte("World"))
+	if err := verifyItems(fm, items, true); !errors.Is(err, idevice.ErrChecksumMismatch) {
+		t.Fatalf("verifyItems of a changed file = %v, want ErrChecksumMismatch", err)
+	}
+
+	// Several sources need an existing directory to go into.
+	if _, err := planPull(fm.FS(), names, filepath.Join(local, "missing"), false); err == nil {
+		t.Fatal("expected an error pulling several files to a missing directory")
+	}
+}
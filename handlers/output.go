@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+
+	"golang.org/x/xerrors"
+	"howett.net/plist"
+)
+
+// Output formats selected with --output. Machine readable output uses the Go
+// field names as keys, so JSON and plist documents share one schema.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputPlist = "plist"
+)
+
+// outputFormat returns the validated --output value.
+func outputFormat() (string, error) {
+	switch f := strings.ToLower(globalOpts.output); f {
+	case "", outputTable:
+		return outputTable, nil
+	case outputJSON, outputPlist:
+		return f, nil
+	}
+
+	return "", xerrors.New(i18n.T("err.output_format", globalOpts.output))
+}
+
+// streamFormat is outputFormat for commands that print an open ended stream
+// of records. Streams are written as JSON lines, which has no plist
+// equivalent.
+func streamFormat() (string, error) {
+	f, err := outputFormat()
+	if err != nil {
+		return "", err
+	}
+	if f == outputPlist {
+		return "", xerrors.New(i18n.T("err.stream_format"))
+	}
+
+	return f, nil
+}
+
+// writeOutput writes v to stdout as one JSON or plist document.
+func writeOutput(format string, v interface{}) error {
+	if format == outputPlist {
+		bs, err := plist.MarshalIndent(v, plist.XMLFormat, "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(bs))
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeRecord writes v as a single JSON line.
+func writeRecord(v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Println(string(bs))
+	return err
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+
+	"github.com/gookit/gcli/v3"
+	"golang.org/x/xerrors"
+)
+
+var pairOpts = struct {
+	timeout int
+}{}
+
+var PairCommand = &gcli.Command{
+	Name: "pair",
+	Desc: i18n.T("cmd.pair.desc"),
+	Config: func(c *gcli.Command) {
+		c.IntOpt(&pairOpts.timeout, "timeout", "t", 120, i18n.T("cmd.pair.opt.timeout"))
+	},
+	Examples: `{$binName} {$cmd}
+{$binName} {$cmd} validate
+{$binName} {$cmd} unpair`,
+	Subs: []*gcli.Command{
+		PairValidateCommand,
+		PairUnpairCommand,
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		device, err := getDevice()
+		if err != nil {
+			return err
+		}
+
+		var last error
+		cert, err := idevice.PairDevice(device, time.Duration(pairOpts.timeout)*time.Second, func(err error) {
+			if last != nil && errors.Is(err, last) {
+				return
+			}
+			last = err
+
+			switch {
+			case errors.Is(err, idevice.ErrPasswordProtected):
+				fmt.Println(i18n.T("msg.unlock_device"))
+			case errors.Is(err, idevice.ErrPairingDialogResponsePending):
+				fmt.Println(i18n.T("msg.tap_trust"))
+			}
+		})
+		if err != nil {
+			if errors.Is(err, idevice.ErrUserDeniedPairing) {
+				return xerrors.New(i18n.T("err.pair_denied"))
+			}
+			return wrapErr(err, "err.pair")
+		}
+
+		fmt.Println(i18n.T("msg.paired", device.Properties.SerialNumber, cert.HostID))
+
+		return nil
+	},
+}
+
+var PairValidateCommand = &gcli.Command{
+	Name: "validate",
+	Desc: i18n.T("cmd.pair.validate.desc"),
+	Func: func(c *gcli.Command, args []string) error {
+		device, err := getDevice()
+		if err != nil {
+			return err
+		}
+
+		if err := idevice.ValidatePair(device); err != nil {
+			return wrapErr(err, "err.pair_invalid")
+		}
+
+		fmt.Println(i18n.T("msg.pair_valid", device.Properties.SerialNumber))
+
+		return nil
+	},
+}
+
+var PairUnpairCommand = &gcli.Command{
+	Name: "unpair",
+	Desc: i18n.T("cmd.pair.unpair.desc"),
+	Func: func(c *gcli.Command, args []string) error {
+		device, err := getDevice()
+		if err != nil {
+			return err
+		}
+
+		if err := idevice.Unpair(device); err != nil {
+			return wrapErr(err, "err.unpair")
+		}
+
+		fmt.Println(i18n.T("msg.unpaired", device.Properties.SerialNumber))
+
+		return nil
+	},
+}
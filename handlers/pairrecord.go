@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+
+	"github.com/gookit/gcli/v3"
+	"golang.org/x/xerrors"
+	"howett.net/plist"
+)
+
+var PairRecordCommand = &gcli.Command{
+	Name:    "pairrecord",
+	Desc:    i18n.T("cmd.pairrecord.desc"),
+	Aliases: []string{"pr"},
+	Examples: `{$binName} {$cmd} show
+{$binName} {$cmd} export ./device.plist
+{$binName} -u 00008020-001A2B3C4D5E6F01 {$cmd} import ./device.plist
+{$binName} {$cmd} delete`,
+	Subs: []*gcli.Command{
+		PairRecordShowCommand,
+		PairRecordExportCommand,
+		PairRecordImportCommand,
+		PairRecordDeleteCommand,
+	},
+}
+
+// pairRecordInfo is the pairrecord show output.
+type pairRecordInfo struct {
+	UDID           string
+	HostID         string
+	SystemBUID     string
+	BUID           string
+	WiFiMACAddress string
+	HasPrivateKeys bool
+	HasEscrowBag   bool
+}
+
+var PairRecordShowCommand = &gcli.Command{
+	Name: "show",
+	Desc: i18n.T("cmd.pairrecord.show.desc"),
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
+		device, err := pairRecordDevice()
+		if err != nil {
+			return err
+		}
+
+		cert, err := idevice.GetCertificate(device.Properties.SerialNumber)
+		if err != nil {
+			return wrapErr(err, "err.read_pair_record")
+		}
+
+		buid, err := idevice.ReadBUID()
+		if err != nil {
+			return wrapErr(err, "err.read_buid")
+		}
+
+		// The private keys are only ever written by export.
+		if format != outputTable {
+			return writeOutput(format, pairRecordInfo{
+				UDID:           device.Properties.SerialNumber,
+				HostID:         cert.HostID,
+				SystemBUID:     cert.SystemBUID,
+				BUID:           buid,
+				WiFiMACAddress: cert.WiFiMACAddress,
+				HasPrivateKeys: len(cert.HostPrivateKey) > 0 && len(cert.RootPrivateKey) > 0,
+				HasEscrowBag:   len(cert.EscrowBag) > 0,
+			})
+		}
+
+		w := new(tabwriter.Writer)
+		w.Init(os.Stdout, 0, 0, 1, ' ', 0)
+
+		_, _ = fmt.Fprintln(w, "- UDID\t: "+device.Properties.SerialNumber)
+		_, _ = fmt.Fprintln(w, "- HostID\t: "+cert.HostID)
+		systemBUID := cert.SystemBUID
+		if systemBUID != buid {
+			systemBUID += i18n.T("msg.buid_mismatch", buid)
+		}
+		_, _ = fmt.Fprintln(w, "- SystemBUID\t: "+systemBUID)
+		_, _ = fmt.Fprintln(w, "- WiFiMACAddress\t: "+cert.WiFiMACAddress)
+		_, _ = fmt.Fprintln(w, "- RootCertificate\t: "+describeCertificate(cert.RootCertificate))
+		_, _ = fmt.Fprintln(w, "- HostCertificate\t: "+describeCertificate(cert.HostCertificate))
+		_, _ = fmt.Fprintln(w, "- DeviceCertificate\t: "+describeCertificate(cert.DeviceCertificate))
+		_, _ = fmt.Fprintln(w, "- RootPrivateKey\t: "+describeData(cert.RootPrivateKey))
+		_, _ = fmt.Fprintln(w, "- HostPrivateKey\t: "+describeData(cert.HostPrivateKey))
+		_, _ = fmt.Fprintln(w, "- EscrowBag\t: "+describeData(cert.EscrowBag))
+
+		_ = w.Flush()
+
+		return nil
+	},
+}
+
+var PairRecordExportCommand = &gcli.Command{
+	Name: "export",
+	Desc: i18n.T("cmd.pairrecord.export.desc"),
+	Config: func(c *gcli.Command) {
+		c.AddArg("file", i18n.T("cmd.pairrecord.export.arg.file"), false)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		device, err := pairRecordDevice()
+		if err != nil {
+			return err
+		}
+
+		udid := device.Properties.SerialNumber
+		cert, err := idevice.GetCertificate(udid)
+		if err != nil {
+			return wrapErr(err, "err.read_pair_record")
+		}
+
+		data, err := plist.MarshalIndent(cert, plist.XMLFormat, "\t")
+		if err != nil {
+			return wrapErr(err, "err.encode_pair_record")
+		}
+
+		name := udid + ".plist"
+		if len(args) > 0 && args[0] != "" {
+			name = args[0]
+		}
+
+		// The record holds the host private keys.
+		if err := ioutil.WriteFile(name, data, 0600); err != nil {
+			return wrapErr(err, "err.write_file")
+		}
+
+		fmt.Println(i18n.T("msg.exported", name))
+
+		return nil
+	},
+}
+
+var PairRecordImportCommand = &gcli.Command{
+	Name: "import",
+	Desc: i18n.T("cmd.pairrecord.import.desc"),
+	Config: func(c *gcli.Command) {
+		c.AddArg("file", i18n.T("cmd.pairrecord.import.arg.file"), true)
+	},
+	Func: func(c *gcli.Command, args []string) error {
+		device, err := pairRecordDevice()
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return wrapErr(err, "err.read_file")
+		}
+
+		var cert idevice.Certificate
+		if _, err := plist.Unmarshal(data, &cert); err != nil {
+			return wrapErr(err, "err.parse_pair_record")
+		}
+		if cert.HostID == "" {
+			return xerrors.New(i18n.T("err.missing_host_id"))
+		}
+
+		if err := idevice.SavePairRecord(device, &cert); err != nil {
+			return wrapErr(err, "err.save_pair_record")
+		}
+
+		fmt.Println(i18n.T("msg.imported", device.Properties.SerialNumber))
+
+		return nil
+	},
+}
+
+var PairRecordDeleteCommand = &gcli.Command{
+	Name: "delete",
+	Desc: i18n.T("cmd.pairrecord.delete.desc"),
+	Func: func(c *gcli.Command, args []string) error {
+		device, err := pairRecordDevice()
+		if err != nil {
+			return err
+		}
+
+		if err := idevice.DeletePairRecord(device.Properties.SerialNumber); err != nil {
+			return wrapErr(err, "err.delete_pair_record")
+		}
+
+		fmt.Println(i18n.T("msg.deleted", device.Properties.SerialNumber))
+
+		return nil
+	},
+}
+
+// pairRecordDevice returns the selected device. Pair records are keyed by
+// UDID, so with --udid the device doesn't need to be attached. Other errors,
+// such as usbmuxd not running, are returned.
+func pairRecordDevice() (*idevice.DeviceEntry, error) {
+	device, err := getDevice()
+	if xerrors.Is(err, idevice.ErrDeviceNotFound) && globalOpts.udid != "" {
+		return &idevice.DeviceEntry{
+			Properties: idevice.DeviceProperties{SerialNumber: globalOpts.udid},
+		}, nil
+	}
+
+	return device, err
+}
+
+func describeCertificate(data []byte) string {
+	if len(data) == 0 {
+		return "-"
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return describeData(data)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return describeData(data)
+	}
+
+	return i18n.T("fmt.valid_until", cert.NotAfter.Format("2006-01-02"))
+}
+
+func describeData(data []byte) string {
+	if len(data) == 0 {
+		return "-"
+	}
+
+	return i18n.T("fmt.bytes", len(data))
+}
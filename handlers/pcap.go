@@ -1,30 +1,27 @@
 package handlers
 
 import (
-	"context"
 	"encoding/hex"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 
-	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/i18n"
 
 	"github.com/gookit/gcli/v3"
 )
 
 var PcapCommand = &gcli.Command{
 	Name: "pcap",
-	Desc: "网络抓包",
+	Desc: i18n.T("cmd.pcap.desc"),
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "PCAP文件保存路径", true)
-		c.AddArg("arg1", "进程名称")
+		c.AddArg("arg0", i18n.T("cmd.pcap.arg.file"), true)
+		c.AddArg("arg1", i18n.T("cmd.pcap.arg.proc"))
 	},
 	Func: func(c *gcli.Command, args []string) error {
-		ctx, cancel := context.WithCancel(context.Background())
+		ctx, cancel := interruptContext()
 		defer cancel()
 
-		device, err := idevice.GetDevice()
+		device, err := openDevice()
 		if err != nil {
 			return err
 		}
@@ -42,20 +39,11 @@ var PcapCommand = &gcli.Command{
 			procName = args[1]
 		}
 
-		go func() {
-			if err := idevice.StartPcapService(ctx, device, procName, f, func(bs []byte) {
-				fmt.Println(hex.Dump(bs))
-			}); err != nil {
-				fmt.Println("抓包错误：", err)
-				os.Exit(-1)
-			}
-		}()
-
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGKILL)
-		<-quit
-
-		cancel()
+		if err := device.Pcap(ctx, procName, f, func(bs []byte) {
+			fmt.Println(hex.Dump(bs))
+		}); err != nil {
+			return wrapErr(err, "err.pcap")
+		}
 
 		return nil
 	},
@@ -4,34 +4,68 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"strconv"
 	"text/tabwriter"
 
+	"github.com/gofmt/iOSBox/pkg/i18n"
+
 	"github.com/gookit/gcli/v3"
 )
 
+// processInfo is one entry of the procs output.
+type processInfo struct {
+	PID  int
+	Name string
+}
+
+// parseProcesses parses the output of `ps -eec`: PID TTY TIME CMD.
+func parseProcesses(out []byte) []processInfo {
+	lines := bytes.Split(out, []byte("\n"))
+	procs := make([]processInfo, 0, len(lines))
+	for _, line := range lines[1:] {
+		fields := bytes.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(string(fields[0]))
+		if err != nil {
+			continue
+		}
+		procs = append(procs, processInfo{
+			PID:  pid,
+			Name: string(bytes.Join(fields[3:], []byte(" "))),
+		})
+	}
+
+	return procs
+}
+
 var ProcessListCommand = &gcli.Command{
 	Name:    "procs",
-	Desc:    "显示当前设备进程列表",
+	Desc:    i18n.T("cmd.procs.desc"),
 	Aliases: []string{"ps"},
 	Func: func(c *gcli.Command, args []string) error {
+		format, err := outputFormat()
+		if err != nil {
+			return err
+		}
+
 		result, err := shellRun("ps -eec")
 		if err != nil {
 			return err
 		}
 
-		if len(result) == 0 {
-			return nil
+		procs := parseProcesses(result)
+		if format != outputTable {
+			return writeOutput(format, procs)
 		}
 
-		results := bytes.Split(result, []byte("\n"))
 		w := new(tabwriter.Writer)
 		w.Init(os.Stdout, 0, 0, 4, ' ', 0)
 
-		for _, res := range results[1:] {
-			ss := bytes.Fields(res)
-			if len(ss) > 0 {
-				_, _ = fmt.Fprintln(w, string(ss[0])+"\t"+string(ss[3]))
-			}
+		for _, p := range procs {
+			_, _ = fmt.Fprintf(w, "%d\t%s\n", p.PID, p.Name)
 		}
 
 		_ = w.Flush()
@@ -78,11 +112,11 @@ var ProcessListCommand = &gcli.Command{
 
 var ProcessKillCommand = &gcli.Command{
 	Name:     "kill",
-	Desc:     "结束进程",
+	Desc:     i18n.T("cmd.kill.desc"),
 	Aliases:  []string{"k"},
 	Examples: "{$binName} {$cmd} SpringBoard",
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "PID或进程名", true)
+		c.AddArg("arg0", i18n.T("cmd.kill.arg.proc"), true)
 	},
 	Func: func(c *gcli.Command, args []string) error {
 		result, err := shellRun("ps -eec")
@@ -90,19 +124,9 @@ var ProcessKillCommand = &gcli.Command{
 			return err
 		}
 
-		results := bytes.Split(result, []byte("\n"))
-		if len(results) == 0 {
-			return nil
-		}
-
-		for _, line := range results[1:] {
-			fields := bytes.Fields(line)
-			if len(fields) == 0 {
-				continue
-			}
-
-			if string(fields[0]) == args[0] || string(fields[3]) == args[0] {
-				_, _ = shellRun("kill " + string(fields[0]))
+		for _, p := range parseProcesses(result) {
+			if strconv.Itoa(p.PID) == args[0] || p.Name == args[0] {
+				_, _ = shellRun("kill " + strconv.Itoa(p.PID))
 				break
 			}
 		}
@@ -113,7 +137,7 @@ var ProcessKillCommand = &gcli.Command{
 
 var ReSpringBoardCommand = &gcli.Command{
 	Name: "respring",
-	Desc: "重启 SpringBoard",
+	Desc: i18n.T("cmd.respring.desc"),
 	Func: func(c *gcli.Command, args []string) error {
 		return c.App().Exec("kill", []string{"SpringBoard"})
 	},
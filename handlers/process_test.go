@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProcesses(t *testing.T) {
+	out := []byte(`  PID TTY           TIME CMD
+    1 ??         1:02.03 launchd
+   57 ??         0:10.00 SpringBoard
+  812 ??         0:00.41 Web Content
+`)
+
+	want := []processInfo{
+		{PID: 1, Name: "launchd"},
+		{PID: 57, Name: "SpringBoard"},
+		{PID: 812, Name: "Web Content"},
+	}
+	if got := parseProcesses(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseProcesses = %+v, want %+v", got, want)
+	}
+}
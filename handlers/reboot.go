@@ -1,22 +1,21 @@
 package handlers
 
 import (
-	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/i18n"
 
 	"github.com/gookit/gcli/v3"
-	"golang.org/x/xerrors"
 )
 
 var SystemRebootCommand = &gcli.Command{
 	Name: "reboot",
-	Desc: "重启当前设备，重启后需要重新越狱",
+	Desc: i18n.T("cmd.reboot.desc"),
 	Func: func(c *gcli.Command, args []string) error {
-		device, err := idevice.GetDevice()
+		device, err := openDevice()
 		if err != nil {
-			return xerrors.Errorf("连接iOS设备错误: %w", err)
+			return wrapErr(err, "err.connect_device")
 		}
 
-		conn, err := idevice.NewDiagnosticsService(device)
+		conn, err := device.Diagnostics()
 		if err != nil {
 			return err
 		}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -10,7 +11,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/i18n"
 
 	"github.com/gookit/gcli/v3"
 	"github.com/gookit/gcli/v3/progress"
@@ -21,41 +22,16 @@ import (
 
 var ShellCommand = &gcli.Command{
 	Name: "shell",
-	Desc: "创建SSH交互环境",
+	Desc: i18n.T("cmd.shell.desc"),
 	Func: func(c *gcli.Command, args []string) error {
-		localPort, err := GetAvailablePort()
+		cli, err := sshClient()
 		if err != nil {
-			return err
-		}
-
-		device, err := idevice.GetDevice()
-		if err != nil {
-			return err
-		}
-
-		service := idevice.NewForwardService(device)
-		err = service.Start(uint16(localPort), uint16(22), func(s string, err error) {
-			if err != nil {
-				fmt.Println(err)
-			}
-		})
-		if err != nil {
-			return err
-		}
-		defer service.Close()
-
-		deviceIp := fmt.Sprintf("127.0.0.1:%d", localPort)
-		cli, err := newSSHClient(deviceIp)
-		if err != nil {
-			return xerrors.Errorf("创建SSH客户端错误：%w", err)
+			return wrapErr(err, "err.ssh_client")
 		}
-		defer func(cli *ssh.Client) {
-			_ = cli.Close()
-		}(cli)
 
 		session, err := cli.NewSession()
 		if err != nil {
-			return xerrors.Errorf("获取SSH会话错误：%w", err)
+			return wrapErr(err, "err.ssh_session")
 		}
 		defer func(session *ssh.Session) {
 			_ = session.Close()
@@ -64,7 +40,7 @@ var ShellCommand = &gcli.Command{
 		fd := int(os.Stdin.Fd())
 		oldState, err := terminal.MakeRaw(fd)
 		if err != nil {
-			return xerrors.Errorf("创建SSH终端错误：%w", err)
+			return wrapErr(err, "err.ssh_terminal")
 		}
 		defer func(fd int, oldState *terminal.State) {
 			_ = terminal.Restore(fd, oldState)
@@ -76,7 +52,7 @@ var ShellCommand = &gcli.Command{
 
 		tWidth, tHeight, err := terminal.GetSize(fd)
 		if err != nil {
-			return xerrors.Errorf("获取SSH终端窗口大小错误：%w", err)
+			return wrapErr(err, "err.ssh_term_size")
 		}
 
 		modes := ssh.TerminalModes{
@@ -86,11 +62,11 @@ var ShellCommand = &gcli.Command{
 		}
 
 		if err := session.RequestPty("xterm-256color", tHeight, tWidth, modes); err != nil {
-			return xerrors.Errorf("请求SSH终端窗口错误：%w", err)
+			return wrapErr(err, "err.ssh_pty")
 		}
 
 		if err := session.Shell(); err != nil {
-			return xerrors.Errorf("启动SSH终端窗口错误：%w", err)
+			return wrapErr(err, "err.ssh_shell")
 		}
 
 		_ = session.Wait()
@@ -101,15 +77,15 @@ var ShellCommand = &gcli.Command{
 
 var RunCommand = &gcli.Command{
 	Name: "run",
-	Desc: "执行任何SHELL命令",
+	Desc: i18n.T("cmd.run.desc"),
 	Config: func(c *gcli.Command) {
-		c.AddArg("arrArg", "SHELL命令列表", true, true)
+		c.AddArg("arrArg", i18n.T("cmd.run.arg.cmd"), true, true)
 	},
 	Examples: "{$binName} {$cmd} ls -lah",
 	Func: func(c *gcli.Command, args []string) error {
 		result, err := shellRun(strings.Join(args, " "))
 		if err != nil {
-			return xerrors.Errorf("执行SHELL命令错误：%w", err)
+			return wrapErr(err, "err.run_shell")
 		}
 
 		c.Println(string(result))
@@ -120,10 +96,10 @@ var RunCommand = &gcli.Command{
 
 var LdrestartCommand = &gcli.Command{
 	Name: "redaemon",
-	Desc: "重启守护进程",
+	Desc: i18n.T("cmd.redaemon.desc"),
 	Func: func(c *gcli.Command, args []string) error {
 		if _, err := shellRun("/usr/bin/ldrestart"); err != nil {
-			return xerrors.Errorf("执行SHELL命令错误：%w", err)
+			return wrapErr(err, "err.run_shell")
 		}
 
 		return nil
@@ -132,10 +108,10 @@ var LdrestartCommand = &gcli.Command{
 
 var SCPCommand = &gcli.Command{
 	Name: "scp",
-	Desc: "通过SSH传递文件",
+	Desc: i18n.T("cmd.scp.desc"),
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "本地或远程文件路径", true)
-		c.AddArg("arg1", "本地或远程文件路径", true)
+		c.AddArg("arg0", i18n.T("cmd.scp.arg.path"), true)
+		c.AddArg("arg1", i18n.T("cmd.scp.arg.path"), true)
 	},
 	Examples: "{$binName} {$cmd} testdata/example.js :/tmp/example.js",
 	Func: func(c *gcli.Command, args []string) error {
@@ -163,39 +139,14 @@ func scp(args []string) error {
 	}
 
 	if len(remotePath) == 0 || len(localPath) == 0 {
-		return xerrors.New("SCP参数错误")
+		return xerrors.New(i18n.T("err.scp_args"))
 	}
 
-	localPort, err := GetAvailablePort()
+	cli, err := sshClient()
 	if err != nil {
-		return err
+		return wrapErr(err, "err.ssh_connect")
 	}
 
-	device, err := idevice.GetDevice()
-	if err != nil {
-		return err
-	}
-
-	service := idevice.NewForwardService(device)
-	err = service.Start(uint16(localPort), uint16(22), func(s string, err error) {
-		if err != nil {
-			fmt.Println(err)
-		}
-	})
-	if err != nil {
-		return err
-	}
-	defer service.Close()
-
-	deviceIp := fmt.Sprintf("127.0.0.1:%d", localPort)
-	cli, err := newSSHClient(deviceIp)
-	if err != nil {
-		return xerrors.Errorf("连接SSH错误：%w", err)
-	}
-	defer func(cli *ssh.Client) {
-		_ = cli.Close()
-	}(cli)
-
 	session, err := cli.NewSession()
 	if err != nil {
 		return err
@@ -210,11 +161,11 @@ func scp(args []string) error {
 				return nil
 			}
 
-			return xerrors.Errorf("SCP错误：%w", err)
+			return wrapErr(err, "err.scp")
 		}
 	} else {
 		if err := scpFrom(session, remotePath, localPath); err != nil {
-			return xerrors.Errorf("SCP错误：%w", err)
+			return wrapErr(err, "err.scp")
 		}
 	}
 
@@ -420,36 +371,10 @@ func scpFrom(session *ssh.Session, remote, local string) error {
 }
 
 func shellRun(cmd string) (result []byte, err error) {
-	localPort, err := GetAvailablePort()
-	if err != nil {
-		return
-	}
-
-	device, err := idevice.GetDevice()
-	if err != nil {
-		return
-	}
-
-	service := idevice.NewForwardService(device)
-	err = service.Start(uint16(localPort), uint16(22), func(s string, err error) {
-		if err != nil {
-			fmt.Println(err)
-		}
-	})
+	cli, err := sshClient()
 	if err != nil {
 		return
 	}
-	defer service.Close()
-
-	deviceIp := fmt.Sprintf("127.0.0.1:%d", localPort)
-
-	cli, err := newSSHClient(deviceIp)
-	if err != nil {
-		return
-	}
-	defer func(cli *ssh.Client) {
-		_ = cli.Close()
-	}(cli)
 
 	session, err := cli.NewSession()
 	if err != nil {
@@ -469,8 +394,18 @@ func shellRun(cmd string) (result []byte, err error) {
 	return buf.Bytes(), nil
 }
 
-func newSSHClient(deviceIp string) (*ssh.Client, error) {
-	cfg := ssh.ClientConfig{
+// sshClient returns the SSH connection to the device's sshd on port 22,
+// shared by every command of the run.
+func sshClient() (*ssh.Client, error) {
+	device, err := openDevice()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return device.SSHContext(ctx, 22, &ssh.ClientConfig{
 		User: "root",
 		Auth: []ssh.AuthMethod{
 			ssh.Password("alpine"),
@@ -478,10 +413,7 @@ func newSSHClient(deviceIp string) (*ssh.Client, error) {
 		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
 			return nil
 		},
-		Timeout: 10 * time.Second,
-	}
-
-	return ssh.Dial("tcp", deviceIp, &cfg)
+	})
 }
 
 func GetAvailablePort() (int, error) {
@@ -2,33 +2,38 @@ package handlers
 
 import (
 	"fmt"
-	"os"
-	"os/signal"
 	"strings"
 	"time"
 
-	"github.com/gofmt/iOSBox/pkg/idevice"
+	"github.com/gofmt/iOSBox/pkg/i18n"
 
 	"github.com/gookit/color"
 	"github.com/gookit/gcli/v3"
-	"golang.org/x/xerrors"
 )
 
 var SystemLogCommand = &gcli.Command{
 	Name: "syslog",
-	Desc: "打印系统日志",
+	Desc: i18n.T("cmd.syslog.desc"),
 	Config: func(c *gcli.Command) {
-		c.AddArg("arg0", "日志过滤字符串，支持过滤进程名或模块名")
+		c.AddArg("arg0", i18n.T("cmd.syslog.arg.filter"))
 	},
 	Func: func(c *gcli.Command, args []string) error {
-		device, err := idevice.GetDevice()
+		format, err := streamFormat()
 		if err != nil {
-			return xerrors.Errorf("连接iOS设备错误: %w", err)
+			return err
 		}
 
-		conn, err := idevice.NewSyslogService(device)
+		device, err := openDevice()
 		if err != nil {
-			return xerrors.Errorf("连接服务错误: %w", err)
+			return wrapErr(err, "err.connect_device")
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		conn, err := device.SyslogContext(ctx)
+		if err != nil {
+			return wrapErr(err, "err.connect_service")
 		}
 		defer conn.Close()
 
@@ -42,57 +47,58 @@ var SystemLogCommand = &gcli.Command{
 		fgMagenta := color.FgMagenta.Render
 		white := color.White.Render
 
-		go func() {
-			for {
-				// Jun  3 18:45:44 iPhone wifid(WiFiPolicy)[51] <Notice>: Copy current network requested by "WirelessRadioMan"
-				msg, err := conn.GetSyslog()
-				if err != nil {
-					fmt.Println("读取系统日志错误：", err)
-					return
-				}
-
-				t, err := time.Parse(time.Stamp, msg.Time)
-				if err != nil {
-					panic(err)
+		for {
+			// Jun  3 18:45:44 iPhone wifid(WiFiPolicy)[51] <Notice>: Copy current network requested by "WirelessRadioMan"
+			msg, err := conn.GetSyslogContext(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
 				}
+				return wrapErr(err, "err.read_syslog")
+			}
 
-				// (kernel(AppleProxDriver)[0]) (进程(模块)[行号])
-				if len(args) > 0 && !strings.Contains(msg.ProcInfo, args[0]) {
-					continue
-				}
+			// (kernel(AppleProxDriver)[0]) (进程(模块)[行号])
+			if len(args) > 0 && !strings.Contains(msg.ProcInfo, args[0]) {
+				continue
+			}
 
-				level := msg.Level
-				body := msg.Body
-				switch msg.Level {
-				case "Notice":
-					level = fgGreen(level)
-				case "Error":
-					level = fgRed(level)
-					body = fgLiRed(body)
-				case "Warning":
-					level = fgYellow(level)
-					body = fgLiYellow(body)
-				case "Debug":
-					level = fgMagenta(level)
-				default:
-					level = white(level)
+			if format == outputJSON {
+				if err := writeRecord(msg); err != nil {
+					return err
 				}
+				continue
+			}
 
-				fmt.Printf(
-					"[%s](%s)[%s]: %s\n",
-					fgWhite(t.Format("01-02 15:04:05")),
-					// gray(msg.DeviceName),
-					fgCyan(msg.ProcInfo),
-					level,
-					body,
-				)
+			t, err := time.Parse(time.Stamp, msg.Time)
+			if err != nil {
+				panic(err)
 			}
-		}()
 
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, os.Interrupt)
-		<-quit
+			level := msg.Level
+			body := msg.Body
+			switch msg.Level {
+			case "Notice":
+				level = fgGreen(level)
+			case "Error":
+				level = fgRed(level)
+				body = fgLiRed(body)
+			case "Warning":
+				level = fgYellow(level)
+				body = fgLiYellow(body)
+			case "Debug":
+				level = fgMagenta(level)
+			default:
+				level = white(level)
+			}
 
-		return nil
+			fmt.Printf(
+				"[%s](%s)[%s]: %s\n",
+				fgWhite(t.Format("01-02 15:04:05")),
+				// gray(msg.DeviceName),
+				fgCyan(msg.ProcInfo),
+				level,
+				body,
+			)
+		}
 	},
 }
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/i18n"
+	"github.com/gofmt/iOSBox/pkg/idevice"
+
+	"github.com/gookit/color"
+	"github.com/gookit/gcli/v3"
+	"golang.org/x/xerrors"
+)
+
+// deviceEventRecord is one line of the watch JSON output.
+type deviceEventRecord struct {
+	Time           time.Time
+	Type           string
+	DeviceID       int
+	UDID           string
+	ConnectionType string
+}
+
+var WatchCommand = &gcli.Command{
+	Name:    "watch",
+	Desc:    i18n.T("cmd.watch.desc"),
+	Aliases: []string{"w"},
+	Func: func(c *gcli.Command, args []string) error {
+		format, err := streamFormat()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		events, err := idevice.Listen(ctx)
+		if err != nil {
+			return wrapErr(err, "err.listen")
+		}
+
+		for ev := range events {
+			if format == outputJSON {
+				if err := writeRecord(deviceEventRecord{
+					Time:           time.Now(),
+					Type:           string(ev.Type),
+					DeviceID:       ev.DeviceID,
+					UDID:           ev.Properties.SerialNumber,
+					ConnectionType: ev.Properties.ConnectionType,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var state string
+			switch ev.Type {
+			case idevice.DeviceAttached:
+				state = color.FgGreen.Render(i18n.T("msg.attached"))
+			case idevice.DeviceDetached:
+				state = color.FgRed.Render(i18n.T("msg.detached"))
+			case idevice.DevicePaired:
+				state = color.FgCyan.Render(i18n.T("msg.paired_event"))
+			}
+
+			fmt.Printf("[%s] %s %s (ID: %d, %s)\n",
+				time.Now().Format("15:04:05"),
+				state,
+				ev.Properties.SerialNumber,
+				ev.DeviceID,
+				ev.Properties.ConnectionType,
+			)
+		}
+
+		if ctx.Err() == nil {
+			return xerrors.New(i18n.T("err.usbmuxd_closed"))
+		}
+
+		return nil
+	},
+}
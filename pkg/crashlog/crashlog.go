@@ -0,0 +1,167 @@
+// Package crashlog parses iOS crash reports and symbolicates them against
+// local dSYMs, without a Mac.
+//
+// Both formats written by iOS are understood: the JSON .ips reports of iOS 15
+// and later, and the text format of older .crash files, which iOS 14 and
+// earlier also wrap in .ips files behind a one line JSON header.
+//
+//	report, err := crashlog.ParseFile("MyApp-2023-05-01-101010.ips")
+//	...
+//	sym := crashlog.NewSymbolicator()
+//	defer sym.Close()
+//	if err := sym.Add("MyApp.app.dSYM"); err != nil {
+//		...
+//	}
+//	sym.Symbolicate(report)
+//	report.WriteText(os.Stdout)
+package crashlog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Report is a parsed crash report.
+type Report struct {
+	// BugType is the report kind from the .ips header, such as "309" for a
+	// crash. Legacy .crash files have none.
+	BugType    string
+	Process    string
+	PID        int
+	Identifier string
+	// Version is the app version, written as "short (build)".
+	Version   string
+	CodeType  string
+	OSVersion string
+	// Time is the time of the crash as written in the report.
+	Time             string
+	ExceptionType    string
+	Signal           string
+	ExceptionSubtype string
+	ExceptionCodes   string
+	// CrashedThread is the index of the thread that crashed, or -1.
+	CrashedThread int
+	Threads       []Thread
+	Images        []Image
+}
+
+// Thread is the backtrace of one thread.
+type Thread struct {
+	Index   int
+	Name    string
+	Queue   string
+	Crashed bool
+	Frames  []Frame
+}
+
+// Frame is one return address of a backtrace.
+type Frame struct {
+	// ImageIndex is the index in Report.Images of the binary the address
+	// belongs to, or -1 when it is unknown.
+	ImageIndex int
+	Address    uint64
+	// ImageOffset is Address relative to the load address of the image.
+	ImageOffset uint64
+	// Symbol and SymbolOffset name the function containing the address,
+	// when the report or a dSYM knows it.
+	Symbol       string
+	SymbolOffset uint64
+	// File and Line are the source location, when a dSYM knows it.
+	File string
+	Line int
+}
+
+// Image is a binary loaded in the crashed process.
+type Image struct {
+	Name string
+	Path string
+	// UUID is the LC_UUID of the binary in the uppercase form dwarfdump
+	// prints, such as "A5A149A9-13E6-3382-A1FC-57B22E8533F7". It is empty
+	// when the report does not say.
+	UUID string
+	Arch string
+	Base uint64
+	Size uint64
+}
+
+// ParseFile parses the crash report in the file name.
+func ParseFile(name string) (*Report, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+// Parse parses a .ips or .crash report.
+func Parse(data []byte) (*Report, error) {
+	data = bytes.TrimLeft(data, "\ufeff \t\r\n")
+	r := &Report{CrashedThread: -1}
+
+	// .ips files start with a one line JSON header, followed by either a
+	// JSON body or, before iOS 15, a legacy text report.
+	if len(data) > 0 && data[0] == '{' {
+		header := data
+		body := []byte(nil)
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			header, body = data[:i], bytes.TrimSpace(data[i+1:])
+		}
+		if err := parseIPSHeader(r, header); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(body) == 0:
+			return r, nil
+		case body[0] == '{':
+			if err := parseIPSBody(r, body); err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+		data = body
+	}
+
+	if err := parseLegacy(r, data); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Image returns the image of f, or nil when it is unknown.
+func (r *Report) Image(f Frame) *Image {
+	if f.ImageIndex < 0 || f.ImageIndex >= len(r.Images) {
+		return nil
+	}
+
+	return &r.Images[f.ImageIndex]
+}
+
+// normalizeUUID turns the UUID spellings found in reports, with or without
+// dashes and angle brackets, into the form dwarfdump prints. It returns ""
+// for anything that is not a UUID.
+func normalizeUUID(s string) string {
+	s = strings.Trim(s, "<> ")
+	s = strings.ReplaceAll(s, "-", "")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 16 {
+		return ""
+	}
+
+	return formatUUID(b)
+}
+
+func formatUUID(b []byte) string {
+	s := strings.ToUpper(hex.EncodeToString(b))
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// ErrNotCrashReport is returned by Parse for data that is neither a .ips nor
+// a .crash report.
+var ErrNotCrashReport = xerrors.New("crashlog: not a crash report")
@@ -0,0 +1,115 @@
+package crashlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParse_IPS(t *testing.T) {
+	r, err := ParseFile("testdata/MyApp-2023-05-01-101010.ips")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.BugType != "309" || r.Process != "MyApp" || r.PID != 1234 || r.Identifier != "com.example.myapp" ||
+		r.Version != "1.2 (34)" || r.OSVersion != "iPhone OS 16.4 (20E247)" || r.CodeType != "ARM-64" {
+		t.Fatalf("header = %+v", r)
+	}
+	if r.ExceptionType != "EXC_BAD_ACCESS" || r.Signal != "SIGSEGV" || r.CrashedThread != 0 {
+		t.Fatalf("exception = %s %s, crashed thread %d", r.ExceptionType, r.Signal, r.CrashedThread)
+	}
+	if len(r.Images) != 3 || r.Images[0].UUID != "A5A149A9-13E6-3382-A1FC-57B22E8533F7" || r.Images[0].Base != 0x100000000 {
+		t.Fatalf("images = %+v", r.Images)
+	}
+	if len(r.Threads) != 2 || !r.Threads[0].Crashed || r.Threads[0].Queue != "com.apple.main-thread" || r.Threads[1].Name != "com.example.worker" {
+		t.Fatalf("threads = %+v", r.Threads)
+	}
+
+	f := r.Threads[0].Frames[1]
+	if f.ImageIndex != 0 || f.Address != 0x100004024 || f.ImageOffset != 0x4024 || f.Symbol != "main" || f.SymbolOffset != 36 {
+		t.Fatalf("frame = %+v", f)
+	}
+}
+
+func TestParse_Legacy(t *testing.T) {
+	for _, name := range []string{
+		"testdata/MyApp-2019-08-12-101010.crash",
+		// iOS 14 wraps the text format in a .ips file.
+		"testdata/MyApp-2020-11-02-090000.ips",
+	} {
+		r, err := ParseFile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if r.Process != "MyApp" || r.PID != 987 || r.Version != "34 (1.2)" || r.OSVersion != "iPhone OS 12.4 (16G77)" ||
+			r.ExceptionType != "EXC_BAD_ACCESS" || r.Signal != "SIGSEGV" || r.CrashedThread != 0 {
+			t.Fatalf("%s: header = %+v", name, r)
+		}
+		if len(r.Images) != 4 || r.Images[0].UUID != "A5A149A9-13E6-3382-A1FC-57B22E8533F7" || r.Images[0].Size != 0x100000 {
+			t.Fatalf("%s: images = %+v", name, r.Images)
+		}
+		if len(r.Threads) != 2 || r.Threads[0].Queue != "com.apple.main-thread" || r.Threads[1].Name != "com.example.worker" || r.Threads[1].Crashed {
+			t.Fatalf("%s: threads = %+v", name, r.Threads)
+		}
+
+		frames := r.Threads[0].Frames
+		if len(frames) != 3 {
+			t.Fatalf("%s: frames = %+v", name, frames)
+		}
+		if f := frames[0]; f.ImageIndex != 0 || f.ImageOffset != 16384 || f.Symbol != "" {
+			t.Fatalf("%s: frame 0 = %+v", name, f)
+		}
+		if f := frames[1]; f.Symbol != "main" || f.SymbolOffset != 36 || f.File != "main.m" || f.Line != 14 || f.ImageOffset != 0x4024 {
+			t.Fatalf("%s: frame 1 = %+v", name, f)
+		}
+	}
+}
+
+func TestParse_NotCrashReport(t *testing.T) {
+	if _, err := Parse([]byte("hello\nworld\n")); err != ErrNotCrashReport {
+		t.Fatalf("Parse error = %v, want ErrNotCrashReport", err)
+	}
+	if _, err := Parse([]byte("{not json")); err == nil {
+		t.Fatal("Parse of a broken .ips header succeeded")
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	r, err := ParseFile("testdata/MyApp-2023-05-01-101010.ips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Threads[0].Frames[0].Symbol = "-[ViewController crash]"
+	r.Threads[0].Frames[0].SymbolOffset = 12
+	r.Threads[0].Frames[0].File = "/src/MyApp/ViewController.m"
+	r.Threads[0].Frames[0].Line = 42
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Process:            MyApp [1234]\n",
+		"Exception Type:     EXC_BAD_ACCESS (SIGSEGV)\n",
+		"Thread 0 name:  Dispatch queue: com.apple.main-thread\nThread 0 Crashed:\n",
+		"0x0000000100004000 -[ViewController crash] + 12 (ViewController.m:42)\n",
+		"0x0000000100004024 main + 36\n",
+		"Thread 1 name:  com.example.worker\nThread 1:\n",
+		"0x100000000 - 0x1000fffff MyApp arm64  <A5A149A9-13E6-3382-A1FC-57B22E8533F7>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteText output lacks %q:\n%s", want, out)
+		}
+	}
+
+	// Frames without a symbol keep the image relative form.
+	r.Threads[0].Frames[0].Symbol = ""
+	buf.Reset()
+	_ = r.WriteText(&buf)
+	if !strings.Contains(buf.String(), "0x0000000100004000 0x100000000 + 16384\n") {
+		t.Errorf("unsymbolicated frame missing:\n%s", buf.String())
+	}
+}
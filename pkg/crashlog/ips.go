@@ -0,0 +1,165 @@
+package crashlog
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/xerrors"
+)
+
+// ipsHeader is the first line of a .ips file.
+type ipsHeader struct {
+	AppName      string `json:"app_name"`
+	AppVersion   string `json:"app_version"`
+	BuildVersion string `json:"build_version"`
+	BundleID     string `json:"bundleID"`
+	BugType      string `json:"bug_type"`
+	OSVersion    string `json:"os_version"`
+	Timestamp    string `json:"timestamp"`
+	Name         string `json:"name"`
+}
+
+// ipsBody is the part of an iOS 15 crash report body that Report covers.
+type ipsBody struct {
+	ProcName    string `json:"procName"`
+	PID         int    `json:"pid"`
+	CPUType     string `json:"cpuType"`
+	CaptureTime string `json:"captureTime"`
+	BundleInfo  struct {
+		CFBundleIdentifier         string
+		CFBundleShortVersionString string
+		CFBundleVersion            string
+	} `json:"bundleInfo"`
+	OSVersion struct {
+		Train string `json:"train"`
+		Build string `json:"build"`
+	} `json:"osVersion"`
+	Exception struct {
+		Type    string `json:"type"`
+		Signal  string `json:"signal"`
+		Subtype string `json:"subtype"`
+		Codes   string `json:"codes"`
+	} `json:"exception"`
+	FaultingThread *int `json:"faultingThread"`
+	Threads        []struct {
+		Name      string `json:"name"`
+		Queue     string `json:"queue"`
+		Triggered bool   `json:"triggered"`
+		Frames    []struct {
+			ImageIndex     int    `json:"imageIndex"`
+			ImageOffset    uint64 `json:"imageOffset"`
+			Symbol         string `json:"symbol"`
+			SymbolLocation uint64 `json:"symbolLocation"`
+			SourceFile     string `json:"sourceFile"`
+			SourceLine     int    `json:"sourceLine"`
+		} `json:"frames"`
+	} `json:"threads"`
+	UsedImages []struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+		UUID string `json:"uuid"`
+		Arch string `json:"arch"`
+		Base uint64 `json:"base"`
+		Size uint64 `json:"size"`
+	} `json:"usedImages"`
+}
+
+func parseIPSHeader(r *Report, data []byte) error {
+	var h ipsHeader
+	if err := json.Unmarshal(data, &h); err != nil {
+		return xerrors.Errorf("crashlog: parsing .ips header: %w", err)
+	}
+
+	r.BugType = h.BugType
+	r.Process = h.AppName
+	if r.Process == "" {
+		r.Process = h.Name
+	}
+	r.Identifier = h.BundleID
+	r.Version = version(h.AppVersion, h.BuildVersion)
+	r.OSVersion = h.OSVersion
+	r.Time = h.Timestamp
+
+	return nil
+}
+
+func parseIPSBody(r *Report, data []byte) error {
+	var b ipsBody
+	if err := json.Unmarshal(data, &b); err != nil {
+		return xerrors.Errorf("crashlog: parsing .ips body: %w", err)
+	}
+
+	if b.ProcName != "" {
+		r.Process = b.ProcName
+	}
+	r.PID = b.PID
+	r.CodeType = b.CPUType
+	if b.CaptureTime != "" {
+		r.Time = b.CaptureTime
+	}
+	if b.BundleInfo.CFBundleIdentifier != "" {
+		r.Identifier = b.BundleInfo.CFBundleIdentifier
+	}
+	if v := version(b.BundleInfo.CFBundleShortVersionString, b.BundleInfo.CFBundleVersion); v != "" {
+		r.Version = v
+	}
+	if b.OSVersion.Train != "" {
+		r.OSVersion = fmt.Sprintf("%s (%s)", b.OSVersion.Train, b.OSVersion.Build)
+	}
+	r.ExceptionType = b.Exception.Type
+	r.Signal = b.Exception.Signal
+	r.ExceptionSubtype = b.Exception.Subtype
+	r.ExceptionCodes = b.Exception.Codes
+
+	for _, img := range b.UsedImages {
+		r.Images = append(r.Images, Image{
+			Name: img.Name,
+			Path: img.Path,
+			UUID: normalizeUUID(img.UUID),
+			Arch: img.Arch,
+			Base: img.Base,
+			Size: img.Size,
+		})
+	}
+
+	for i, t := range b.Threads {
+		thread := Thread{Index: i, Name: t.Name, Queue: t.Queue, Crashed: t.Triggered}
+		if t.Triggered {
+			r.CrashedThread = i
+		}
+		for _, f := range t.Frames {
+			frame := Frame{
+				ImageIndex:   -1,
+				ImageOffset:  f.ImageOffset,
+				Symbol:       f.Symbol,
+				SymbolOffset: f.SymbolLocation,
+				File:         f.SourceFile,
+				Line:         f.SourceLine,
+			}
+			if f.ImageIndex >= 0 && f.ImageIndex < len(r.Images) {
+				frame.ImageIndex = f.ImageIndex
+				frame.Address = r.Images[f.ImageIndex].Base + f.ImageOffset
+			}
+			thread.Frames = append(thread.Frames, frame)
+		}
+		r.Threads = append(r.Threads, thread)
+	}
+	if r.CrashedThread < 0 && b.FaultingThread != nil && *b.FaultingThread < len(r.Threads) {
+		r.CrashedThread = *b.FaultingThread
+		r.Threads[r.CrashedThread].Crashed = true
+	}
+
+	return nil
+}
+
+// version formats an app version the way crash reports do.
+func version(short, build string) string {
+	switch {
+	case short == "":
+		return build
+	case build == "":
+		return short
+	}
+
+	return fmt.Sprintf("%s (%s)", short, build)
+}
@@ -0,0 +1,227 @@
+package crashlog
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Thread 0 name:  Dispatch queue: com.apple.main-thread
+	legacyThreadName = regexp.MustCompile(`^Thread (\d+) name:\s*(.*)$`)
+	// Thread 0 Crashed:
+	legacyThread = regexp.MustCompile(`^Thread (\d+)( Crashed)?:\s*$`)
+	// 0   MyApp    0x0000000100f45a2c 0x100f40000 + 23084
+	// 0   MyApp    0x0000000100f45a2c main + 20 (main.m:14)
+	legacyFrame = regexp.MustCompile(`^(\d+)\s+(.+?)\s+(0x[0-9a-fA-F]+)\s+(.*)$`)
+	// 0x100f40000 + 23084
+	legacyOffset = regexp.MustCompile(`^0x[0-9a-fA-F]+ \+ (\d+)$`)
+	// main + 20 (main.m:14)
+	legacySymbol = regexp.MustCompile(`^(.+?) \+ (\d+)(?: \((.+):(\d+)\))?$`)
+	// 0x100f40000 - 0x100f47fff MyApp arm64  <c3ad7f1bd8b93a8fa5b2c6b7f2d8f81b> /var/.../MyApp
+	legacyImage = regexp.MustCompile(`^\s*(0x[0-9a-fA-F]+)\s*-\s*(0x[0-9a-fA-F]+)\s+\+?(.+?)\s+(\S+)\s+<([0-9a-fA-F-]+)>\s*(.*)$`)
+	// EXC_BAD_ACCESS (SIGSEGV)
+	legacyException = regexp.MustCompile(`^(\S+)\s+\((\S+)\)$`)
+	// MyApp [1234]
+	legacyProcess = regexp.MustCompile(`^(.+?)\s+\[(\d+)\]$`)
+)
+
+// parseLegacy parses the text format of .crash files.
+func parseLegacy(r *Report, data []byte) error {
+	var (
+		thread      *Thread
+		images      []string
+		frameImages [][]string
+		names       = make(map[int]string)
+		inImages    bool
+		sawContent  bool
+	)
+	endThread := func() {
+		if thread != nil {
+			r.Threads = append(r.Threads, *thread)
+			frameImages = append(frameImages, images)
+			thread, images = nil, nil
+		}
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			endThread()
+			inImages = false
+			continue
+		}
+
+		if inImages {
+			if m := legacyImage.FindStringSubmatch(line); m != nil {
+				base, _ := strconv.ParseUint(m[1], 0, 64)
+				end, _ := strconv.ParseUint(m[2], 0, 64)
+				r.Images = append(r.Images, Image{
+					Name: m[3],
+					Arch: m[4],
+					UUID: normalizeUUID(m[5]),
+					Path: m[6],
+					Base: base,
+					Size: end - base + 1,
+				})
+			}
+			continue
+		}
+
+		if thread != nil {
+			if m := legacyFrame.FindStringSubmatch(line); m != nil {
+				f, image := legacyParseFrame(m)
+				thread.Frames = append(thread.Frames, f)
+				images = append(images, image)
+				continue
+			}
+			endThread()
+		}
+
+		if m := legacyThreadName.FindStringSubmatch(line); m != nil {
+			i, _ := strconv.Atoi(m[1])
+			names[i] = m[2]
+			continue
+		}
+		if m := legacyThread.FindStringSubmatch(line); m != nil {
+			i, _ := strconv.Atoi(m[1])
+			thread = &Thread{Index: i, Crashed: m[2] != ""}
+			if thread.Crashed {
+				r.CrashedThread = i
+			}
+			sawContent = true
+			continue
+		}
+		if strings.HasPrefix(line, "Binary Images:") {
+			inImages = true
+			continue
+		}
+
+		key, value, ok := legacyField(line)
+		if !ok {
+			continue
+		}
+		if legacyHeader(r, key, value) {
+			sawContent = true
+		}
+	}
+	endThread()
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if !sawContent {
+		return ErrNotCrashReport
+	}
+
+	for i := range r.Threads {
+		t := &r.Threads[i]
+		name := names[t.Index]
+		if strings.HasPrefix(name, "Dispatch queue: ") {
+			t.Queue = strings.TrimPrefix(name, "Dispatch queue: ")
+		} else {
+			t.Name = name
+		}
+	}
+	legacyResolveImages(r, frameImages)
+
+	return nil
+}
+
+// legacyField splits a "Key:   value" header line.
+func legacyField(line string) (key, value string, ok bool) {
+	i := strings.Index(line, ":")
+	if i <= 0 || strings.HasPrefix(line, " ") {
+		return "", "", false
+	}
+
+	return line[:i], strings.TrimSpace(line[i+1:]), true
+}
+
+// legacyHeader stores a header field and reports whether it is one of the
+// fields of a crash report.
+func legacyHeader(r *Report, key, value string) bool {
+	switch key {
+	case "Process":
+		r.Process = value
+		if m := legacyProcess.FindStringSubmatch(value); m != nil {
+			r.Process = m[1]
+			r.PID, _ = strconv.Atoi(m[2])
+		}
+	case "Identifier":
+		r.Identifier = value
+	case "Version":
+		r.Version = value
+	case "Code Type":
+		r.CodeType = value
+	case "OS Version":
+		r.OSVersion = value
+	case "Date/Time":
+		r.Time = value
+	case "Exception Type":
+		r.ExceptionType = value
+		if m := legacyException.FindStringSubmatch(value); m != nil {
+			r.ExceptionType, r.Signal = m[1], m[2]
+		}
+	case "Exception Subtype":
+		r.ExceptionSubtype = value
+	case "Exception Codes":
+		r.ExceptionCodes = value
+	case "Triggered by Thread", "Crashed Thread":
+		if i, err := strconv.Atoi(strings.Fields(value + " ")[0]); err == nil {
+			r.CrashedThread = i
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// legacyParseFrame parses a backtrace line. The image is only known by name
+// until the binary images are read.
+func legacyParseFrame(m []string) (Frame, string) {
+	f := Frame{ImageIndex: -1}
+	f.Address, _ = strconv.ParseUint(m[3], 0, 64)
+
+	rest := strings.TrimSpace(m[4])
+	if o := legacyOffset.FindStringSubmatch(rest); o != nil {
+		f.ImageOffset, _ = strconv.ParseUint(o[1], 10, 64)
+	} else if s := legacySymbol.FindStringSubmatch(rest); s != nil {
+		f.Symbol = s[1]
+		f.SymbolOffset, _ = strconv.ParseUint(s[2], 10, 64)
+		f.File = s[3]
+		f.Line, _ = strconv.Atoi(s[4])
+	} else {
+		f.Symbol = rest
+	}
+
+	return f, m[2]
+}
+
+// legacyResolveImages points frames at their binary images by name.
+func legacyResolveImages(r *Report, frameImages [][]string) {
+	byName := make(map[string]int)
+	for i, img := range r.Images {
+		if _, ok := byName[img.Name]; !ok {
+			byName[img.Name] = i
+		}
+	}
+
+	for t := range r.Threads {
+		for i := range r.Threads[t].Frames {
+			f := &r.Threads[t].Frames[i]
+			idx, ok := byName[frameImages[t][i]]
+			if !ok {
+				continue
+			}
+			f.ImageIndex = idx
+			if base := r.Images[idx].Base; f.Address >= base {
+				f.ImageOffset = f.Address - base
+			}
+		}
+	}
+}
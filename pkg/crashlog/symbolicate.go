@@ -0,0 +1,366 @@
+package crashlog
+
+import (
+	"debug/dwarf"
+	"debug/macho"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Mach-O magic numbers, as read big endian from the start of a file.
+const (
+	magic32    = 0xfeedface
+	magic64    = 0xfeedfacf
+	magic32Rev = 0xcefaedfe
+	magic64Rev = 0xcffaedfe
+	magicFat   = 0xcafebabe
+)
+
+const loadCmdUUID macho.LoadCmd = 0x1b
+
+// Symbolicator resolves addresses against dSYMs and other Mach-O files with
+// debug information, matched to report images by UUID.
+type Symbolicator struct {
+	images map[string]*debugImage
+}
+
+// debugImage is one architecture slice of a Mach-O file. Its debug
+// information is loaded the first time it is needed.
+type debugImage struct {
+	path   string
+	arch   int // index in a fat file, or -1
+	uuid   string
+	text   uint64
+	loaded bool
+	closer io.Closer
+	dwarf  *dwarf.Data
+	syms   []macho.Symbol
+}
+
+// NewSymbolicator returns a Symbolicator that knows no binaries.
+func NewSymbolicator() *Symbolicator {
+	return &Symbolicator{images: make(map[string]*debugImage)}
+}
+
+// Close releases the files opened while symbolicating.
+func (s *Symbolicator) Close() error {
+	var first error
+	for _, img := range s.images {
+		if img.closer != nil {
+			if err := img.closer.Close(); err != nil && first == nil {
+				first = err
+			}
+			img.closer = nil
+		}
+	}
+
+	return first
+}
+
+// Add makes the Mach-O binaries at path available. path may be a binary, a
+// .dSYM bundle or any directory, such as an Xcode archive, which is searched
+// recursively; files there that are not Mach-O are skipped.
+func (s *Symbolicator) Add(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return s.addFile(path)
+	}
+
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || !isMachO(p) {
+			return nil
+		}
+		// A file that looks like Mach-O but does not parse is not fatal in a
+		// directory full of other things.
+		_ = s.addFile(p)
+		return nil
+	})
+}
+
+// UUIDs returns the UUIDs of the binaries added so far.
+func (s *Symbolicator) UUIDs() []string {
+	uuids := make([]string, 0, len(s.images))
+	for uuid := range s.images {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	return uuids
+}
+
+func (s *Symbolicator) addFile(path string) error {
+	if ff, err := macho.OpenFat(path); err == nil {
+		defer ff.Close()
+		for i, arch := range ff.Arches {
+			s.addImage(path, i, arch.File)
+		}
+		return nil
+	}
+
+	f, err := macho.Open(path)
+	if err != nil {
+		return xerrors.Errorf("crashlog: %s: %w", path, err)
+	}
+	defer f.Close()
+	s.addImage(path, -1, f)
+
+	return nil
+}
+
+func (s *Symbolicator) addImage(path string, arch int, f *macho.File) {
+	uuid := machoUUID(f)
+	if uuid == "" {
+		return
+	}
+
+	img := &debugImage{path: path, arch: arch, uuid: uuid}
+	if seg := f.Segment("__TEXT"); seg != nil {
+		img.text = seg.Addr
+	}
+	// A dSYM is preferred over the binary it was made from, which may have
+	// been stripped.
+	if old, ok := s.images[uuid]; ok && !strings.Contains(path, ".dSYM") && strings.Contains(old.path, ".dSYM") {
+		return
+	}
+	s.images[uuid] = img
+}
+
+// Symbolicate fills in the symbol and source location of every frame whose
+// image was added, and returns how many frames it resolved.
+func (s *Symbolicator) Symbolicate(r *Report) int {
+	n := 0
+	for t := range r.Threads {
+		for i := range r.Threads[t].Frames {
+			f := &r.Threads[t].Frames[i]
+			img := r.Image(*f)
+			if img == nil || img.UUID == "" {
+				continue
+			}
+			debug, ok := s.images[img.UUID]
+			if !ok {
+				continue
+			}
+
+			// Return addresses point after the call; look up the call
+			// itself so the line is right when it is the last instruction
+			// of an inlined or noreturn call site.
+			offset := f.ImageOffset
+			if i > 0 && offset > 0 {
+				offset--
+			}
+			sym, ok := debug.lookup(debug.text + offset)
+			if !ok {
+				continue
+			}
+			f.Symbol = sym.name
+			f.SymbolOffset = f.ImageOffset - (sym.addr - debug.text)
+			f.File = sym.file
+			f.Line = sym.line
+			n++
+		}
+	}
+
+	return n
+}
+
+// symbol is what lookup found for an address.
+type symbol struct {
+	name string
+	addr uint64
+	file string
+	line int
+}
+
+func (img *debugImage) load() {
+	if img.loaded {
+		return
+	}
+	img.loaded = true
+
+	var f *macho.File
+	if img.arch >= 0 {
+		ff, err := macho.OpenFat(img.path)
+		if err != nil || img.arch >= len(ff.Arches) {
+			return
+		}
+		img.closer = ff
+		f = ff.Arches[img.arch].File
+	} else {
+		mf, err := macho.Open(img.path)
+		if err != nil {
+			return
+		}
+		img.closer = mf
+		f = mf
+	}
+
+	img.dwarf, _ = f.DWARF()
+	if f.Symtab != nil {
+		for _, sym := range f.Symtab.Syms {
+			// Defined section symbols only, no stabs.
+			if sym.Type&0xe0 == 0 && sym.Type&0x0e == 0x0e && sym.Sect != 0 {
+				img.syms = append(img.syms, sym)
+			}
+		}
+		sort.Slice(img.syms, func(i, j int) bool {
+			return img.syms[i].Value < img.syms[j].Value
+		})
+	}
+}
+
+// lookup resolves pc, a virtual address of the image, from DWARF and falls
+// back to the symbol table.
+func (img *debugImage) lookup(pc uint64) (symbol, bool) {
+	img.load()
+
+	if img.dwarf != nil {
+		if sym, ok := dwarfLookup(img.dwarf, pc); ok {
+			return sym, true
+		}
+	}
+
+	i := sort.Search(len(img.syms), func(i int) bool {
+		return img.syms[i].Value > pc
+	}) - 1
+	if i < 0 {
+		return symbol{}, false
+	}
+	name := img.syms[i].Name
+	// C symbols carry a leading underscore in Mach-O.
+	if strings.HasPrefix(name, "_") {
+		name = name[1:]
+	}
+
+	return symbol{name: name, addr: img.syms[i].Value}, true
+}
+
+func dwarfLookup(d *dwarf.Data, pc uint64) (symbol, bool) {
+	r := d.Reader()
+	cu, err := r.SeekPC(pc)
+	if err != nil {
+		return symbol{}, false
+	}
+
+	var sym symbol
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || e.Tag == dwarf.TagCompileUnit || e.Tag == dwarf.TagPartialUnit {
+			break
+		}
+		if e.Tag != dwarf.TagSubprogram {
+			continue
+		}
+
+		ranges, err := d.Ranges(e)
+		if err != nil || !inRanges(ranges, pc) {
+			if e.Children {
+				r.SkipChildren()
+			}
+			continue
+		}
+		sym.name = dwarfName(d, e)
+		sym.addr = ranges[0][0]
+		for _, rg := range ranges {
+			if rg[0] < sym.addr {
+				sym.addr = rg[0]
+			}
+		}
+		break
+	}
+	if sym.name == "" {
+		return symbol{}, false
+	}
+
+	if lr, err := d.LineReader(cu); err == nil && lr != nil {
+		var le dwarf.LineEntry
+		if lr.SeekPC(pc, &le) == nil && le.File != nil {
+			sym.file = le.File.Name
+			sym.line = le.Line
+		}
+	}
+
+	return sym, true
+}
+
+func inRanges(ranges [][2]uint64, pc uint64) bool {
+	for _, r := range ranges {
+		if pc >= r[0] && pc < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dwarfName returns the name of a subprogram, following the declaration or
+// abstract instance it refers to when it has none of its own.
+func dwarfName(d *dwarf.Data, e *dwarf.Entry) string {
+	for depth := 0; e != nil && depth < 4; depth++ {
+		if name, ok := e.Val(dwarf.AttrName).(string); ok {
+			return name
+		}
+		if name, ok := e.Val(dwarf.AttrLinkageName).(string); ok {
+			return name
+		}
+
+		off, ok := e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		if !ok {
+			off, ok = e.Val(dwarf.AttrSpecification).(dwarf.Offset)
+		}
+		if !ok {
+			return ""
+		}
+		r := d.Reader()
+		r.Seek(off)
+		e, _ = r.Next()
+	}
+
+	return ""
+}
+
+// machoUUID returns the LC_UUID of f, or "".
+func machoUUID(f *macho.File) string {
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) < 24 {
+			continue
+		}
+		if macho.LoadCmd(f.ByteOrder.Uint32(raw)) == loadCmdUUID {
+			return formatUUID(raw[8:24])
+		}
+	}
+
+	return ""
+}
+
+// isMachO reports whether the file at path starts with a Mach-O magic number.
+func isMachO(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var magic uint32
+	if err := binary.Read(f, binary.BigEndian, &magic); err != nil {
+		return false
+	}
+	switch magic {
+	case magic32, magic64, magic32Rev, magic64Rev, magicFat:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,128 @@
+package crashlog
+
+import (
+	"debug/macho"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const crashProgram = `package main
+
+//go:noinline
+func crashHere(p *int) int {
+	return *p + 1
+}
+
+func main() {
+	println(crashHere(nil))
+}
+`
+
+// buildDarwin builds crashProgram for iOS hardware, which gives a Mach-O
+// with LC_UUID and DWARF like an app binary next to its dSYM.
+func buildDarwin(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a darwin binary")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(crashProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/crash\n\ngo 1.16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(dir, "MyApp.app.dSYM", "Contents", "Resources", "DWARF", "MyApp")
+	cmd := exec.Command(goTool, "build", "-o", bin, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=darwin", "GOARCH=arm64", "CGO_ENABLED=0", "GOFLAGS=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	return bin
+}
+
+func TestSymbolicate(t *testing.T) {
+	bin := buildDarwin(t)
+
+	f, err := macho.Open(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid := machoUUID(f)
+	text := f.Segment("__TEXT").Addr
+	var fn uint64
+	for _, sym := range f.Symtab.Syms {
+		if sym.Name == "_main.crashHere" || sym.Name == "main.crashHere" {
+			fn = sym.Value
+		}
+	}
+	f.Close()
+	if uuid == "" || fn == 0 {
+		t.Fatalf("UUID %q, main.crashHere at %#x", uuid, fn)
+	}
+
+	const base = 0x104000000
+	r := &Report{
+		CrashedThread: 0,
+		Images: []Image{
+			{Name: "MyApp", UUID: uuid, Base: base, Size: 1 << 20},
+			{Name: "libsystem_kernel.dylib", UUID: "3C4D5E6F-7A8B-39C0-9D1E-2F3A4B5C6D7E", Base: 0x1e8000000, Size: 1 << 16},
+		},
+		Threads: []Thread{{Index: 0, Crashed: true, Frames: []Frame{
+			{ImageIndex: 0, Address: base + fn - text + 4, ImageOffset: fn - text + 4},
+			{ImageIndex: 1, Address: 0x1e8000fd0, ImageOffset: 0xfd0, Symbol: "__workq_kernreturn", SymbolOffset: 8},
+		}}},
+	}
+
+	sym := NewSymbolicator()
+	defer sym.Close()
+	// The bundle is found by walking its parent directory.
+	if err := sym.Add(filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(bin))))); err != nil {
+		t.Fatal(err)
+	}
+	if uuids := sym.UUIDs(); len(uuids) != 1 || uuids[0] != uuid {
+		t.Fatalf("UUIDs = %v, want [%s]", uuids, uuid)
+	}
+
+	if n := sym.Symbolicate(r); n != 1 {
+		t.Fatalf("Symbolicate resolved %d frames, want 1", n)
+	}
+	got := r.Threads[0].Frames[0]
+	// Where the prologue ends depends on the compiler; any line of
+	// crashHere will do.
+	if got.Symbol != "main.crashHere" || got.SymbolOffset != 4 || !strings.HasSuffix(got.File, "main.go") || got.Line < 4 || got.Line > 6 {
+		t.Fatalf("frame = %+v", got)
+	}
+	// Frames of images without a dSYM are left alone.
+	if other := r.Threads[0].Frames[1]; other.Symbol != "__workq_kernreturn" || other.File != "" {
+		t.Fatalf("frame = %+v", other)
+	}
+}
+
+func TestSymbolicator_AddNotMachO(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "notes.txt")
+	if err := ioutil.WriteFile(name, []byte("not a binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sym := NewSymbolicator()
+	if err := sym.Add(name); err == nil {
+		t.Fatal("Add of a text file succeeded")
+	}
+	// Directories skip what is not Mach-O.
+	if err := sym.Add(filepath.Dir(name)); err != nil {
+		t.Fatal(err)
+	}
+}
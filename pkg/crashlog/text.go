@@ -0,0 +1,101 @@
+package crashlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// WriteText writes r in the text layout of .crash files, with symbols and
+// source locations where they are known.
+func (r *Report) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	field := func(key, value string) {
+		if value == "" {
+			return
+		}
+		pad := 20 - len(key) - 1
+		if pad < 2 {
+			pad = 2
+		}
+		fmt.Fprintf(bw, "%s:%s%s\n", key, strings.Repeat(" ", pad), value)
+	}
+	process := r.Process
+	if r.PID != 0 {
+		process = fmt.Sprintf("%s [%d]", r.Process, r.PID)
+	}
+	field("Process", process)
+	field("Identifier", r.Identifier)
+	field("Version", r.Version)
+	field("Code Type", r.CodeType)
+	field("OS Version", r.OSVersion)
+	field("Date/Time", r.Time)
+	bw.WriteString("\n")
+
+	exception := r.ExceptionType
+	if r.Signal != "" {
+		exception = fmt.Sprintf("%s (%s)", r.ExceptionType, r.Signal)
+	}
+	field("Exception Type", exception)
+	field("Exception Subtype", r.ExceptionSubtype)
+	field("Exception Codes", r.ExceptionCodes)
+	if r.CrashedThread >= 0 {
+		field("Triggered by Thread", fmt.Sprint(r.CrashedThread))
+	}
+
+	for _, t := range r.Threads {
+		bw.WriteString("\n")
+		switch {
+		case t.Name != "" && t.Queue != "":
+			fmt.Fprintf(bw, "Thread %d name:  %s  Dispatch queue: %s\n", t.Index, t.Name, t.Queue)
+		case t.Name != "":
+			fmt.Fprintf(bw, "Thread %d name:  %s\n", t.Index, t.Name)
+		case t.Queue != "":
+			fmt.Fprintf(bw, "Thread %d name:  Dispatch queue: %s\n", t.Index, t.Queue)
+		}
+		if t.Crashed {
+			fmt.Fprintf(bw, "Thread %d Crashed:\n", t.Index)
+		} else {
+			fmt.Fprintf(bw, "Thread %d:\n", t.Index)
+		}
+		for i, f := range t.Frames {
+			fmt.Fprintf(bw, "%-4d%-30s\t0x%016x %s\n", i, r.imageName(f), f.Address, r.location(f))
+		}
+	}
+
+	if len(r.Images) > 0 {
+		bw.WriteString("\nBinary Images:\n")
+		for _, img := range r.Images {
+			fmt.Fprintf(bw, "0x%x - 0x%x %s %s  <%s> %s\n", img.Base, img.Base+img.Size-1, img.Name, img.Arch, img.UUID, img.Path)
+		}
+	}
+
+	return bw.Flush()
+}
+
+func (r *Report) imageName(f Frame) string {
+	if img := r.Image(f); img != nil {
+		return img.Name
+	}
+
+	return "???"
+}
+
+// location describes where f is: "symbol + offset (file:line)" when the
+// symbol is known, "base + offset" otherwise.
+func (r *Report) location(f Frame) string {
+	if f.Symbol == "" {
+		base := f.Address - f.ImageOffset
+		return fmt.Sprintf("0x%x + %d", base, f.ImageOffset)
+	}
+
+	s := fmt.Sprintf("%s + %d", f.Symbol, f.SymbolOffset)
+	if f.File != "" {
+		s += fmt.Sprintf(" (%s:%d)", path.Base(f.File), f.Line)
+	}
+
+	return s
+}
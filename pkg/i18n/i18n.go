@@ -0,0 +1,158 @@
+// Package i18n holds the message catalogs for user facing text.
+//
+// Messages are looked up by ID. The language is picked when the package is
+// initialised, before any command is built: a --lang argument wins, then
+// LC_ALL, LC_MESSAGES and LANG. Chinese is the default.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Chinese = "zh"
+	English = "en"
+
+	// DefaultLanguage is used when nothing selects a language.
+	DefaultLanguage = Chinese
+)
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]map[string]string{
+		Chinese: zhMessages,
+		English: enMessages,
+	}
+	current = DefaultLanguage
+)
+
+func init() {
+	current = Detect(os.Args[1:], os.Getenv)
+}
+
+// Register adds a catalog for lang, or extends an existing one. Messages
+// missing from a catalog fall back to English, then Chinese.
+func Register(lang string, messages map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	catalog, ok := catalogs[lang]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		catalogs[lang] = catalog
+	}
+	for id, msg := range messages {
+		catalog[id] = msg
+	}
+}
+
+// Languages returns the registered languages, sorted.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	return langs
+}
+
+// Language returns the selected language.
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current
+}
+
+// SetLanguage selects lang. Text that was already looked up, such as
+// command descriptions, is not updated.
+func SetLanguage(lang string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := catalogs[lang]; !ok {
+		return fmt.Errorf("unsupported language: %s", lang)
+	}
+	current = lang
+
+	return nil
+}
+
+// T returns the message for id in the selected language, formatted with args
+// when any are given. Unknown IDs are returned as is.
+func T(id string, args ...interface{}) string {
+	mu.RLock()
+	msg, ok := catalogs[current][id]
+	if !ok {
+		msg, ok = catalogs[English][id]
+	}
+	if !ok {
+		msg, ok = catalogs[Chinese][id]
+	}
+	mu.RUnlock()
+
+	if !ok {
+		msg = id
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+
+	return msg
+}
+
+// Detect picks the language from a --lang argument, then the locale
+// environment variables. It only knows the built in catalogs, since it runs
+// before anything can be registered. A --lang value that names none of them
+// is skipped here, for the command line to report.
+func Detect(args []string, getenv func(string) string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if v := strings.TrimPrefix(arg, "--lang="); v != arg && builtin(v) {
+			return v
+		}
+		if arg == "--lang" && i+1 < len(args) && builtin(args[i+1]) {
+			return args[i+1]
+		}
+	}
+
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if lang, ok := parseLocale(getenv(name)); ok {
+			return lang
+		}
+	}
+
+	return DefaultLanguage
+}
+
+func builtin(lang string) bool {
+	return lang == Chinese || lang == English
+}
+
+// parseLocale maps a locale such as "zh_CN.UTF-8" or "en" to a language.
+// "C" and "POSIX" carry no preference. Other languages fall back to English.
+func parseLocale(s string) (string, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(s, "_.@-"); i >= 0 {
+		s = s[:i]
+	}
+
+	switch s {
+	case "", "c", "posix":
+		return "", false
+	case Chinese:
+		return Chinese, true
+	}
+
+	return English, true
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{nil, nil, DefaultLanguage},
+		{nil, map[string]string{"LANG": "en_US.UTF-8"}, English},
+		{nil, map[string]string{"LANG": "zh_CN.UTF-8"}, Chinese},
+		{nil, map[string]string{"LANG": "de_DE.UTF-8"}, English},
+		{nil, map[string]string{"LANG": "C.UTF-8"}, DefaultLanguage},
+		{nil, map[string]string{"LC_ALL": "zh_TW", "LANG": "en_US"}, Chinese},
+		{nil, map[string]string{"LC_MESSAGES": "en", "LANG": "zh_CN"}, English},
+		{[]string{"--lang", "en", "info"}, map[string]string{"LANG": "zh_CN"}, English},
+		{[]string{"--lang=zh", "info"}, map[string]string{"LANG": "en_US"}, Chinese},
+		{[]string{"run", "--", "--lang", "en"}, nil, DefaultLanguage},
+		{[]string{"--lang=fr", "info"}, map[string]string{"LANG": "en_US"}, English},
+		{[]string{"--lang", "zh_CN"}, nil, DefaultLanguage},
+	}
+
+	for _, tt := range tests {
+		getenv := func(name string) string { return tt.env[name] }
+		if got := Detect(tt.args, getenv); got != tt.want {
+			t.Errorf("Detect(%q, %v) = %s, want %s", tt.args, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogs(t *testing.T) {
+	for id := range zhMessages {
+		if _, ok := enMessages[id]; !ok {
+			t.Errorf("%s missing from the English catalog", id)
+		}
+	}
+	for id := range enMessages {
+		if _, ok := zhMessages[id]; !ok {
+			t.Errorf("%s missing from the Chinese catalog", id)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	defer func(lang string) { current = lang }(Language())
+
+	if err := SetLanguage(English); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("device.not_found", "abc"); got != "device not found: abc" {
+		t.Fatalf("T = %q", got)
+	}
+	if got := T("no.such.id"); got != "no.such.id" {
+		t.Fatalf("T(unknown) = %q", got)
+	}
+
+	Register("fr", map[string]string{"device.none": "aucun appareil"})
+	defer func() {
+		mu.Lock()
+		delete(catalogs, "fr")
+		mu.Unlock()
+	}()
+
+	if err := SetLanguage("fr"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("device.none"); got != "aucun appareil" {
+		t.Fatalf("T = %q", got)
+	}
+	if got := T("err.pair"); got != enMessages["err.pair"] {
+		t.Fatalf("T fallback = %q", got)
+	}
+	if err := SetLanguage("xx"); err == nil {
+		t.Fatal("expected an error for an unknown language")
+	}
+}
@@ -0,0 +1,251 @@
+package i18n
+
+var enMessages = map[string]string{
+	// help templates
+	"help.version":        "version",
+	"help.global_options": "Global Options:",
+	"help.commands":       "Available Commands:",
+	"help.aliases":        "alias",
+	"help.help":           "Display help information",
+	"help.more":           `Use "<cyan>{$binName} COMMAND -h</>" for more information about a command`,
+	"help.usage":          "Usage:",
+	"help.options":        "Options:",
+	"help.arguments":      "Arguments:",
+	"help.examples":       "Examples:",
+	"help.help_section":   "Help:",
+
+	// global options
+	"opt.socket":           "usbmuxd address, a unix socket path or TCP host:port; defaults to USBMUXD_SOCKET_ADDRESS",
+	"opt.udid":             "UDID of the target device; defaults to IOSBOX_UDID, otherwise the first device",
+	"opt.conn":             "connection type: usb, network or any; USB wins when a device is on both USB and Wi-Fi",
+	"opt.output":           "output format: table, json or plist; streaming commands (syslog, watch) print one JSON record per line",
+	"opt.lang":             "interface language: zh or en; defaults to LANG",
+	"err.unsupported_lang": "unsupported language %s, use one of: %s",
+	"opt.trace":            "append usbmuxd, lockdown, AFC and gdb-remote traffic to this file; defaults to IOSBOX_TRACE; private keys and other secrets are redacted",
+	"opt.record":           "record the usbmuxd connections of this run to this file, for replaying in pkg/idevice tests; pair record private keys are redacted",
+
+	// device selection
+	"device.none":         "no iOS device connected",
+	"device.not_found":    "device not found: %s",
+	"device.no_conn_type": "no device connected over %s",
+
+	// common errors
+	"err.connect_device":  "connecting to iOS device",
+	"err.get_device":      "getting iOS device",
+	"err.connect_service": "connecting to service",
+	"err.output_format":   "unsupported output format: %s, expected table, json or plist",
+	"err.stream_format":   "this command prints a stream and only supports table or json output",
+	"err.read_file":       "reading file",
+	"err.write_file":      "writing file",
+	"err.open_trace":      "opening trace file",
+	"err.open_record":     "creating recording file",
+	"err.write_record":    "writing recording file",
+
+	// value formatting
+	"fmt.data":        "<data %d bytes>",
+	"fmt.dict":        "<dict %d items>",
+	"fmt.array":       "<array %d items>",
+	"fmt.bytes":       "%d bytes",
+	"fmt.valid_until": "valid until %s",
+
+	// devices
+	"cmd.devices.desc": "List all connected devices",
+	"err.list_devices": "listing devices",
+
+	// info
+	"cmd.info.desc":       "Show device information",
+	"cmd.info.opt.domain": "lockdown domain such as com.apple.disk_usage; the global domain by default",
+	"cmd.info.opt.key":    "only show this key; the whole domain by default",
+	"err.device_info":     "getting device information",
+
+	// apps
+	"cmd.apps.desc":          "List installed applications",
+	"cmd.apps.arg.name":      "application name",
+	"cmd.install.desc":       "Install an application",
+	"cmd.install.arg.ipa":    "path to the IPA file",
+	"cmd.install.example":    "{$binName} {$cmd} $HOME/Downloads/example.ipa",
+	"cmd.install.opt.resume": "upload only the rest of the IPA when a previous upload was interrupted",
+	"cmd.install.opt.verify": "check the SHA-1 of the uploaded IPA before installing it",
+	"err.missing_ipa":        "no IPA file given",
+	"msg.uploading":          "Uploading...",
+	"err.upload_ipa":         "uploading IPA file",
+	"err.verify_ipa":         "verifying uploaded IPA file",
+	"err.install":            "installing application",
+	"cmd.uninstall.desc":     "Uninstall an application",
+	"cmd.uninstall.arg.id":   "application bundle ID",
+	"cmd.uninstall.example":  "{$binName} {$cmd} com.xxx.xxx",
+	"err.missing_bundle_id":  "no bundle ID given",
+	"err.uninstall":          "uninstalling application [%s]",
+	"msg.uninstalled":        "Application uninstalled",
+
+	// processes
+	"cmd.procs.desc":    "List running processes",
+	"cmd.kill.desc":     "Kill a process",
+	"cmd.kill.arg.proc": "PID or process name",
+	"cmd.respring.desc": "Restart SpringBoard",
+
+	// system
+	"cmd.reboot.desc":       "Reboot the device; it must be jailbroken again afterwards",
+	"cmd.syslog.desc":       "Print the system log",
+	"cmd.syslog.arg.filter": "filter by process or module name",
+	"err.read_syslog":       "reading system log",
+
+	// shell
+	"cmd.shell.desc":    "Open an interactive SSH shell",
+	"err.ssh_client":    "creating SSH client",
+	"err.ssh_session":   "opening SSH session",
+	"err.ssh_terminal":  "creating SSH terminal",
+	"err.ssh_term_size": "getting terminal size",
+	"err.ssh_pty":       "requesting SSH terminal",
+	"err.ssh_shell":     "starting SSH shell",
+	"cmd.run.desc":      "Run a shell command",
+	"cmd.run.arg.cmd":   "shell command and arguments",
+	"err.run_shell":     "running shell command",
+	"cmd.redaemon.desc": "Restart daemons",
+	"cmd.scp.desc":      "Copy files over SSH",
+	"cmd.scp.arg.path":  "local or remote file path",
+	"err.scp_args":      "invalid SCP arguments",
+	"err.ssh_connect":   "connecting over SSH",
+	"err.scp":           "SCP",
+
+	// forward
+	"cmd.forward.desc":       "Forward a local port to a device port (replaces iproxy)",
+	"cmd.forward.arg.local":  "local port",
+	"cmd.forward.arg.remote": "device port",
+	"cmd.forward.example":    "{$binName} {$cmd} LOCAL_PORT DEVICE_PORT",
+	"msg.forwarding":         "Forwarding localhost:%d to device port %d, press Ctrl-C to stop",
+
+	// pcap
+	"cmd.pcap.desc":     "Capture network packets",
+	"cmd.pcap.arg.file": "path to save the PCAP file",
+	"cmd.pcap.arg.proc": "process name",
+	"err.pcap":          "capturing packets",
+
+	// debug
+	"cmd.dbgserver.desc": "Start debugserver on the device",
+	"cmd.lldb.desc":      "Multi-window lldb debugging",
+	"lldb.title":         "lldb browser",
+	"lldb.quit":          "CTRL+C to quit",
+
+	// watch
+	"cmd.watch.desc":     "Watch devices being attached and detached",
+	"err.listen":         "listening for devices",
+	"msg.attached":       "attached",
+	"msg.detached":       "detached",
+	"msg.paired_event":   "paired",
+	"err.usbmuxd_closed": "usbmuxd connection closed",
+
+	// pair
+	"cmd.pair.desc":          "Pair with the device, generating certificates and saving the pair record",
+	"cmd.pair.opt.timeout":   "seconds to wait for the user to tap Trust on the device",
+	"msg.unlock_device":      "The device is locked, please unlock it...",
+	"msg.tap_trust":          "Please tap \"Trust\" on the device...",
+	"err.pair_denied":        "the user denied the pairing request",
+	"err.pair":               "pairing",
+	"msg.paired":             "Paired: %s (HostID: %s)",
+	"cmd.pair.validate.desc": "Check that the device still trusts this host",
+	"err.pair_invalid":       "pairing is not valid",
+	"msg.pair_valid":         "Pairing is valid: %s",
+	"cmd.pair.unpair.desc":   "Unpair the device from this host",
+	"err.unpair":             "unpairing",
+	"msg.unpaired":           "Unpaired: %s",
+
+	// pairrecord
+	"cmd.pairrecord.desc":            "Manage pair records stored by usbmuxd",
+	"cmd.pairrecord.show.desc":       "Show the device's pair record",
+	"err.read_pair_record":           "reading pair record",
+	"err.read_buid":                  "reading BUID",
+	"msg.buid_mismatch":              " (differs from host BUID %s)",
+	"cmd.pairrecord.export.desc":     "Export the pair record to a plist file",
+	"cmd.pairrecord.export.arg.file": "output file, <UDID>.plist by default",
+	"err.encode_pair_record":         "encoding pair record",
+	"msg.exported":                   "Exported pair record: %s",
+	"cmd.pairrecord.import.desc":     "Import a pair record from a plist file",
+	"cmd.pairrecord.import.arg.file": "pair record file",
+	"err.parse_pair_record":          "parsing pair record",
+	"err.missing_host_id":            "pair record has no HostID",
+	"err.save_pair_record":           "saving pair record",
+	"msg.imported":                   "Imported pair record: %s",
+	"cmd.pairrecord.delete.desc":     "Delete the pair record stored by usbmuxd",
+	"err.delete_pair_record":         "deleting pair record",
+	"msg.deleted":                    "Deleted pair record: %s",
+
+	// fs
+	"cmd.fs.desc":            "Manage files on the media partition or in an app sandbox over AFC; no jailbreak needed",
+	"cmd.fs.arg.path":        "device path; quote wildcards such as \"*.JPG\" so they are expanded on the device",
+	"cmd.fs.opt.app":         "work in the sandbox of the app with this bundle ID instead of the media partition; the app must be signed for development",
+	"cmd.fs.opt.documents":   "with --app, only open the app's Documents directory, which works for any app that enables file sharing",
+	"cmd.fs.opt.recursive":   "copy or remove directories recursively",
+	"cmd.fs.opt.verify":      "compare the SHA-1 of every copied file with the device's, reading it back when the device cannot hash files",
+	"cmd.fs.ls.desc":         "List files",
+	"cmd.fs.ls.opt.long":     "show type, size and modification time",
+	"cmd.fs.pull.desc":       "Copy files from the device, keeping modification times",
+	"cmd.fs.pull.arg.paths":  "device paths followed by the local destination",
+	"cmd.fs.push.desc":       "Copy files to the device, keeping modification times",
+	"cmd.fs.push.arg.paths":  "local paths followed by the device destination",
+	"cmd.fs.push.opt.resume": "send only the rest of files that an interrupted push left partly copied",
+	"cmd.fs.rm.desc":         "Remove files",
+	"cmd.fs.mkdir.desc":      "Create directories, including missing parents",
+	"cmd.fs.mv.desc":         "Move or rename files",
+	"cmd.fs.mv.arg.paths":    "device paths followed by the destination",
+	"cmd.fs.stat.desc":       "Show the AFC attributes of a file",
+	"cmd.fs.tree.desc":       "Show a directory tree",
+	"err.fs_args":            "expected at least %d paths",
+	"err.fs_no_match":        "no match for %s",
+	"err.fs_is_dir":          "%s is a directory, use -r",
+	"err.fs_not_dir":         "%s is not a directory",
+	"err.fs_root":            "refusing to remove the root directory",
+	"err.fs_list":            "listing %s",
+	"err.fs_stat":            "reading attributes of %s",
+	"err.fs_pull":            "copying %s from the device",
+	"err.fs_push":            "copying %s to the device",
+	"err.fs_mtime":           "setting modification time of %s",
+	"err.fs_verify":          "verifying %s",
+	"err.fs_remove":          "removing %s",
+	"err.fs_mkdir":           "creating directory %s",
+	"err.fs_move":            "moving %s",
+	"err.fs_app":             "opening the sandbox of %s",
+	"msg.fs_copied":          "Copied %d files, %d bytes",
+	"msg.fs_verified":        "Verified %d files",
+	"msg.fs_tree_summary":    "%d directories, %d files",
+
+	// crash
+	"cmd.crash.desc":                    "Collect crash reports such as .ips files and symbolicate them; no jailbreak needed",
+	"cmd.crash.opt.process":             "only reports of processes whose name contains this, ignoring case",
+	"cmd.crash.opt.since":               "only reports written since then: a duration such as 24h, or a date such as 2006-01-02 or \"2006-01-02 15:04\"",
+	"cmd.crash.opt.delete":              "delete reports from the device once they are downloaded",
+	"cmd.crash.arg.dir":                 "local directory, the current directory by default",
+	"cmd.crash.ls.desc":                 "List crash reports",
+	"cmd.crash.pull.desc":               "Download crash reports, keeping modification times",
+	"cmd.crash.clear.desc":              "Delete crash reports from the device",
+	"cmd.crash.watch.desc":              "Print crash reports as they are written",
+	"cmd.crash.watch.opt.interval":      "seconds between checks for new reports",
+	"cmd.crash.watch.arg.dir":           "also download new reports into this directory",
+	"cmd.crash.symbolicate.desc":        "Symbolicate .ips and .crash reports with local dSYMs, offline",
+	"cmd.crash.symbolicate.opt.dsym":    "dSYM bundle, binary or directory to search for debug symbols, such as an .xcarchive; may be repeated",
+	"cmd.crash.symbolicate.arg.reports": "crash report files",
+	"err.crash_dsym":                    "loading debug symbols from %s",
+	"err.crash_parse":                   "parsing crash report %s",
+	"msg.crash_no_dsym":                 "%s: no dSYM for %s <%s>, its frames are not symbolicated",
+	"err.crash_list":                    "listing crash reports",
+	"err.crash_pull":                    "downloading crash report %s",
+	"err.crash_remove":                  "deleting crash report %s",
+	"err.crash_since":                   "invalid --since value: %s",
+	"err.crash_interval":                "--interval must be at least 1 second",
+	"msg.crash_pulled":                  "Downloaded %d crash reports to %s",
+	"msg.crash_cleared":                 "Deleted %d crash reports",
+
+	// unregistered commands
+	"cmd.cydia.desc":           "Cydia package repository",
+	"cmd.cydia.view.desc":      "List packages",
+	"cmd.cydia.search.desc":    "Search packages",
+	"cmd.cydia.install.desc":   "Install a package",
+	"cmd.cydia.uninstall.desc": "Uninstall a package",
+	"cmd.cydia.upgrade.desc":   "Upgrade packages",
+	"cmd.cydia.publish.desc":   "Publish a package",
+	"cmd.frida.desc":           "Frida script repository",
+	"cmd.frida.list.desc":      "List scripts",
+	"cmd.frida.search.desc":    "Search scripts",
+	"cmd.frida.get.desc":       "Download a script",
+	"cmd.donation.desc":        "Support the author",
+}
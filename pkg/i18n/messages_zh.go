@@ -0,0 +1,251 @@
+package i18n
+
+var zhMessages = map[string]string{
+	// help templates
+	"help.version":        "版本",
+	"help.global_options": "全局选项:",
+	"help.commands":       "命令列表:",
+	"help.aliases":        "别名",
+	"help.help":           "显示帮助信息",
+	"help.more":           `使用 "<cyan>{$binName} COMMAND -h</>" 查看命令的其他帮助信息`,
+	"help.usage":          "用法:",
+	"help.options":        "选项:",
+	"help.arguments":      "参数:",
+	"help.examples":       "示例:",
+	"help.help_section":   "帮助:",
+
+	// global options
+	"opt.socket":           "usbmuxd 地址，支持 unix 套接字路径或 TCP host:port，默认读取 USBMUXD_SOCKET_ADDRESS",
+	"opt.udid":             "目标设备 UDID，默认读取 IOSBOX_UDID，未指定时使用第一台设备",
+	"opt.conn":             "设备连接方式: usb, network 或 any，同一设备同时通过 USB 和 Wi-Fi 连接时优先使用 USB",
+	"opt.output":           "输出格式: table, json 或 plist，数据流命令(syslog, watch)的 json 输出为每行一条记录",
+	"opt.lang":             "界面语言: zh 或 en，默认读取 LANG",
+	"err.unsupported_lang": "不支持的语言 %s，可选: %s",
+	"opt.trace":            "将 usbmuxd、lockdown、AFC 和 gdb-remote 协议流量追加写入该文件，默认读取 IOSBOX_TRACE；私钥等敏感字段会被隐去",
+	"opt.record":           "将本次运行的 usbmuxd 连接录制到该文件，用于在 pkg/idevice 测试中回放；配对记录中的私钥会被隐去",
+
+	// device selection
+	"device.none":         "没有连接任何iOS设备",
+	"device.not_found":    "没有找到设备: %s",
+	"device.no_conn_type": "没有找到 %s 连接的设备",
+
+	// common errors
+	"err.connect_device":  "连接iOS设备错误",
+	"err.get_device":      "获取iOS设备错误",
+	"err.connect_service": "连接服务错误",
+	"err.output_format":   "不支持的输出格式: %s，可选 table, json 或 plist",
+	"err.stream_format":   "该命令输出为数据流，只支持 table 或 json 格式",
+	"err.read_file":       "读取文件错误",
+	"err.write_file":      "写入文件错误",
+	"err.open_trace":      "打开追踪文件错误",
+	"err.open_record":     "创建录制文件错误",
+	"err.write_record":    "写入录制文件错误",
+
+	// value formatting
+	"fmt.data":        "<data %d 字节>",
+	"fmt.dict":        "<dict %d 项>",
+	"fmt.array":       "<array %d 项>",
+	"fmt.bytes":       "%d 字节",
+	"fmt.valid_until": "有效期至 %s",
+
+	// devices
+	"cmd.devices.desc": "显示所有已连接的设备",
+	"err.list_devices": "获取设备列表错误",
+
+	// info
+	"cmd.info.desc":       "显示当前设备信息",
+	"cmd.info.opt.domain": "lockdown 域，例如 com.apple.disk_usage，默认为全局域",
+	"cmd.info.opt.key":    "只显示指定的键，未指定时显示整个域",
+	"err.device_info":     "获取设备信息错误",
+
+	// apps
+	"cmd.apps.desc":          "显示当前设备应用列表",
+	"cmd.apps.arg.name":      "应用名称",
+	"cmd.install.desc":       "安装应用",
+	"cmd.install.arg.ipa":    "IPA文件路径",
+	"cmd.install.example":    "{$binName} {$cmd} $HOME/Downloads/example.ipa",
+	"cmd.install.opt.resume": "上次上传中断时只上传 IPA 的剩余部分",
+	"cmd.install.opt.verify": "安装前校验已上传 IPA 的 SHA-1",
+	"err.missing_ipa":        "未传入IPA文件路径",
+	"msg.uploading":          "正在上传...",
+	"err.upload_ipa":         "IPA文件上传错误",
+	"err.verify_ipa":         "IPA文件校验错误",
+	"err.install":            "安装应用错误",
+	"cmd.uninstall.desc":     "卸载应用",
+	"cmd.uninstall.arg.id":   "应用BundleID",
+	"cmd.uninstall.example":  "{$binName} {$cmd} com.xxx.xxx",
+	"err.missing_bundle_id":  "未传入应用BundleID",
+	"err.uninstall":          "卸载应用[%s]错误",
+	"msg.uninstalled":        "应用卸载完成",
+
+	// processes
+	"cmd.procs.desc":    "显示当前设备进程列表",
+	"cmd.kill.desc":     "结束进程",
+	"cmd.kill.arg.proc": "PID或进程名",
+	"cmd.respring.desc": "重启 SpringBoard",
+
+	// system
+	"cmd.reboot.desc":       "重启当前设备，重启后需要重新越狱",
+	"cmd.syslog.desc":       "打印系统日志",
+	"cmd.syslog.arg.filter": "日志过滤字符串，支持过滤进程名或模块名",
+	"err.read_syslog":       "读取系统日志错误",
+
+	// shell
+	"cmd.shell.desc":    "创建SSH交互环境",
+	"err.ssh_client":    "创建SSH客户端错误",
+	"err.ssh_session":   "获取SSH会话错误",
+	"err.ssh_terminal":  "创建SSH终端错误",
+	"err.ssh_term_size": "获取SSH终端窗口大小错误",
+	"err.ssh_pty":       "请求SSH终端窗口错误",
+	"err.ssh_shell":     "启动SSH终端窗口错误",
+	"cmd.run.desc":      "执行任何SHELL命令",
+	"cmd.run.arg.cmd":   "SHELL命令列表",
+	"err.run_shell":     "执行SHELL命令错误",
+	"cmd.redaemon.desc": "重启守护进程",
+	"cmd.scp.desc":      "通过SSH传递文件",
+	"cmd.scp.arg.path":  "本地或远程文件路径",
+	"err.scp_args":      "SCP参数错误",
+	"err.ssh_connect":   "连接SSH错误",
+	"err.scp":           "SCP错误",
+
+	// forward
+	"cmd.forward.desc":       "映射设备端口到本地端口(替代iproxy)",
+	"cmd.forward.arg.local":  "本机端口",
+	"cmd.forward.arg.remote": "设备端口",
+	"cmd.forward.example":    "{$binName} {$cmd} 本机端口 设备端口",
+	"msg.forwarding":         "正在将 localhost:%d 转发到设备端口 %d，按 Ctrl-C 停止",
+
+	// pcap
+	"cmd.pcap.desc":     "网络抓包",
+	"cmd.pcap.arg.file": "PCAP文件保存路径",
+	"cmd.pcap.arg.proc": "进程名称",
+	"err.pcap":          "抓包错误",
+
+	// debug
+	"cmd.dbgserver.desc": "启动设备上的 debug-server",
+	"cmd.lldb.desc":      "多窗口 lldb 调试",
+	"lldb.title":         "lldb 浏览器",
+	"lldb.quit":          "CTRL+C 退出",
+
+	// watch
+	"cmd.watch.desc":     "监听设备连接和断开",
+	"err.listen":         "监听设备错误",
+	"msg.attached":       "已连接",
+	"msg.detached":       "已断开",
+	"msg.paired_event":   "已配对",
+	"err.usbmuxd_closed": "usbmuxd 连接已断开",
+
+	// pair
+	"cmd.pair.desc":          "与设备配对，生成证书并保存配对记录",
+	"cmd.pair.opt.timeout":   "等待用户在设备上点击信任的最长秒数",
+	"msg.unlock_device":      "设备已锁定，请先解锁设备...",
+	"msg.tap_trust":          "请在设备上点击\"信任\"...",
+	"err.pair_denied":        "用户拒绝了配对请求",
+	"err.pair":               "配对错误",
+	"msg.paired":             "配对成功: %s (HostID: %s)",
+	"cmd.pair.validate.desc": "检查设备是否仍然信任本机",
+	"err.pair_invalid":       "配对无效",
+	"msg.pair_valid":         "配对有效: %s",
+	"cmd.pair.unpair.desc":   "解除设备与本机的配对",
+	"err.unpair":             "解除配对错误",
+	"msg.unpaired":           "已解除配对: %s",
+
+	// pairrecord
+	"cmd.pairrecord.desc":            "管理 usbmuxd 保存的配对记录",
+	"cmd.pairrecord.show.desc":       "显示设备的配对记录",
+	"err.read_pair_record":           "读取配对记录错误",
+	"err.read_buid":                  "读取 BUID 错误",
+	"msg.buid_mismatch":              " (与本机 BUID %s 不一致)",
+	"cmd.pairrecord.export.desc":     "导出配对记录到 plist 文件",
+	"cmd.pairrecord.export.arg.file": "导出文件路径，默认为 <UDID>.plist",
+	"err.encode_pair_record":         "编码配对记录错误",
+	"msg.exported":                   "已导出配对记录: %s",
+	"cmd.pairrecord.import.desc":     "从 plist 文件导入配对记录",
+	"cmd.pairrecord.import.arg.file": "配对记录文件路径",
+	"err.parse_pair_record":          "解析配对记录错误",
+	"err.missing_host_id":            "配对记录缺少 HostID",
+	"err.save_pair_record":           "保存配对记录错误",
+	"msg.imported":                   "已导入配对记录: %s",
+	"cmd.pairrecord.delete.desc":     "删除 usbmuxd 保存的配对记录",
+	"err.delete_pair_record":         "删除配对记录错误",
+	"msg.deleted":                    "已删除配对记录: %s",
+
+	// fs
+	"cmd.fs.desc":            "通过 AFC 管理媒体分区或应用沙盒中的文件，无需越狱",
+	"cmd.fs.arg.path":        "设备上的路径；\"*.JPG\" 等通配符需加引号，由设备端展开",
+	"cmd.fs.opt.app":         "操作该 Bundle ID 对应应用的沙盒而不是媒体分区，应用需为开发签名",
+	"cmd.fs.opt.documents":   "配合 --app 使用，只打开应用的 Documents 目录，适用于开启了文件共享的应用",
+	"cmd.fs.opt.recursive":   "递归复制或删除目录",
+	"cmd.fs.opt.verify":      "比较每个已复制文件与设备上文件的 SHA-1，设备不支持计算哈希时读回文件校验",
+	"cmd.fs.ls.desc":         "列出文件",
+	"cmd.fs.ls.opt.long":     "显示类型、大小和修改时间",
+	"cmd.fs.pull.desc":       "从设备复制文件，保留修改时间",
+	"cmd.fs.pull.arg.paths":  "设备路径，最后一个为本地目标路径",
+	"cmd.fs.push.desc":       "复制文件到设备，保留修改时间",
+	"cmd.fs.push.arg.paths":  "本地路径，最后一个为设备目标路径",
+	"cmd.fs.push.opt.resume": "上次推送中断时只发送文件的剩余部分",
+	"cmd.fs.rm.desc":         "删除文件",
+	"cmd.fs.mkdir.desc":      "创建目录，包括缺少的上级目录",
+	"cmd.fs.mv.desc":         "移动或重命名文件",
+	"cmd.fs.mv.arg.paths":    "设备路径，最后一个为目标路径",
+	"cmd.fs.stat.desc":       "显示文件的 AFC 属性",
+	"cmd.fs.tree.desc":       "显示目录树",
+	"err.fs_args":            "至少需要 %d 个路径",
+	"err.fs_no_match":        "没有匹配 %s 的文件",
+	"err.fs_is_dir":          "%s 是目录，请使用 -r",
+	"err.fs_not_dir":         "%s 不是目录",
+	"err.fs_root":            "拒绝删除根目录",
+	"err.fs_list":            "列出 %s 错误",
+	"err.fs_stat":            "读取 %s 属性错误",
+	"err.fs_pull":            "从设备复制 %s 错误",
+	"err.fs_push":            "复制 %s 到设备错误",
+	"err.fs_mtime":           "设置 %s 修改时间错误",
+	"err.fs_verify":          "校验 %s 错误",
+	"err.fs_remove":          "删除 %s 错误",
+	"err.fs_mkdir":           "创建目录 %s 错误",
+	"err.fs_move":            "移动 %s 错误",
+	"err.fs_app":             "打开应用 %s 的沙盒错误",
+	"msg.fs_copied":          "已复制 %d 个文件，共 %d 字节",
+	"msg.fs_verified":        "已校验 %d 个文件",
+	"msg.fs_tree_summary":    "%d 个目录，%d 个文件",
+
+	// crash
+	"cmd.crash.desc":                    "收集并符号化 .ips 等崩溃日志，无需越狱",
+	"cmd.crash.opt.process":             "只显示进程名包含该字符串的日志，不区分大小写",
+	"cmd.crash.opt.since":               "只显示此后写入的日志：24h 等时长，或 2006-01-02、\"2006-01-02 15:04\" 等日期",
+	"cmd.crash.opt.delete":              "下载后从设备删除日志",
+	"cmd.crash.arg.dir":                 "本地目录，默认为当前目录",
+	"cmd.crash.ls.desc":                 "列出崩溃日志",
+	"cmd.crash.pull.desc":               "下载崩溃日志，保留修改时间",
+	"cmd.crash.clear.desc":              "从设备删除崩溃日志",
+	"cmd.crash.watch.desc":              "实时打印新写入的崩溃日志",
+	"cmd.crash.watch.opt.interval":      "检查新日志的间隔秒数",
+	"cmd.crash.watch.arg.dir":           "同时将新日志下载到该目录",
+	"cmd.crash.symbolicate.desc":        "使用本地 dSYM 离线符号化 .ips 和 .crash 崩溃日志",
+	"cmd.crash.symbolicate.opt.dsym":    "dSYM 包、二进制文件或要搜索调试符号的目录（如 .xcarchive），可重复指定",
+	"cmd.crash.symbolicate.arg.reports": "崩溃日志文件",
+	"err.crash_dsym":                    "从 %s 加载调试符号错误",
+	"err.crash_parse":                   "解析崩溃日志 %s 错误",
+	"msg.crash_no_dsym":                 "%s：没有 %s <%s> 的 dSYM，其调用帧未被符号化",
+	"err.crash_list":                    "列出崩溃日志错误",
+	"err.crash_pull":                    "下载崩溃日志 %s 错误",
+	"err.crash_remove":                  "删除崩溃日志 %s 错误",
+	"err.crash_since":                   "无效的 --since 值：%s",
+	"err.crash_interval":                "--interval 至少为 1 秒",
+	"msg.crash_pulled":                  "已下载 %d 个崩溃日志到 %s",
+	"msg.crash_cleared":                 "已删除 %d 个崩溃日志",
+
+	// unregistered commands
+	"cmd.cydia.desc":           "Cydia 插件仓库",
+	"cmd.cydia.view.desc":      "展示插件列表",
+	"cmd.cydia.search.desc":    "搜索插件",
+	"cmd.cydia.install.desc":   "安装插件",
+	"cmd.cydia.uninstall.desc": "卸载插件",
+	"cmd.cydia.upgrade.desc":   "升级插件",
+	"cmd.cydia.publish.desc":   "发布插件",
+	"cmd.frida.desc":           "Frida 脚本仓库",
+	"cmd.frida.list.desc":      "脚本列表",
+	"cmd.frida.search.desc":    "搜索脚本",
+	"cmd.frida.get.desc":       "下载脚本",
+	"cmd.donation.desc":        "捐助作者，保持更新能力",
+}
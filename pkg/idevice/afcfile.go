@@ -0,0 +1,444 @@
+package idevice
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// AFCFile is a file opened through a FileManagerService. It implements
+// io.Reader, io.Writer, io.Seeker, io.ReaderAt and io.Closer, so it can be
+// used with io.Copy and friends. Reads and writes are sent in chunks of at
+// most DefaultChunkSize.
+type AFCFile struct {
+	fm     *FileManagerService
+	name   string
+	handle uint64
+
+	// mu keeps ReadAt from racing with Read, Write and Seek, since it
+	// moves the file position and puts it back.
+	mu     sync.Mutex
+	closed bool
+}
+
+// OpenFile opens name on the device in mode.
+func (f *FileManagerService) OpenFile(name string, mode FileMode) (*AFCFile, error) {
+	return f.OpenFileContext(context.Background(), name, mode)
+}
+
+// OpenFileContext is OpenFile with a context that bounds opening the file.
+func (f *FileManagerService) OpenFileContext(ctx context.Context, name string, mode FileMode) (*AFCFile, error) {
+	handle, err := f.FileOpenContext(ctx, name, mode)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &AFCFile{fm: f, name: name, handle: handle}, nil
+}
+
+// Name returns the path the file was opened with.
+func (a *AFCFile) Name() string {
+	return a.name
+}
+
+func (a *AFCFile) Read(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, fs.ErrClosed
+	}
+
+	return a.read(p)
+}
+
+// read fills p from the current position. It returns io.EOF once the device
+// has nothing more to send.
+func (a *AFCFile) read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(p) > DefaultChunkSize {
+		p = p[:DefaultChunkSize]
+	}
+
+	data, err := a.fm.FileRead(a.handle, uint64(len(p)))
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: a.name, Err: err}
+	}
+	if len(data) == 0 {
+		return 0, io.EOF
+	}
+
+	return copy(p, data), nil
+}
+
+func (a *AFCFile) Write(p []byte) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, fs.ErrClosed
+	}
+
+	n := 0
+	for n < len(p) {
+		chunk := p[n:]
+		if len(chunk) > DefaultChunkSize {
+			chunk = chunk[:DefaultChunkSize]
+		}
+		if err := a.fm.FileWrite(a.handle, chunk); err != nil {
+			return n, &fs.PathError{Op: "write", Path: a.name, Err: err}
+		}
+		n += len(chunk)
+	}
+
+	return n, nil
+}
+
+func (a *AFCFile) Seek(offset int64, whence int) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, fs.ErrClosed
+	}
+
+	return a.seek(offset, whence)
+}
+
+func (a *AFCFile) seek(offset int64, whence int) (int64, error) {
+	if err := a.fm.FileSeek(a.handle, offset, whence); err != nil {
+		return 0, &fs.PathError{Op: "seek", Path: a.name, Err: err}
+	}
+
+	pos, err := a.fm.FileTell(a.handle)
+	if err != nil {
+		return 0, &fs.PathError{Op: "seek", Path: a.name, Err: err}
+	}
+
+	return int64(pos), nil
+}
+
+// ReadAt reads len(p) bytes at off. AFC has no positioned read on every
+// iOS version, so it seeks to off, reads and seeks back; the position seen
+// by Read is unchanged.
+func (a *AFCFile) ReadAt(p []byte, off int64) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, fs.ErrClosed
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: a.name, Err: xerrors.New("negative offset")}
+	}
+
+	pos, err := a.seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := a.seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n < len(p) && err == nil {
+		var nr int
+		nr, err = a.read(p[n:])
+		n += nr
+	}
+
+	if _, serr := a.seek(pos, io.SeekStart); serr != nil && (err == nil || err == io.EOF) {
+		err = serr
+	}
+
+	return n, err
+}
+
+// Stat returns the file's attributes.
+func (a *AFCFile) Stat() (fs.FileInfo, error) {
+	fi, err := statAFC(a.fm, a.name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: a.name, Err: err}
+	}
+
+	return fi, nil
+}
+
+// Truncate changes the size of the file.
+func (a *AFCFile) Truncate(size int64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return fs.ErrClosed
+	}
+	if err := a.fm.FileTruncate(a.handle, uint64(size)); err != nil {
+		return &fs.PathError{Op: "truncate", Path: a.name, Err: err}
+	}
+
+	return nil
+}
+
+// Close closes the handle on the device. The FileManagerService stays open.
+func (a *AFCFile) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return fs.ErrClosed
+	}
+	a.closed = true
+
+	if err := a.fm.FileClose(a.handle); err != nil {
+		return &fs.PathError{Op: "close", Path: a.name, Err: err}
+	}
+
+	return nil
+}
+
+// AFCFileInfo describes a file on the device. Sys returns the MapResult of
+// GetFileInfo.
+type AFCFileInfo struct {
+	name  string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+	info  MapResult
+}
+
+func (fi *AFCFileInfo) Name() string       { return fi.name }
+func (fi *AFCFileInfo) Size() int64        { return fi.size }
+func (fi *AFCFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *AFCFileInfo) ModTime() time.Time { return fi.mtime }
+func (fi *AFCFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *AFCFileInfo) Sys() interface{}   { return fi.info }
+
+// LinkTarget returns where a symbolic link points, or "" for other files.
+func (fi *AFCFileInfo) LinkTarget() string {
+	target, _ := fi.info["LinkTarget"].(string)
+	return target
+}
+
+func statAFC(fm *FileManagerService, name string) (*AFCFileInfo, error) {
+	info, err := fm.GetFileInfo(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAFCFileInfo(path.Base(name), info), nil
+}
+
+func newAFCFileInfo(name string, info MapResult) *AFCFileInfo {
+	fi := &AFCFileInfo{name: name, info: info}
+
+	if s, ok := info["st_size"].(string); ok {
+		fi.size, _ = strconv.ParseInt(s, 10, 64)
+	}
+	if s, ok := info["st_mtime"].(string); ok {
+		if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+			fi.mtime = time.Unix(0, ns)
+		}
+	}
+	// AFC does not report permissions; the media partition is writable by
+	// the mobile user.
+	switch info["st_ifmt"] {
+	case "S_IFDIR":
+		fi.mode = fs.ModeDir | 0755
+	case "S_IFLNK":
+		fi.mode = fs.ModeSymlink | 0777
+	default:
+		fi.mode = 0644
+	}
+
+	return fi
+}
+
+// AFCFS is a read-only fs.FS over a FileManagerService, for code written
+// against io/fs such as fs.WalkDir or http.FS. Names are slash separated and
+// relative to the root of the service, as fs.ValidPath requires.
+type AFCFS struct {
+	fm *FileManagerService
+}
+
+// FS returns the service as an fs.FS. It also implements fs.ReadDirFS,
+// fs.ReadFileFS and fs.StatFS.
+func (f *FileManagerService) FS() *AFCFS {
+	return &AFCFS{fm: f}
+}
+
+// afcName maps an fs.FS name to an AFC path.
+func afcName(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return "/", nil
+	}
+
+	return "/" + name, nil
+}
+
+// Open opens name for reading. Directories are returned as fs.ReadDirFile.
+func (a *AFCFS) Open(name string) (fs.File, error) {
+	p, err := afcName("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := statAFC(a.fm, p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	fi.name = path.Base(name)
+
+	if fi.IsDir() {
+		return &afcDir{fs: a, name: name, info: fi}, nil
+	}
+
+	handle, err := a.fm.FileOpen(p, AFC_FOPEN_RDONLY)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &afcFSFile{AFCFile: &AFCFile{fm: a.fm, name: name, handle: handle}, info: fi}, nil
+}
+
+// Stat returns the attributes of name without following a final symbolic
+// link.
+func (a *AFCFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := afcName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := statAFC(a.fm, p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	fi.name = path.Base(name)
+
+	return fi, nil
+}
+
+// ReadFile returns the content of name.
+func (a *AFCFS) ReadFile(name string) ([]byte, error) {
+	f, err := a.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// ReadDir returns the entries of the directory name sorted by name.
+func (a *AFCFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := afcName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := a.fm.ReadDir(p)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, n := range names {
+		if n == "" || n == "." || n == ".." {
+			continue
+		}
+		fi, err := statAFC(a.fm, path.Join(p, n))
+		if err != nil {
+			// Removed since the listing.
+			if xerrors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, afcDirEntry{fi})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// afcFSFile is a file opened through AFCFS, which already knows its
+// attributes.
+type afcFSFile struct {
+	*AFCFile
+	info *AFCFileInfo
+}
+
+func (f *afcFSFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// afcDir is a directory opened through AFCFS.
+type afcDir struct {
+	fs      *AFCFS
+	name    string
+	info    *AFCFileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *afcDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *afcDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: xerrors.New("is a directory")}
+}
+
+func (d *afcDir) Close() error {
+	return nil
+}
+
+// ReadDir lists the directory on the first call and then hands out n
+// entries at a time, as fs.ReadDirFile requires.
+func (d *afcDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+type afcDirEntry struct {
+	info *AFCFileInfo
+}
+
+func (e afcDirEntry) Name() string               { return e.info.Name() }
+func (e afcDirEntry) IsDir() bool                { return e.info.IsDir() }
+func (e afcDirEntry) Type() fs.FileMode          { return e.info.Mode().Type() }
+func (e afcDirEntry) Info() (fs.FileInfo, error) { return e.info, nil }
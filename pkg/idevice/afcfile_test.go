@@ -0,0 +1,101 @@
+package idevice
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestAFCFile(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+
+	f, err := fileService.OpenFile("notes.txt", AFC_FOPEN_WR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, strings.NewReader("hello afc file")); err != nil {
+		t.Fatal(err)
+	}
+	if pos, err := f.Seek(6, io.SeekStart); err != nil || pos != 6 {
+		t.Fatalf("Seek = %d, %v", pos, err)
+	}
+
+	buf := make([]byte, 5)
+	if n, err := f.ReadAt(buf, 0); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("ReadAt = %q, %v", buf[:n], err)
+	}
+	// ReadAt leaves the position alone.
+	rest, err := io.ReadAll(f)
+	if err != nil || string(rest) != "afc file" {
+		t.Fatalf("ReadAll after ReadAt = %q, %v", rest, err)
+	}
+	if n, err := f.ReadAt(buf, 10); err != io.EOF || string(buf[:n]) != "file" {
+		t.Fatalf("ReadAt past the end = %q, %v, want io.EOF", buf[:n], err)
+	}
+
+	if err := f.Truncate(5); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Name() != "notes.txt" || fi.Size() != 5 || fi.IsDir() {
+		t.Fatalf("Stat = %s %d %v", fi.Name(), fi.Size(), fi.Mode())
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Read(buf); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("Read after Close error = %v, want fs.ErrClosed", err)
+	}
+	if data, _ := afc.ReadFile("notes.txt"); string(data) != "hello" {
+		t.Fatalf("content = %q", data)
+	}
+
+	if _, err := fileService.OpenFile("missing.txt", AFC_FOPEN_RDONLY); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("OpenFile error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestAFCFS(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+	afc.WriteFile("DCIM/100APPLE/IMG_0001.JPG", bytes.Repeat([]byte{0xff}, 3000))
+	afc.WriteFile("DCIM/100APPLE/IMG_0002.JPG", []byte("jpeg"))
+	afc.WriteFile("Downloads/readme.txt", []byte("read me"))
+
+	fsys := fileService.FS()
+	if err := fstest.TestFS(fsys, "DCIM/100APPLE/IMG_0001.JPG", "DCIM/100APPLE/IMG_0002.JPG", "Downloads/readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	var walked []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		walked = append(walked, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ". DCIM DCIM/100APPLE DCIM/100APPLE/IMG_0001.JPG DCIM/100APPLE/IMG_0002.JPG Downloads Downloads/readme.txt"
+	if got := strings.Join(walked, " "); got != want {
+		t.Fatalf("WalkDir = %s, want %s", got, want)
+	}
+
+	if _, err := fs.Stat(fsys, "Downloads/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := fsys.Open("../etc/passwd"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Open error = %v, want fs.ErrInvalid", err)
+	}
+}
@@ -0,0 +1,203 @@
+package idevice
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strconv"
+
+	"golang.org/x/xerrors"
+)
+
+// DefaultPipelineDepth is how many FileRefWrite packets an upload keeps in
+// flight before it waits for the first status. Waiting for every status
+// leaves the link idle for a round trip per chunk.
+var DefaultPipelineDepth = 4
+
+// UploadOption configures FileUpload.
+type UploadOption func(u *upload)
+
+type upload struct {
+	resume bool
+	depth  int
+}
+
+// WithResume continues an upload that was cut short: when remote already
+// exists, only the rest of local is sent. It needs local to be an io.Seeker,
+// such as an *os.File. The upload fails, keeping remote, when local cannot
+// seek or remote is larger than what is left of local.
+func WithResume() UploadOption {
+	return func(u *upload) {
+		u.resume = true
+	}
+}
+
+// WithPipelineDepth sets how many writes are in flight at once. 1 waits for
+// each write to be acknowledged before sending the next.
+func WithPipelineDepth(depth int) UploadOption {
+	return func(u *upload) {
+		if depth > 0 {
+			u.depth = depth
+		}
+	}
+}
+
+// FileUpload copies local to the device path remote, replacing it. cb, which
+// may be nil, is called with the size of remote each time the device
+// acknowledges a chunk, counting the part kept by WithResume.
+func (f *FileManagerService) FileUpload(local io.Reader, remote string, cb func(written int64), opts ...UploadOption) error {
+	return f.FileUploadContext(context.Background(), local, remote, cb, opts...)
+}
+
+// FileUploadContext is FileUpload with a context.
+func (f *FileManagerService) FileUploadContext(ctx context.Context, local io.Reader, remote string, cb func(written int64), opts ...UploadOption) error {
+	u := upload{depth: DefaultPipelineDepth}
+	for _, opt := range opts {
+		opt(&u)
+	}
+
+	var offset int64
+	if u.resume {
+		seeker, ok := local.(io.Seeker)
+		if !ok {
+			return xerrors.Errorf("afc: resuming %s: local file is not seekable", remote)
+		}
+		var err error
+		if offset, err = f.resumeOffset(ctx, seeker, remote); err != nil {
+			return err
+		}
+	}
+
+	mode := AFC_FOPEN_WRONLY
+	if offset > 0 {
+		// r+ keeps what is there.
+		mode = AFC_FOPEN_RW
+	}
+	handle, err := f.FileOpenContext(ctx, remote, mode)
+	if err != nil {
+		return err
+	}
+	defer func(f *FileManagerService, handle uint64) {
+		_ = f.FileCloseContext(ctx, handle)
+	}(f, handle)
+
+	if offset > 0 {
+		if err := f.FileSeekContext(ctx, handle, offset, io.SeekStart); err != nil {
+			return err
+		}
+		if cb != nil {
+			cb(offset)
+		}
+	}
+
+	return f.writePipelined(ctx, handle, local, u.depth, offset, cb)
+}
+
+// resumeOffset returns how much of local is already in remote, and skips
+// that much of local. It is 0 when remote is missing. A remote larger than
+// the rest of local, or a size it cannot read, is an error rather than 0,
+// which would truncate what was uploaded.
+func (f *FileManagerService) resumeOffset(ctx context.Context, local io.Seeker, remote string) (int64, error) {
+	info, err := f.GetFileInfoContext(ctx, remote)
+	if xerrors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	s, ok := info["st_size"].(string)
+	if !ok {
+		return 0, xerrors.Errorf("afc: %s has no st_size", remote)
+	}
+	size, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, xerrors.Errorf("afc: st_size of %s: %w", remote, err)
+	}
+	if size == 0 {
+		return 0, nil
+	}
+
+	start, err := local.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := local.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if size > end-start {
+		return 0, xerrors.Errorf("afc: %s is larger than the local file, not resumable", remote)
+	}
+	if _, err := local.Seek(start+size, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// writePipelined writes local to handle in DefaultChunkSize packets, keeping
+// up to depth of them unacknowledged. written is where the file position
+// starts, for cb.
+func (f *FileManagerService) writePipelined(ctx context.Context, handle uint64, local io.Reader, depth int, written int64, cb func(int64)) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer withContext(ctx, f.conn)(&err)
+
+	type pending struct {
+		num uint64
+		n   int
+	}
+	var inflight []pending
+	// drain reads the statuses still owed, so that the service can be used
+	// again after a failed write.
+	drain := func() {
+		for _, p := range inflight {
+			_, _ = f.recv(p.num)
+		}
+	}
+
+	param := afcUint64(handle)
+	buf := make([]byte, DefaultChunkSize)
+	eof := false
+	for {
+		for !eof && len(inflight) < depth {
+			// Only what was read is sent; the tail of buf is stale.
+			n, err := io.ReadFull(local, buf)
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				drain()
+				return err
+			}
+			if n == 0 {
+				break
+			}
+			if err := f.Send(AFC_OP_FILE_WRITE, param, buf[:n]); err != nil {
+				drain()
+				return err
+			}
+			inflight = append(inflight, pending{num: f.header.PacketNum, n: n})
+		}
+		if len(inflight) == 0 {
+			return nil
+		}
+
+		ret, err := f.recv(inflight[0].num)
+		if err != nil {
+			inflight = inflight[1:]
+			drain()
+			return err
+		}
+		n := inflight[0].n
+		inflight = inflight[1:]
+		if err := ret.status(); err != nil {
+			drain()
+			return err
+		}
+
+		written += int64(n)
+		if cb != nil {
+			cb(written)
+		}
+	}
+}
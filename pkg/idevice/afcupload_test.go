@@ -0,0 +1,269 @@
+package idevice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func withChunkSize(t *testing.T, size int) {
+	old := DefaultChunkSize
+	DefaultChunkSize = size
+	t.Cleanup(func() { DefaultChunkSize = old })
+}
+
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	return data
+}
+
+func TestFileUpload_Tail(t *testing.T) {
+	withChunkSize(t, 100)
+	afc, fileService := newTestFileManager(t)
+	afc.WriteFile("app.ipa", bytes.Repeat([]byte{0xff}, 400))
+
+	data := testData(250)
+	var progress []int64
+	if err := fileService.FileUpload(bytes.NewReader(data), "app.ipa", func(written int64) {
+		progress = append(progress, written)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := afc.ReadFile("app.ipa"); !bytes.Equal(got, data) {
+		t.Fatalf("uploaded %d bytes, want the %d bytes read", len(got), len(data))
+	}
+	if want := []int64{100, 200, 250}; !reflect.DeepEqual(progress, want) {
+		t.Fatalf("progress = %v, want %v", progress, want)
+	}
+}
+
+func TestFileUpload_Resume(t *testing.T) {
+	withChunkSize(t, 100)
+	afc, fileService := newTestFileManager(t)
+	data := testData(250)
+
+	// An interrupted upload left the first 120 bytes.
+	afc.WriteFile("app.ipa", data[:120])
+	var progress []int64
+	if err := fileService.FileUpload(bytes.NewReader(data), "app.ipa", func(written int64) {
+		progress = append(progress, written)
+	}, WithResume()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := afc.ReadFile("app.ipa"); !bytes.Equal(got, data) {
+		t.Fatalf("resumed upload = %d bytes, want %d", len(got), len(data))
+	}
+	if want := []int64{120, 220, 250}; !reflect.DeepEqual(progress, want) {
+		t.Fatalf("progress = %v, want %v", progress, want)
+	}
+
+}
+
+func TestFileUpload_ResumeRefused(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+	data := testData(250)
+
+	// A remote file larger than local is not a prefix of it.
+	afc.WriteFile("app.ipa", testData(400))
+	if err := fileService.FileUpload(bytes.NewReader(data), "app.ipa", nil, WithResume()); err == nil {
+		t.Fatal("FileUpload resumed over a larger file")
+	}
+	if got, _ := afc.ReadFile("app.ipa"); len(got) != 400 {
+		t.Fatalf("remote is %d bytes after a refused resume, want 400 kept", len(got))
+	}
+
+	// Without Seek there is no telling how much was uploaded.
+	afc.WriteFile("app.ipa", data[:120])
+	if err := fileService.FileUpload(bytes.NewBuffer(data), "app.ipa", nil, WithResume()); err == nil {
+		t.Fatal("FileUpload resumed from a reader that cannot seek")
+	}
+	if got, _ := afc.ReadFile("app.ipa"); len(got) != 120 {
+		t.Fatalf("remote is %d bytes after a refused resume, want 120 kept", len(got))
+	}
+}
+
+// pipelineAFC answers FileRefWrite only once depth of them are waiting, or
+// when no more arrive, and records the most it saw waiting.
+type pipelineAFC struct {
+	depth   int
+	maxSeen int
+	written int
+}
+
+func (p *pipelineAFC) Serve(conn net.Conn) {
+	reply := func(num, op uint64, param []byte) {
+		hdr := AFCHeader{EntireLength: uint64(40 + len(param)), ThisLength: uint64(40 + len(param)), PacketNum: num, Operation: op}
+		copy(hdr.Magic[:], "CFA6LPAA")
+		_ = binary.Write(conn, binary.LittleEndian, hdr)
+		_, _ = conn.Write(param)
+	}
+
+	var waiting []uint64
+	flush := func() {
+		for _, num := range waiting {
+			reply(num, AFC_OP_STATUS, afcUint64(0))
+		}
+		waiting = nil
+	}
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		var hdr AFCHeader
+		if err := binary.Read(conn, binary.LittleEndian, &hdr); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				flush()
+				continue
+			}
+			return
+		}
+		_ = conn.SetReadDeadline(time.Time{})
+		body := make([]byte, hdr.EntireLength-40)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+
+		switch hdr.Operation {
+		case AFC_OP_FILE_OPEN:
+			reply(hdr.PacketNum, AFC_OP_FILE_OPEN_RES, afcUint64(1))
+		case AFC_OP_FILE_WRITE:
+			p.written += len(body) - 8
+			waiting = append(waiting, hdr.PacketNum)
+			if len(waiting) > p.maxSeen {
+				p.maxSeen = len(waiting)
+			}
+			if len(waiting) == p.depth {
+				flush()
+			}
+		default:
+			reply(hdr.PacketNum, AFC_OP_STATUS, afcUint64(0))
+		}
+	}
+}
+
+func TestFileUpload_Pipelined(t *testing.T) {
+	withChunkSize(t, 100)
+	dev, device := newTestDevice(t)
+	svc := &pipelineAFC{depth: 3}
+	dev.AddService(FileManagerServiceName, idevicetest.ServiceFunc(svc.Serve))
+
+	fileService, err := NewFileManagerService(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileService.Close()
+
+	data := testData(1000)
+	if err := fileService.FileUpload(bytes.NewReader(data), "app.ipa", nil, WithPipelineDepth(3)); err != nil {
+		t.Fatal(err)
+	}
+	if svc.maxSeen != 3 || svc.written != len(data) {
+		t.Fatalf("%d writes in flight, %d bytes written; want 3 and %d", svc.maxSeen, svc.written, len(data))
+	}
+}
+
+// failingConn fails the header of the fail-th FileRefWrite before any of it
+// is sent, leaving the replies to the earlier writes on the connection.
+type failingConn struct {
+	IConn
+	fail   int
+	writes int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (c *failingConn) Writer() io.Writer {
+	return failingWriter{c}
+}
+
+type failingWriter struct {
+	c *failingConn
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	if len(p) == 40 && binary.LittleEndian.Uint64(p[32:]) == AFC_OP_FILE_WRITE {
+		w.c.writes++
+		if w.c.writes == w.c.fail {
+			return 0, errWriteFailed
+		}
+	}
+
+	return w.c.IConn.Writer().Write(p)
+}
+
+func TestFileUpload_SendError(t *testing.T) {
+	withChunkSize(t, 100)
+	afc, fileService := newTestFileManager(t)
+	fileService.conn = &failingConn{IConn: fileService.conn, fail: 3}
+
+	err := fileService.FileUpload(bytes.NewReader(testData(1000)), "app.ipa", nil)
+	if !errors.Is(err, errWriteFailed) {
+		t.Fatalf("FileUpload = %v, want the write error", err)
+	}
+
+	// The replies to the first two writes were read, so the service still
+	// pairs requests with their replies.
+	afc.WriteFile("other", testData(10))
+	info, err := fileService.GetFileInfo("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["st_size"] != "10" {
+		t.Fatalf("st_size = %v, want 10", info["st_size"])
+	}
+	if data, _ := afc.ReadFile("app.ipa"); len(data) != 200 {
+		t.Fatalf("device has %d bytes, want the 200 acknowledged", len(data))
+	}
+}
+
+func TestFileUpload_ResumeBadSize(t *testing.T) {
+	dev, device := newTestDevice(t)
+	opened := false
+	dev.AddService(FileManagerServiceName, idevicetest.ServiceFunc(func(conn net.Conn) {
+		for {
+			var hdr AFCHeader
+			if err := binary.Read(conn, binary.LittleEndian, &hdr); err != nil {
+				return
+			}
+			if _, err := io.CopyN(ioutil.Discard, conn, int64(hdr.EntireLength-40)); err != nil {
+				return
+			}
+
+			op, payload := uint64(AFC_OP_STATUS), afcUint64(0)
+			switch hdr.Operation {
+			case AFC_OP_GET_FILE_INFO:
+				op, payload = AFC_OP_DATA, []byte("st_size\x00lots\x00st_ifmt\x00S_IFREG\x00")
+			case AFC_OP_FILE_OPEN:
+				opened = true
+			}
+			out := AFCHeader{EntireLength: uint64(40 + len(payload)), ThisLength: 40, PacketNum: hdr.PacketNum, Operation: op}
+			copy(out.Magic[:], "CFA6LPAA")
+			_ = binary.Write(conn, binary.LittleEndian, out)
+			_, _ = conn.Write(payload)
+		}
+	}))
+
+	fileService, err := NewFileManagerService(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileService.Close()
+
+	if err := fileService.FileUpload(bytes.NewReader(testData(250)), "app.ipa", nil, WithResume()); err == nil {
+		t.Fatal("FileUpload succeeded with an unreadable st_size")
+	}
+	if opened {
+		t.Fatal("FileUpload opened, and so truncated, the partial upload")
+	}
+}
@@ -0,0 +1,73 @@
+package idevice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"io"
+
+	"golang.org/x/xerrors"
+)
+
+// Verify checks that the device file remote holds what local reads until
+// EOF. It returns an error matching ErrChecksumMismatch when they differ.
+func (f *FileManagerService) Verify(local io.Reader, remote string) error {
+	return f.VerifyContext(context.Background(), local, remote)
+}
+
+// VerifyContext is Verify with a context.
+func (f *FileManagerService) VerifyContext(ctx context.Context, local io.Reader, remote string) error {
+	h := sha1.New()
+	if _, err := io.Copy(h, local); err != nil {
+		return err
+	}
+	want := h.Sum(nil)
+
+	got, err := f.FileSHA1Context(ctx, remote)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return xerrors.Errorf("%s: device has sha1 %x, local %x: %w", remote, got, want, ErrChecksumMismatch)
+	}
+
+	return nil
+}
+
+// FileSHA1 returns the SHA-1 of the device file remote. It asks the device
+// with Hash and, when the device does not support that, reads the
+// file back and hashes it on the host.
+func (f *FileManagerService) FileSHA1(remote string) ([]byte, error) {
+	return f.FileSHA1Context(context.Background(), remote)
+}
+
+// FileSHA1Context is FileSHA1 with a context.
+func (f *FileManagerService) FileSHA1Context(ctx context.Context, remote string) ([]byte, error) {
+	sum, err := f.HashContext(ctx, remote)
+	if err == nil && len(sum) == sha1.Size {
+		return sum, nil
+	}
+	// Other digest sizes are not SHA-1, and are read back as well.
+	if err != nil && !hashUnsupported(err) {
+		return nil, err
+	}
+
+	file, err := f.OpenFileContext(ctx, remote, AFC_FOPEN_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := sha1.New()
+	if _, err := io.CopyBuffer(h, file, make([]byte, DefaultChunkSize)); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// hashUnsupported reports whether err is how devices without
+// AFC_OP_GET_FILE_HASH refuse it.
+func hashUnsupported(err error) bool {
+	return xerrors.Is(err, AFC_E_OP_NOT_SUPPORTED) || xerrors.Is(err, AFC_E_UNKNOWN_PACKET_TYPE)
+}
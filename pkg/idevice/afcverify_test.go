@@ -0,0 +1,49 @@
+package idevice
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestHashRange(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+	data := testData(300)
+	afc.WriteFile("app.ipa", data)
+
+	hash, err := fileService.HashRange("app.ipa", 100, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sha1.Sum(data[100:150]); !bytes.Equal(hash, want[:]) {
+		t.Fatalf("HashRange = %x, want %x", hash, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, noHash := range []bool{false, true} {
+		afc, fileService := newTestFileManager(t)
+		if noHash {
+			afc.DisableHash()
+		}
+		data := testData(250)
+		afc.WriteFile("app.ipa", data)
+
+		if err := fileService.Verify(bytes.NewReader(data), "app.ipa"); err != nil {
+			t.Fatalf("noHash=%v: Verify = %v", noHash, err)
+		}
+
+		data[200]++
+		err := fileService.Verify(bytes.NewReader(data), "app.ipa")
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("noHash=%v: Verify of changed data = %v, want ErrChecksumMismatch", noHash, err)
+		}
+
+		err = fileService.Verify(bytes.NewReader(data), "missing.ipa")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("noHash=%v: Verify of missing file = %v, want fs.ErrNotExist", noHash, err)
+		}
+	}
+}
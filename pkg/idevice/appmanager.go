@@ -1,6 +1,8 @@
 package idevice
 
 import (
+	"context"
+
 	"golang.org/x/xerrors"
 	"howett.net/plist"
 )
@@ -32,19 +34,38 @@ type AppInfo struct {
 	UIRequiredDeviceCapabilities []string
 }
 
+// AppManagerServiceName is the lockdown name of installation_proxy.
+const AppManagerServiceName = "com.apple.mobile.installation_proxy"
+
 type AppManagerService struct {
 	conn IConn
 }
 
 func NewAppManagerService(device *DeviceEntry) (*AppManagerService, error) {
-	conn, err := ConnectToService(device, "com.apple.mobile.installation_proxy")
+	return NewAppManagerServiceContext(context.Background(), device)
+}
+
+// NewAppManagerServiceContext is NewAppManagerService with a context.
+func NewAppManagerServiceContext(ctx context.Context, device *DeviceEntry) (*AppManagerService, error) {
+	conn, err := ConnectToServiceContext(ctx, device, AppManagerServiceName)
 	if err != nil {
 		return nil, err
 	}
 
+	return newAppManagerService(conn), nil
+}
+
+func newAppManagerService(conn IConn) *AppManagerService {
 	return &AppManagerService{
 		conn: conn,
-	}, nil
+	}
+}
+
+// SetPlistFormat selects the plist encoding for requests. installation_proxy
+// answers in the format it was asked in, and binary plists make large Browse
+// responses much cheaper to produce and parse.
+func (a *AppManagerService) SetPlistFormat(format int) {
+	a.conn.SetPlistFormat(format)
 }
 
 func (a *AppManagerService) Close() {
@@ -58,6 +79,11 @@ type AppInstallResponse struct {
 }
 
 func (a *AppManagerService) GetApplications() ([]AppInfo, error) {
+	return a.GetApplicationsContext(context.Background())
+}
+
+// GetApplicationsContext is GetApplications with a context.
+func (a *AppManagerService) GetApplicationsContext(ctx context.Context) ([]AppInfo, error) {
 	clientOptions := map[string]interface{}{
 		"ApplicationType": "User",
 		"ReturnAttributes": []string{
@@ -83,13 +109,13 @@ func (a *AppManagerService) GetApplications() ([]AppInfo, error) {
 	}
 
 	param := map[string]interface{}{"ClientOptions": clientOptions, "Command": "Browse"}
-	userApps, err := a.browseApps(param)
+	userApps, err := a.browseApps(ctx, param)
 	if err != nil {
 		return nil, err
 	}
 
 	clientOptions["ApplicationType"] = "System"
-	sysApps, err := a.browseApps(param)
+	sysApps, err := a.browseApps(ctx, param)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +123,9 @@ func (a *AppManagerService) GetApplications() ([]AppInfo, error) {
 	return append(userApps, sysApps...), nil
 }
 
-func (a *AppManagerService) browseApps(param map[string]interface{}) ([]AppInfo, error) {
+func (a *AppManagerService) browseApps(ctx context.Context, param map[string]interface{}) (_ []AppInfo, err error) {
+	defer withContext(ctx, a.conn)(&err)
+
 	bs, err := a.conn.Encode(param)
 	if err != nil {
 		return nil, err
@@ -135,6 +163,13 @@ func (a *AppManagerService) browseApps(param map[string]interface{}) ([]AppInfo,
 }
 
 func (a *AppManagerService) Install(pkgPath string, cb func(AppInstallResponse)) error {
+	return a.InstallContext(context.Background(), pkgPath, cb)
+}
+
+// InstallContext is Install with a context, which bounds the whole installation.
+func (a *AppManagerService) InstallContext(ctx context.Context, pkgPath string, cb func(AppInstallResponse)) (err error) {
+	defer withContext(ctx, a.conn)(&err)
+
 	param := map[string]interface{}{"Command": "Install", "PackagePath": pkgPath}
 	bs, err := a.conn.Encode(param)
 	if err != nil {
@@ -171,6 +206,13 @@ func (a *AppManagerService) Install(pkgPath string, cb func(AppInstallResponse))
 }
 
 func (a *AppManagerService) Uninstall(bundleId string) error {
+	return a.UninstallContext(context.Background(), bundleId)
+}
+
+// UninstallContext is Uninstall with a context.
+func (a *AppManagerService) UninstallContext(ctx context.Context, bundleId string) (err error) {
+	defer withContext(ctx, a.conn)(&err)
+
 	param := map[string]interface{}{"Command": "Uninstall", "ApplicationIdentifier": bundleId}
 	bs, err := a.conn.Encode(param)
 	if err != nil {
@@ -1,23 +1,137 @@
 package idevice
 
-import "testing"
+import (
+	"fmt"
+	"testing"
 
-func TestAppManagerService_GetApplications(t *testing.T) {
-	device, err := GetDevice()
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+	"howett.net/plist"
+)
+
+func newTestAppManager(t testing.TB, apps ...map[string]interface{}) (*idevicetest.InstallationProxy, *AppManagerService) {
+	t.Helper()
+
+	if len(apps) == 0 {
+		apps = []map[string]interface{}{
+			{"CFBundleIdentifier": "com.example.one", "CFBundleDisplayName": "One", "ApplicationType": "User"},
+			{"CFBundleIdentifier": "com.example.two", "CFBundleDisplayName": "Two", "ApplicationType": "User"},
+			{"CFBundleIdentifier": "com.example.three", "CFBundleDisplayName": "Three", "ApplicationType": "User"},
+			{"CFBundleIdentifier": "com.apple.Preferences", "CFBundleDisplayName": "Settings", "ApplicationType": "System"},
+		}
+	}
+
+	dev, device := newTestDevice(t)
+	proxy := idevicetest.NewInstallationProxy(apps...)
+	dev.AddService("com.apple.mobile.installation_proxy", proxy)
+
+	service, err := NewAppManagerService(device)
 	if err != nil {
 		t.Fatal(err)
 	}
+	t.Cleanup(service.Close)
 
-	service, err := NewAppManagerService(device)
+	return proxy, service
+}
+
+func TestAppManagerService_GetApplications(t *testing.T) {
+	_, service := newTestAppManager(t)
+
+	apps, err := service.GetApplications()
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer service.Close()
+
+	want := []string{"com.example.one", "com.example.two", "com.example.three", "com.apple.Preferences"}
+	if len(apps) != len(want) {
+		t.Fatalf("got %d apps, want %d", len(apps), len(want))
+	}
+	for i, id := range want {
+		if apps[i].CFBundleIdentifier != id {
+			t.Errorf("apps[%d] = %q, want %q", i, apps[i].CFBundleIdentifier, id)
+		}
+	}
+}
+
+func TestAppManagerService_GetApplicationsBinary(t *testing.T) {
+	_, service := newTestAppManager(t)
+	service.SetPlistFormat(plist.BinaryFormat)
 
 	apps, err := service.GetApplications()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(apps) != 4 || apps[3].CFBundleDisplayName != "Settings" {
+		t.Fatalf("GetApplications = %+v", apps)
+	}
+}
+
+func BenchmarkAppManagerService_GetApplications(b *testing.B) {
+	apps := make([]map[string]interface{}, 0, 300)
+	for i := 0; i < 300; i++ {
+		id := fmt.Sprintf("com.example.app%d", i)
+		apps = append(apps, map[string]interface{}{
+			"ApplicationType":            "User",
+			"CFBundleIdentifier":         id,
+			"CFBundleDisplayName":        fmt.Sprintf("App %d", i),
+			"CFBundleExecutable":         "App",
+			"CFBundleShortVersionString": "1.0.0",
+			"CFBundleVersion":            "100",
+			"Container":                  "/private/var/mobile/Containers/Data/Application/" + id,
+			"Path":                       "/private/var/containers/Bundle/Application/" + id + "/App.app",
+			"Entitlements": map[string]interface{}{
+				"application-identifier":                 "ABCDE12345." + id,
+				"com.apple.developer.team-identifier":    "ABCDE12345",
+				"keychain-access-groups":                 []interface{}{"ABCDE12345." + id},
+				"com.apple.security.application-groups":  []interface{}{"group." + id},
+				"com.apple.developer.associated-domains": []interface{}{"applinks:example.com"},
+			},
+			"UIDeviceFamily":               []interface{}{1, 2},
+			"UIRequiredDeviceCapabilities": []interface{}{"arm64"},
+		})
+	}
+
+	for _, bm := range []struct {
+		name   string
+		format int
+	}{
+		{"XML", plist.XMLFormat},
+		{"Binary", plist.BinaryFormat},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			_, service := newTestAppManager(b, apps...)
+			service.SetPlistFormat(bm.format)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := service.GetApplications(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAppManagerService_InstallUninstall(t *testing.T) {
+	proxy, service := newTestAppManager(t)
 
-	t.Log(apps)
+	var progress []int
+	if err := service.Install("PublicStaging/example.ipa", func(resp AppInstallResponse) {
+		progress = append(progress, resp.PercentComplete)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) == 0 {
+		t.Fatal("install callback was never called")
+	}
+
+	if err := service.Uninstall("com.example.one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Uninstall("com.example.missing"); err == nil {
+		t.Fatal("expected uninstalling a missing app to fail")
+	}
+
+	if got := len(proxy.Apps()); got != 4 {
+		t.Fatalf("got %d apps after install and uninstall, want 4", got)
+	}
 }
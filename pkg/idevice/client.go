@@ -0,0 +1,168 @@
+package idevice
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Logger receives what a Client is doing, one line per call. *log.Logger
+// satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Client is a handle on one usbmuxd. It lists devices and opens Devices;
+// the Devices it opens share its options.
+//
+//	client := idevice.NewClient(idevice.WithTimeout(10 * time.Second))
+//	device, err := client.Device(idevice.DeviceFilter{UDID: udid})
+//	if err != nil {
+//		return err
+//	}
+//	defer device.Close()
+//
+//	afc, err := device.AFC()
+type Client struct {
+	addr    string
+	timeout time.Duration
+	logger  Logger
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithSocketAddress makes the Client dial addr instead of SocketAddress. See
+// SocketAddress for the accepted forms.
+func WithSocketAddress(addr string) Option {
+	return func(c *Client) {
+		c.addr = addr
+	}
+}
+
+// WithTimeout bounds every call made through the Client or its Devices
+// whose context has no deadline of its own. Services returned by a Device
+// are not affected once they are connected.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithLogger makes the Client log connections, sessions and services to
+// logger.
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewClient returns a Client configured by opts. It does not connect to
+// usbmuxd until it is used.
+func NewClient(opts ...Option) *Client {
+	c := &Client{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// defaultClient dials SocketAddress with no timeout and no logging. It backs
+// the functions that take a DeviceEntry.
+var defaultClient = &Client{}
+
+func (c *Client) address() string {
+	if c.addr != "" {
+		return c.addr
+	}
+
+	return SocketAddress
+}
+
+// context applies the Client timeout to ctx unless ctx has a deadline.
+func (c *Client) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || c.timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+	}
+}
+
+func (c *Client) usbConn(ctx context.Context) (*USBConn, error) {
+	conn, err := newUSBConn(ctx, c.address())
+	if err != nil {
+		c.logf("usbmuxd %s: %v", c.address(), err)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Devices returns every device usbmuxd currently knows about.
+func (c *Client) Devices() ([]DeviceEntry, error) {
+	return c.DevicesContext(context.Background())
+}
+
+// DevicesContext is Devices with a context.
+func (c *Client) DevicesContext(ctx context.Context) ([]DeviceEntry, error) {
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+
+	conn, err := c.usbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.ListDevicesContext(ctx)
+}
+
+// Device opens the attached device selected by filter, see SelectDevice. The
+// Device must be closed when done.
+func (c *Client) Device(filter DeviceFilter) (*Device, error) {
+	return c.DeviceContext(context.Background(), filter)
+}
+
+// DeviceContext is Device with a context.
+func (c *Client) DeviceContext(ctx context.Context, filter DeviceFilter) (*Device, error) {
+	list, err := c.DevicesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := SelectDevice(list, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.NewDevice(entry), nil
+}
+
+// NewDevice returns a Device for entry that uses the Client's options.
+func (c *Client) NewDevice(entry *DeviceEntry) *Device {
+	return &Device{Entry: *entry, client: c, ssh: make(map[string]*ssh.Client)}
+}
+
+// Listen streams device events until ctx is done.
+func (c *Client) Listen(ctx context.Context) (<-chan DeviceEvent, error) {
+	conn, err := c.usbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := conn.Listen(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,136 @@
+package idevice
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func TestClient_Device(t *testing.T) {
+	dev := idevicetest.NewDevice(testUDID)
+	afc := idevicetest.NewAFC()
+	afc.WriteFile("DCIM/hello.txt", []byte("hello"))
+	dev.AddService(FileManagerServiceName, afc)
+	dev.AddService(AppManagerServiceName, idevicetest.NewInstallationProxy(
+		map[string]interface{}{"CFBundleIdentifier": "com.example.one", "ApplicationType": "User"},
+	))
+	dev.Handle(27042, idevicetest.ServiceFunc(func(conn net.Conn) {
+		_, _ = io.Copy(conn, conn)
+	}))
+
+	// The Client dials its own address; SocketAddress is left alone.
+	srv, err := idevicetest.NewServer(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	client := NewClient(
+		WithSocketAddress(srv.Addr),
+		WithTimeout(5*time.Second),
+		WithLogger(log.New(&logs, "", 0)),
+	)
+
+	device, err := client.Device(DeviceFilter{UDID: testUDID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+
+	fileService, err := device.AFC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := fileService.GetFileInfo("DCIM/hello.txt")
+	fileService.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["st_size"] != "5" {
+		t.Fatalf("st_size = %v, want 5", info["st_size"])
+	}
+
+	appService, err := device.Apps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apps, err := appService.GetApplications()
+	appService.Close()
+	if err != nil || len(apps) != 1 {
+		t.Fatalf("GetApplications = %v, %v", apps, err)
+	}
+
+	lockdown, err := device.Lockdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, err := lockdown.GetValue("", "DeviceName")
+	lockdown.Close()
+	if err != nil || name != "iPhone" {
+		t.Fatalf("GetValue = %v, %v", name, err)
+	}
+
+	if !strings.Contains(logs.String(), "connected to "+FileManagerServiceName) {
+		t.Fatalf("log does not mention the AFC connection:\n%s", logs.String())
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	forward, err := device.Forward(uint16(port), 27042)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer forward.Close()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "ping\n" {
+		t.Fatalf("forwarded reply = %q, %v", line, err)
+	}
+
+	if n := dev.Lockdown.Sessions(); n != 2 {
+		t.Fatalf("started %d sessions, want 2", n)
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	// A usbmuxd that accepts connections and never answers.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	client := NewClient(WithSocketAddress(ln.Addr().String()), WithTimeout(100*time.Millisecond))
+	if _, err := client.Devices(); err != context.DeadlineExceeded {
+		t.Fatalf("Devices error = %v, want context.DeadlineExceeded", err)
+	}
+}
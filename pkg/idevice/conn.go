@@ -2,11 +2,16 @@ package idevice
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 	"io"
 	"net"
+	"os"
+	"strings"
+	"time"
 
+	"golang.org/x/xerrors"
 	"howett.net/plist"
 )
 
@@ -17,21 +22,106 @@ type IConn interface {
 	Write(data []byte) error
 	Encode(msg interface{}) ([]byte, error)
 	Decode(r io.Reader) ([]byte, error)
+	PlistFormat() int
+	SetPlistFormat(format int)
 	EnableSessionSSL(cert *Certificate) error
 	EnableSessionSSLHandshakeOnly(cert *Certificate) error
+	SetDeadline(t time.Time) error
 }
 
+const DefaultSocketAddress = "/var/run/usbmuxd"
+
+// SocketAddress is the usbmuxd endpoint NewConn dials: a unix socket path
+// ("/var/run/usbmuxd" or "unix:/var/run/usbmuxd") or a TCP address
+// ("127.0.0.1:27015" or "tcp:127.0.0.1:27015"). It defaults to the
+// USBMUXD_SOCKET_ADDRESS environment variable, like libusbmuxd.
+var SocketAddress = socketAddressFromEnv()
+
+// DialTimeout bounds how long NewConn waits for usbmuxd to accept.
+var DialTimeout = 5 * time.Second
+
+func socketAddressFromEnv() string {
+	if addr := os.Getenv("USBMUXD_SOCKET_ADDRESS"); addr != "" {
+		return addr
+	}
+
+	return DefaultSocketAddress
+}
+
+// ParseSocketAddress splits a usbmuxd address into the network and address
+// arguments of net.Dial.
+func ParseSocketAddress(addr string) (network, address string, err error) {
+	addr = strings.TrimSpace(addr)
+	switch {
+	case addr == "":
+		return "", "", xerrors.New("empty usbmuxd socket address")
+	case hasPrefixFold(addr, "unix:"):
+		network, address = "unix", addr[len("unix:"):]
+	case hasPrefixFold(addr, "tcp:"):
+		network, address = "tcp", addr[len("tcp:"):]
+	case strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "."):
+		network, address = "unix", addr
+	default:
+		network, address = "tcp", addr
+	}
+
+	if network == "tcp" {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", "", xerrors.Errorf("invalid usbmuxd socket address %q: %w", addr, err)
+		}
+	} else if address == "" {
+		return "", "", xerrors.Errorf("invalid usbmuxd socket address %q", addr)
+	}
+
+	return network, address, nil
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// DefaultPlistFormat is the plist encoding new connections send with.
+// Responses are decoded whatever their format, so switching to
+// plist.BinaryFormat only changes what we put on the wire.
+var DefaultPlistFormat = plist.XMLFormat
+
 type Conn struct {
-	conn net.Conn
+	conn   net.Conn
+	format int
+	// raw is the connection to usbmuxd. conn is replaced when TLS is
+	// enabled; deadlines are always set on raw so that they can be changed
+	// from another goroutine while that happens.
+	raw net.Conn
 }
 
 func NewConn() (IConn, error) {
-	conn, err := net.Dial("unix", "/var/run/usbmuxd")
-	if err != nil {
-		return nil, err
-	}
+	return NewConnContext(context.Background())
+}
 
-	return &Conn{conn: conn}, nil
+// NewConnContext is NewConn with a context that bounds the dial, on top of
+// DialTimeout.
+func NewConnContext(ctx context.Context) (IConn, error) {
+	return dialConn(ctx, SocketAddress)
+}
+
+// dialConn connects to the usbmuxd at addr, see SocketAddress.
+// While a session is recorded or replayed, see Record, the connection goes
+// through it.
+func dialConn(ctx context.Context, addr string) (IConn, error) {
+	return sessionConn(func() (IConn, error) {
+		network, address, err := ParseSocketAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		d := net.Dialer{Timeout: DialTimeout}
+		conn, err := d.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, xerrors.Errorf("could not connect to usbmuxd at %s (is usbmuxd running?): %w", addr, err)
+		}
+
+		return &Conn{conn: conn, format: DefaultPlistFormat, raw: conn}, nil
+	})
 }
 
 func (c *Conn) Close() {
@@ -51,8 +141,24 @@ func (c *Conn) Write(data []byte) error {
 	return err
 }
 
+// SetDeadline sets the read and write deadline of the connection, which
+// also applies once TLS is enabled. A zero t means no deadline.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.raw.SetDeadline(t)
+}
+
+// PlistFormat returns the encoding Encode uses.
+func (c *Conn) PlistFormat() int {
+	return c.format
+}
+
+// SetPlistFormat switches Encode to plist.XMLFormat or plist.BinaryFormat.
+func (c *Conn) SetPlistFormat(format int) {
+	c.format = format
+}
+
 func (c *Conn) Encode(msg interface{}) ([]byte, error) {
-	bs, err := plist.Marshal(msg, plist.XMLFormat)
+	bs, err := plist.Marshal(msg, c.format)
 	if err != nil {
 		return nil, err
 	}
@@ -114,3 +220,58 @@ func (c *Conn) createClientTLSConn(cert *Certificate) (*tls.Conn, error) {
 
 	return tlsConn, nil
 }
+
+// expired is a deadline in the past, used to interrupt blocked I/O.
+var expired = time.Unix(1, 0)
+
+// withContext applies ctx to conn for one exchange and is used as
+//
+//	defer withContext(ctx, conn)(&err)
+//
+// The context deadline becomes the connection deadline and cancelling ctx
+// expires it, so blocked reads and writes return straight away. The deadline
+// is cleared afterwards and an error caused by ctx is replaced by ctx.Err().
+// A connection interrupted mid message is out of sync and should be closed.
+func withContext(ctx context.Context, conn IConn) func(err *error) {
+	if ctx.Done() == nil {
+		return func(*error) {}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if ctx.Err() != nil {
+		_ = conn.SetDeadline(expired)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(expired)
+		case <-stop:
+		}
+	}()
+
+	return func(err *error) {
+		close(stop)
+		<-stopped
+		_ = conn.SetDeadline(time.Time{})
+		if *err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			*err = ctx.Err()
+		} else if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) && isTimeout(*err) {
+			// The connection deadline can fire before the context's timer.
+			*err = context.DeadlineExceeded
+		}
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return xerrors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,70 @@
+package idevice
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func TestParseSocketAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		address string
+	}{
+		{"/var/run/usbmuxd", "unix", "/var/run/usbmuxd"},
+		{"unix:/var/run/usbmuxd", "unix", "/var/run/usbmuxd"},
+		{"UNIX:/tmp/usbmuxd", "unix", "/tmp/usbmuxd"},
+		{"127.0.0.1:27015", "tcp", "127.0.0.1:27015"},
+		{"tcp:usbmuxd.lab:27015", "tcp", "usbmuxd.lab:27015"},
+		{"[::1]:27015", "tcp", "[::1]:27015"},
+	}
+	for _, tt := range tests {
+		network, address, err := ParseSocketAddress(tt.addr)
+		if err != nil {
+			t.Errorf("ParseSocketAddress(%q): %v", tt.addr, err)
+			continue
+		}
+		if network != tt.network || address != tt.address {
+			t.Errorf("ParseSocketAddress(%q) = %s %s, want %s %s", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+
+	for _, addr := range []string{"", "unix:", "localhost", "tcp:27015"} {
+		if _, _, err := ParseSocketAddress(addr); err == nil {
+			t.Errorf("ParseSocketAddress(%q) should fail", addr)
+		}
+	}
+}
+
+func TestNewConn_TCP(t *testing.T) {
+	srv, err := idevicetest.NewTCPServer(idevicetest.NewDevice(testUDID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	old := SocketAddress
+	SocketAddress = "tcp:" + srv.Addr
+	defer func() { SocketAddress = old }()
+
+	device, err := GetDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Properties.SerialNumber != testUDID {
+		t.Fatalf("SerialNumber = %q, want %q", device.Properties.SerialNumber, testUDID)
+	}
+}
+
+func TestNewConn_NotListening(t *testing.T) {
+	old := SocketAddress
+	SocketAddress = "unix:" + t.TempDir() + "/usbmuxd"
+	defer func() { SocketAddress = old }()
+
+	_, err := NewConn()
+	if err == nil || !strings.Contains(err.Error(), "is usbmuxd running?") {
+		t.Fatalf("NewConn error = %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package idevice
+
+import (
+	"context"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Crash report services. crashreportmover moves new reports into the
+// directory that crashreportcopymobile serves over AFC; it answers "ping"
+// once it is done.
+const (
+	CrashReportMoverServiceName = "com.apple.crashreportmover"
+	CrashReportCopyServiceName  = "com.apple.crashreportcopymobile"
+)
+
+// CrashReport is a crash log on the device, such as an .ips file.
+type CrashReport struct {
+	// Name is the path relative to the crash report directory, such as
+	// "Retired/SpringBoard-2023-05-01-101010.ips".
+	Name string
+	// Process is the process name taken from the file name.
+	Process string
+	// Time is when the report was written.
+	Time time.Time
+	// Size is the size in bytes.
+	Size int64
+}
+
+// crashReportName matches the process and date in report names, in both the
+// "SpringBoard-2023-05-01-101010.ips" and the older
+// "SpringBoard_2019-08-12-101010_iPhone.ips" format.
+var crashReportName = regexp.MustCompile(`^(.+?)[-_]\d{4}-\d{2}-\d{2}-\d{6}`)
+
+// crashReportProcess returns the process name of a report file name.
+func crashReportProcess(name string) string {
+	name = path.Base(name)
+	if m := crashReportName.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		return name[:i]
+	}
+
+	return name
+}
+
+// CrashReportService reads and deletes the crash reports of a device.
+type CrashReportService struct {
+	fm      *FileManagerService
+	connect func(ctx context.Context, name string) (IConn, error)
+}
+
+// NewCrashReportService moves new crash reports into place and connects to
+// crashreportcopymobile.
+func NewCrashReportService(device *DeviceEntry) (*CrashReportService, error) {
+	return NewCrashReportServiceContext(context.Background(), device)
+}
+
+// NewCrashReportServiceContext is NewCrashReportService with a context.
+func NewCrashReportServiceContext(ctx context.Context, device *DeviceEntry) (*CrashReportService, error) {
+	return newCrashReportService(ctx, func(ctx context.Context, name string) (IConn, error) {
+		return ConnectToServiceContext(ctx, device, name)
+	})
+}
+
+func newCrashReportService(ctx context.Context, connect func(ctx context.Context, name string) (IConn, error)) (*CrashReportService, error) {
+	s := &CrashReportService{connect: connect}
+	if err := s.MoveContext(ctx); err != nil {
+		return nil, err
+	}
+
+	conn, err := connect(ctx, CrashReportCopyServiceName)
+	if err != nil {
+		return nil, err
+	}
+	s.fm = newFileManagerService(conn)
+
+	return s, nil
+}
+
+func (s *CrashReportService) Close() {
+	s.fm.Close()
+}
+
+// FS returns the crash report directory as an fs.FS.
+func (s *CrashReportService) FS() *AFCFS {
+	return s.fm.FS()
+}
+
+// Move asks crashreportmover to move reports written since the last move
+// into place, so that List sees them.
+func (s *CrashReportService) Move() error {
+	return s.MoveContext(context.Background())
+}
+
+// MoveContext is Move with a context.
+func (s *CrashReportService) MoveContext(ctx context.Context) (err error) {
+	conn, err := s.connect(ctx, CrashReportMoverServiceName)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer withContext(ctx, conn)(&err)
+
+	ping := make([]byte, 4)
+	if _, err := io.ReadFull(conn.Reader(), ping); err != nil {
+		return err
+	}
+	if string(ping) != "ping" {
+		return xerrors.Errorf("crashreportmover: unexpected reply %q", ping)
+	}
+
+	return nil
+}
+
+// List returns every crash report, oldest first.
+func (s *CrashReportService) List() ([]CrashReport, error) {
+	return s.ListContext(context.Background())
+}
+
+// ListContext is List with a context.
+func (s *CrashReportService) ListContext(ctx context.Context) ([]CrashReport, error) {
+	var reports []CrashReport
+	if err := s.walk(ctx, "", &reports); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Time.Before(reports[j].Time)
+	})
+
+	return reports, nil
+}
+
+func (s *CrashReportService) walk(ctx context.Context, dir string, reports *[]CrashReport) error {
+	names, err := s.fm.ReadDirContext(ctx, "/"+dir)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		if n == "" || n == "." || n == ".." {
+			continue
+		}
+		name := path.Join(dir, n)
+		info, err := s.fm.GetFileInfoContext(ctx, "/"+name)
+		if err != nil {
+			// Removed since the listing.
+			if xerrors.Is(err, AFC_E_OBJECT_NOT_FOUND) {
+				continue
+			}
+			return err
+		}
+
+		fi := newAFCFileInfo(n, info)
+		switch {
+		case fi.IsDir():
+			if err := s.walk(ctx, name, reports); err != nil {
+				return err
+			}
+		case fi.Mode().IsRegular():
+			*reports = append(*reports, CrashReport{
+				Name:    name,
+				Process: crashReportProcess(n),
+				Time:    fi.ModTime(),
+				Size:    fi.Size(),
+			})
+		}
+	}
+
+	return nil
+}
+
+// Open opens the report name for reading.
+func (s *CrashReportService) Open(name string) (*AFCFile, error) {
+	return s.OpenContext(context.Background(), name)
+}
+
+// OpenContext is Open with a context that bounds opening the report.
+func (s *CrashReportService) OpenContext(ctx context.Context, name string) (*AFCFile, error) {
+	return s.fm.OpenFileContext(ctx, "/"+name, AFC_FOPEN_RDONLY)
+}
+
+// Remove deletes the report name from the device.
+func (s *CrashReportService) Remove(name string) error {
+	return s.RemoveContext(context.Background(), name)
+}
+
+// RemoveContext is Remove with a context.
+func (s *CrashReportService) RemoveContext(ctx context.Context, name string) error {
+	_, err := s.fm.RemovePathContext(ctx, "/"+name)
+	return err
+}
@@ -0,0 +1,87 @@
+package idevice
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func TestCrashReportProcess(t *testing.T) {
+	for name, want := range map[string]string{
+		"SpringBoard-2023-05-01-101010.ips":                 "SpringBoard",
+		"Retired/MobileSafari_2019-08-12-101010_iPhone.ips": "MobileSafari",
+		"stacks+com.example.app-2023-05-01-101010.ips":      "stacks+com.example.app",
+		"JetsamEvent-2023-05-01-101010.ips":                 "JetsamEvent",
+		"ResetCounter-Data.ips":                             "ResetCounter-Data",
+	} {
+		if got := crashReportProcess(name); got != want {
+			t.Errorf("crashReportProcess(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCrashReportService(t *testing.T) {
+	dev, entry := newTestDevice(t)
+	mover := idevicetest.NewCrashReportMover()
+	mover.Reports.WriteFile("Retired/MobileSafari_2019-08-12-101010_iPhone.ips", []byte("old"))
+	mover.Crash("MyApp-2023-05-01-101010.ips", []byte("crash"))
+	dev.AddService(CrashReportMoverServiceName, mover)
+	dev.AddService(CrashReportCopyServiceName, mover.Reports)
+
+	crashes, err := NewCrashReportService(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer crashes.Close()
+
+	reports, err := crashes.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("List = %+v, want 2 reports", reports)
+	}
+	byName := make(map[string]CrashReport)
+	for _, r := range reports {
+		byName[r.Name] = r
+	}
+	if r := byName["MyApp-2023-05-01-101010.ips"]; r.Process != "MyApp" || r.Size != 5 || r.Time.IsZero() {
+		t.Fatalf("moved report = %+v", r)
+	}
+	if r := byName["Retired/MobileSafari_2019-08-12-101010_iPhone.ips"]; r.Process != "MobileSafari" {
+		t.Fatalf("retired report = %+v", r)
+	}
+
+	f, err := crashes.Open("MyApp-2023-05-01-101010.ips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "crash" {
+		t.Fatalf("ReadAll = %q, %v", data, err)
+	}
+
+	if err := crashes.Remove("MyApp-2023-05-01-101010.ips"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crashes.Open("MyApp-2023-05-01-101010.ips"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Open of a removed report error = %v, want fs.ErrNotExist", err)
+	}
+
+	// Reports written later show up after another move.
+	mover.Crash("Other-2023-05-02-101010.ips", []byte("again"))
+	if err := crashes.Move(); err != nil {
+		t.Fatal(err)
+	}
+	reports, err = crashes.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || (reports[0].Name != "Other-2023-05-02-101010.ips" && reports[1].Name != "Other-2023-05-02-101010.ips") {
+		t.Fatalf("List after Move = %+v", reports)
+	}
+}
@@ -0,0 +1,478 @@
+package idevice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/xerrors"
+)
+
+// Device is an attached device, usually opened with Client.Device. It keeps
+// what several calls have in common instead of setting it up again each
+// time: the pair record is read once, a lockdown session is kept open for
+// starting services and SSH clients are pooled. A Device is safe for
+// concurrent use; Close releases what it holds.
+type Device struct {
+	Entry DeviceEntry
+
+	client   *Client
+	mu       sync.Mutex
+	cert     *Certificate
+	lockdown *LockdownConn
+	ssh      map[string]*ssh.Client
+}
+
+// NewDevice returns a Device for entry that dials SocketAddress. Nothing is
+// dialled until it is needed. Use Client.NewDevice for other options.
+func NewDevice(entry *DeviceEntry) *Device {
+	return defaultClient.NewDevice(entry)
+}
+
+// Certificate returns the pair record of the device, read from usbmuxd on
+// first use.
+func (d *Device) Certificate() (*Certificate, error) {
+	return d.CertificateContext(context.Background())
+}
+
+// CertificateContext is Certificate with a context.
+func (d *Device) CertificateContext(ctx context.Context) (*Certificate, error) {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cert == nil {
+		conn, err := d.client.usbConn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		cert, err := conn.GetCertificateContext(ctx, d.Entry.Properties.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		d.cert = cert
+	}
+
+	return d.cert, nil
+}
+
+// session returns the open lockdown session, starting one if needed. The
+// pair record is read over the same usbmuxd connection. d.mu must be held.
+func (d *Device) session(ctx context.Context) (*LockdownConn, error) {
+	if d.lockdown != nil {
+		return d.lockdown, nil
+	}
+
+	lockdown, err := d.connectLockdown(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d.lockdown = lockdown
+
+	return lockdown, nil
+}
+
+// connectLockdown opens a new lockdown connection and starts a session,
+// reading the pair record over the same usbmuxd connection if it is not
+// cached yet. d.mu must be held.
+func (d *Device) connectLockdown(ctx context.Context) (*LockdownConn, error) {
+	conn, err := d.client.usbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.cert == nil {
+		cert, err := conn.GetCertificateContext(ctx, d.Entry.Properties.SerialNumber)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		d.cert = cert
+	}
+
+	lockdown, err := conn.ConnectLockdownContext(ctx, d.Entry.DeviceID)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if _, err := lockdown.StartSessionContext(ctx, d.cert); err != nil {
+		d.client.logf("%s: lockdown session: %v", d.Entry.Properties.SerialNumber, err)
+		lockdown.Close()
+		return nil, err
+	}
+	d.client.logf("%s: lockdown session started", d.Entry.Properties.SerialNumber)
+
+	return lockdown, nil
+}
+
+// withSession runs fn on the lockdown session. lockdownd drops sessions that
+// sit idle, so when a reused session fails with anything but a lockdownd
+// error it is replaced and fn is tried once more.
+func (d *Device) withSession(ctx context.Context, fn func(lockdown *LockdownConn) error) error {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	reused := d.lockdown != nil
+	for {
+		lockdown, err := d.session(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = fn(lockdown)
+		var lockdownErr *LockdownError
+		if err == nil || xerrors.As(err, &lockdownErr) {
+			return err
+		}
+
+		// The connection is out of sync or gone.
+		d.client.logf("%s: lockdown session lost: %v", d.Entry.Properties.SerialNumber, err)
+		d.lockdown.Close()
+		d.lockdown = nil
+		if !reused || ctx.Err() != nil {
+			return err
+		}
+		reused = false
+	}
+}
+
+// StartService asks lockdownd to start the named service using the open
+// session.
+func (d *Device) StartService(name string) (*StartServiceResponse, error) {
+	return d.StartServiceContext(context.Background(), name)
+}
+
+// StartServiceContext is StartService with a context.
+func (d *Device) StartServiceContext(ctx context.Context, name string) (resp *StartServiceResponse, err error) {
+	err = d.withSession(ctx, func(lockdown *LockdownConn) error {
+		resp, err = lockdown.StartServiceContext(ctx, name)
+		return err
+	})
+
+	return resp, err
+}
+
+// GetValue is LockdownConn.GetValue on the open session.
+func (d *Device) GetValue(domain, key string) (interface{}, error) {
+	return d.GetValueContext(context.Background(), domain, key)
+}
+
+// GetValueContext is GetValue with a context.
+func (d *Device) GetValueContext(ctx context.Context, domain, key string) (value interface{}, err error) {
+	err = d.withSession(ctx, func(lockdown *LockdownConn) error {
+		value, err = lockdown.GetValueContext(ctx, domain, key)
+		return err
+	})
+
+	return value, err
+}
+
+// ConnectToService starts the named service and connects to it, which
+// takes a single new usbmuxd connection once the session is open.
+func (d *Device) ConnectToService(name string) (IConn, error) {
+	return d.ConnectToServiceContext(context.Background(), name)
+}
+
+// ConnectToServiceContext is ConnectToService with a context.
+func (d *Device) ConnectToServiceContext(ctx context.Context, name string) (IConn, error) {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	resp, err := d.StartServiceContext(ctx, name)
+	if err != nil {
+		d.client.logf("%s: start %s: %v", d.Entry.Properties.SerialNumber, name, err)
+		return nil, err
+	}
+
+	d.mu.Lock()
+	cert := d.cert
+	d.mu.Unlock()
+
+	conn, err := d.client.usbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.ConnectWithStartServiceResponseContext(ctx, d.Entry.DeviceID, resp, cert); err != nil {
+		d.client.logf("%s: connect %s on port %d: %v", d.Entry.Properties.SerialNumber, name, resp.Port, err)
+		conn.Close()
+		return nil, err
+	}
+	d.client.logf("%s: connected to %s on port %d", d.Entry.Properties.SerialNumber, name, resp.Port)
+
+	return conn.Conn, nil
+}
+
+// Dial connects to a TCP port on the device through usbmuxd, for services
+// that are not started by lockdownd such as SSH.
+func (d *Device) Dial(port uint16) (net.Conn, error) {
+	return d.DialContext(context.Background(), port)
+}
+
+// DialContext is Dial with a context that bounds connecting.
+func (d *Device) DialContext(ctx context.Context, port uint16) (net.Conn, error) {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	conn, err := d.client.usbConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.ConnectContext(ctx, d.Entry.DeviceID, Ntohs(port)); err != nil {
+		d.client.logf("%s: connect port %d: %v", d.Entry.Properties.SerialNumber, port, err)
+		conn.Close()
+		return nil, err
+	}
+
+	return netConn(conn.Conn), nil
+}
+
+// SSH returns an SSH client logged in to port on the device with config.
+// Clients are pooled by user and port, so later calls share the connection
+// and its handshake. The client belongs to the Device: do not close it,
+// close the Device instead.
+func (d *Device) SSH(port uint16, config *ssh.ClientConfig) (*ssh.Client, error) {
+	return d.SSHContext(context.Background(), port, config)
+}
+
+// SSHContext is SSH with a context that bounds connecting and the handshake.
+func (d *Device) SSHContext(ctx context.Context, port uint16, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	key := fmt.Sprintf("%s@%d", config.User, port)
+
+	d.mu.Lock()
+	client := d.ssh[key]
+	d.mu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	conn, err := d.DialContext(ctx, port)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err = newSSHClient(ctx, conn, fmt.Sprintf("%s:%d", d.Entry.Properties.SerialNumber, port), config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	d.mu.Lock()
+	if pooled := d.ssh[key]; pooled != nil {
+		// Another caller got there first.
+		d.mu.Unlock()
+		_ = client.Close()
+		return pooled, nil
+	}
+	d.ssh[key] = client
+	d.mu.Unlock()
+	d.client.logf("%s: SSH connected to port %d as %s", d.Entry.Properties.SerialNumber, port, config.User)
+
+	go func() {
+		_ = client.Wait()
+
+		d.mu.Lock()
+		if d.ssh[key] == client {
+			delete(d.ssh, key)
+		}
+		d.mu.Unlock()
+	}()
+
+	return client, nil
+}
+
+func newSSHClient(ctx context.Context, conn net.Conn, addr string, config *ssh.ClientConfig) (_ *ssh.Client, err error) {
+	defer withContext(ctx, &Conn{conn: conn, raw: conn})(&err)
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Lockdown opens a new lockdown connection with a session started, for
+// requests the Device does not wrap. The caller owns it and must close it.
+func (d *Device) Lockdown() (*LockdownConn, error) {
+	return d.LockdownContext(context.Background())
+}
+
+// LockdownContext is Lockdown with a context.
+func (d *Device) LockdownContext(ctx context.Context) (*LockdownConn, error) {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.connectLockdown(ctx)
+}
+
+// AFC connects to the AFC service, which serves the media partition.
+func (d *Device) AFC() (*FileManagerService, error) {
+	return d.AFCContext(context.Background())
+}
+
+// AFCContext is AFC with a context.
+func (d *Device) AFCContext(ctx context.Context) (*FileManagerService, error) {
+	conn, err := d.ConnectToServiceContext(ctx, FileManagerServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFileManagerService(conn), nil
+}
+
+// AppContainer opens AFC on the container of the app with the given bundle
+// ID through house_arrest. Only apps signed for development vend their
+// container.
+func (d *Device) AppContainer(bundleID string) (*FileManagerService, error) {
+	return d.AppContainerContext(context.Background(), bundleID)
+}
+
+// AppContainerContext is AppContainer with a context.
+func (d *Device) AppContainerContext(ctx context.Context, bundleID string) (*FileManagerService, error) {
+	return d.houseArrest(ctx, HouseArrestVendContainer, bundleID)
+}
+
+// AppDocuments opens AFC on the Documents directory of the app with the
+// given bundle ID through house_arrest. The app must enable file sharing.
+func (d *Device) AppDocuments(bundleID string) (*FileManagerService, error) {
+	return d.AppDocumentsContext(context.Background(), bundleID)
+}
+
+// AppDocumentsContext is AppDocuments with a context.
+func (d *Device) AppDocumentsContext(ctx context.Context, bundleID string) (*FileManagerService, error) {
+	return d.houseArrest(ctx, HouseArrestVendDocuments, bundleID)
+}
+
+func (d *Device) houseArrest(ctx context.Context, command, bundleID string) (*FileManagerService, error) {
+	ctx, cancel := d.client.context(ctx)
+	defer cancel()
+
+	conn, err := d.ConnectToServiceContext(ctx, HouseArrestServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return vendAFC(ctx, conn, command, bundleID)
+}
+
+// CrashReports moves new crash reports into place and opens the crash report
+// directory.
+func (d *Device) CrashReports() (*CrashReportService, error) {
+	return d.CrashReportsContext(context.Background())
+}
+
+// CrashReportsContext is CrashReports with a context.
+func (d *Device) CrashReportsContext(ctx context.Context) (*CrashReportService, error) {
+	return newCrashReportService(ctx, d.ConnectToServiceContext)
+}
+
+// Apps connects to installation_proxy.
+func (d *Device) Apps() (*AppManagerService, error) {
+	return d.AppsContext(context.Background())
+}
+
+// AppsContext is Apps with a context.
+func (d *Device) AppsContext(ctx context.Context) (*AppManagerService, error) {
+	conn, err := d.ConnectToServiceContext(ctx, AppManagerServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAppManagerService(conn), nil
+}
+
+// Syslog connects to syslog_relay.
+func (d *Device) Syslog() (*SyslogService, error) {
+	return d.SyslogContext(context.Background())
+}
+
+// SyslogContext is Syslog with a context.
+func (d *Device) SyslogContext(ctx context.Context) (*SyslogService, error) {
+	conn, err := d.ConnectToServiceContext(ctx, SyslogServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSyslogService(conn), nil
+}
+
+// Diagnostics connects to diagnostics_relay.
+func (d *Device) Diagnostics() (*DiagnosticsService, error) {
+	return d.DiagnosticsContext(context.Background())
+}
+
+// DiagnosticsContext is Diagnostics with a context.
+func (d *Device) DiagnosticsContext(ctx context.Context) (*DiagnosticsService, error) {
+	conn, err := d.ConnectToServiceContext(ctx, DiagnosticsServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiagnosticsService{conn: conn}, nil
+}
+
+// Pcap is StartPcapService on the Device's session.
+func (d *Device) Pcap(ctx context.Context, procName string, wr io.Writer, dump func([]byte)) error {
+	service, err := d.ConnectToServiceContext(ctx, PcapServiceName)
+	if err != nil {
+		return err
+	}
+	defer service.Close()
+
+	return capturePcap(ctx, service, procName, wr, dump)
+}
+
+// Forward listens on hostPort on localhost and forwards every connection to
+// remotePort on the device, like iproxy. Connection events go to the
+// Client's logger. Close the returned service to stop forwarding.
+func (d *Device) Forward(hostPort, remotePort uint16) (*ForwardService, error) {
+	fs := &ForwardService{entry: &d.Entry, client: d.client}
+	err := fs.Start(hostPort, remotePort, func(msg string, err error) {
+		if err != nil {
+			d.client.logf("%s: forward %d: %v", d.Entry.Properties.SerialNumber, remotePort, err)
+		} else {
+			d.client.logf("%s: forward %d: %s", d.Entry.Properties.SerialNumber, remotePort, msg)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Close ends the lockdown session and closes the pooled SSH clients.
+// Services and forwards it returned are closed separately. The Device can
+// still be used afterwards; it reconnects on demand.
+func (d *Device) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.lockdown != nil {
+		d.lockdown.Close()
+		d.lockdown = nil
+	}
+	for key, client := range d.ssh {
+		_ = client.Close()
+		delete(d.ssh, key)
+	}
+}
@@ -0,0 +1,142 @@
+package idevice
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestDevice_SessionReuse(t *testing.T) {
+	dev, entry := newTestDevice(t)
+	dev.AddService("com.apple.afc", idevicetest.NewAFC())
+
+	d := NewDevice(entry)
+	defer d.Close()
+
+	for i := 0; i < 3; i++ {
+		conn, err := d.ConnectToService("com.apple.afc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+	if name, err := d.GetValue("", "DeviceName"); err != nil || name != "iPhone" {
+		t.Fatalf("GetValue = %v, %v", name, err)
+	}
+	if n := dev.Lockdown.Sessions(); n != 1 {
+		t.Fatalf("started %d sessions, want 1", n)
+	}
+
+	// A session dropped by the device is replaced transparently.
+	d.lockdown.Close()
+	if _, err := d.StartService("com.apple.afc"); err != nil {
+		t.Fatal(err)
+	}
+	if n := dev.Lockdown.Sessions(); n != 2 {
+		t.Fatalf("started %d sessions, want 2", n)
+	}
+
+	// lockdownd errors leave the session alone.
+	if _, err := d.StartService("com.apple.no_such_service"); err == nil {
+		t.Fatal("expected an error for an unknown service")
+	}
+	if _, err := d.StartService("com.apple.afc"); err != nil {
+		t.Fatal(err)
+	}
+	if n := dev.Lockdown.Sessions(); n != 2 {
+		t.Fatalf("started %d sessions, want 2", n)
+	}
+}
+
+func TestDevice_SSH(t *testing.T) {
+	dev, entry := newTestDevice(t)
+
+	var handshakes int32
+	dev.Handle(22, idevicetest.ServiceFunc(func(conn net.Conn) {
+		atomic.AddInt32(&handshakes, 1)
+		serveSSH(t, conn)
+	}))
+
+	d := NewDevice(entry)
+	defer d.Close()
+
+	config := &ssh.ClientConfig{
+		User:            "root",
+		Auth:            []ssh.AuthMethod{ssh.Password("alpine")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	for _, cmd := range []string{"ps -eec", "kill 1"} {
+		client, err := d.SSH(22, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := client.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := session.Output(cmd)
+		session.Close()
+		if err != nil || string(out) != cmd {
+			t.Fatalf("Output(%q) = %q, %v", cmd, out, err)
+		}
+	}
+	if n := atomic.LoadInt32(&handshakes); n != 1 {
+		t.Fatalf("%d SSH handshakes, want 1", n)
+	}
+}
+
+// serveSSH is a minimal sshd that echoes the command of every exec request.
+func serveSSH(t *testing.T, conn net.Conn) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		ch, reqs, err := newChan.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer ch.Close()
+			for req := range reqs {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+
+				var exec struct{ Command string }
+				_ = ssh.Unmarshal(req.Payload, &exec)
+				_ = req.Reply(true, nil)
+				_, _ = ch.Write([]byte(exec.Command))
+				_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+		}()
+	}
+}
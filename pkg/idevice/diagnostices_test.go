@@ -1,12 +1,27 @@
 package idevice
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
 
 func TestDiagnosticsService_AllValues(t *testing.T) {
-	device, err := GetDevice()
-	if err != nil {
-		t.Fatal(err)
-	}
+	dev, device := newTestDevice(t)
+	dev.AddService("com.apple.mobile.diagnostics_relay", idevicetest.PlistHandlerFunc(func(req map[string]interface{}) []interface{} {
+		switch req["Request"] {
+		case "All":
+			return []interface{}{map[string]interface{}{
+				"Status": "Success",
+				"Diagnostics": map[string]interface{}{
+					"GasGauge": map[string]interface{}{"CycleCount": 42, "Status": "Success"},
+				},
+			}}
+		case "Goodbye":
+			return []interface{}{map[string]interface{}{"Status": "Success"}}
+		}
+		return nil
+	}))
 
 	conn, err := NewDiagnosticsService(device)
 	if err != nil {
@@ -19,5 +34,7 @@ func TestDiagnosticsService_AllValues(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	t.Log(resp)
+	if resp.Diagnostics.GasGauge.CycleCount != 42 {
+		t.Fatalf("CycleCount = %d, want 42", resp.Diagnostics.GasGauge.CycleCount)
+	}
 }
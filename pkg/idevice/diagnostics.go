@@ -1,6 +1,8 @@
 package idevice
 
 import (
+	"context"
+
 	"golang.org/x/xerrors"
 	"howett.net/plist"
 )
@@ -53,12 +55,20 @@ type rebootRequest struct {
 	DisplayFail       bool
 }
 
+// DiagnosticsServiceName is the lockdown name of diagnostics_relay.
+const DiagnosticsServiceName = "com.apple.mobile.diagnostics_relay"
+
 type DiagnosticsService struct {
 	conn IConn
 }
 
 func NewDiagnosticsService(entry *DeviceEntry) (*DiagnosticsService, error) {
-	conn, err := ConnectToService(entry, "com.apple.mobile.diagnostics_relay")
+	return NewDiagnosticsServiceContext(context.Background(), entry)
+}
+
+// NewDiagnosticsServiceContext is NewDiagnosticsService with a context.
+func NewDiagnosticsServiceContext(ctx context.Context, entry *DeviceEntry) (*DiagnosticsService, error) {
+	conn, err := ConnectToServiceContext(ctx, entry, DiagnosticsServiceName)
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +76,19 @@ func NewDiagnosticsService(entry *DeviceEntry) (*DiagnosticsService, error) {
 	return &DiagnosticsService{conn: conn}, nil
 }
 
+// SetPlistFormat selects the plist encoding for requests.
+func (d *DiagnosticsService) SetPlistFormat(format int) {
+	d.conn.SetPlistFormat(format)
+}
+
 func (d *DiagnosticsService) GetAllValues() (*allDiagnosticsResponse, error) {
+	return d.GetAllValuesContext(context.Background())
+}
+
+// GetAllValuesContext is GetAllValues with a context.
+func (d *DiagnosticsService) GetAllValuesContext(ctx context.Context) (_ *allDiagnosticsResponse, err error) {
+	defer withContext(ctx, d.conn)(&err)
+
 	req := diagnosticsRequest{"All"}
 	bs, err := d.conn.Encode(req)
 	if err != nil {
@@ -91,6 +113,13 @@ func (d *DiagnosticsService) GetAllValues() (*allDiagnosticsResponse, error) {
 }
 
 func (d *DiagnosticsService) Reboot() error {
+	return d.RebootContext(context.Background())
+}
+
+// RebootContext is Reboot with a context.
+func (d *DiagnosticsService) RebootContext(ctx context.Context) (err error) {
+	defer withContext(ctx, d.conn)(&err)
+
 	req := rebootRequest{
 		Request:           "Restart",
 		WaitForDisconnect: true,
@@ -0,0 +1,293 @@
+package idevice
+
+import (
+	"fmt"
+	"io/fs"
+
+	"golang.org/x/xerrors"
+)
+
+// Error kinds. Failures reported by usbmuxd, lockdownd and AFC are returned as
+// *MuxError, *LockdownError and AFCError, which match these with errors.Is.
+var (
+	// ErrDeviceNotFound means the device is not attached, or usbmuxd does
+	// not know its device ID.
+	ErrDeviceNotFound = xerrors.New("device not found")
+	// ErrPortRefused means the device refused a connection to a port,
+	// usually because no service is listening on it.
+	ErrPortRefused = xerrors.New("connection refused by device")
+	// ErrPasswordProtected is returned by Pair while the device is locked
+	// with a passcode. Pairing can be retried once the user unlocks it.
+	ErrPasswordProtected = xerrors.New("device is passcode protected, unlock it to continue pairing")
+	// ErrPairingDialogResponsePending is returned by Pair while the trust
+	// dialog is shown on the device and the user has not answered yet.
+	ErrPairingDialogResponsePending = xerrors.New("waiting for the user to accept the trust dialog")
+	// ErrUserDeniedPairing is returned by Pair when the user tapped
+	// "Don't Trust".
+	ErrUserDeniedPairing = xerrors.New("user denied pairing")
+	// ErrInvalidHostID means the device does not trust the host in the pair
+	// record, for example after it was unpaired or reset.
+	ErrInvalidHostID = xerrors.New("device does not trust this host, pair it again")
+	// ErrMissingValue is returned by GetValue when the domain or key does
+	// not exist on the device.
+	ErrMissingValue = xerrors.New("missing value")
+	// ErrServiceProhibited means lockdownd refused to start a service, for
+	// example while the device is locked.
+	ErrServiceProhibited = xerrors.New("service prohibited")
+	// ErrInvalidService means lockdownd does not know the service.
+	ErrInvalidService = xerrors.New("invalid service")
+	// ErrDeveloperImageMissing means a developer service could not be
+	// started because the Developer Disk Image is not mounted.
+	ErrDeveloperImageMissing = xerrors.New("developer disk image is not mounted")
+	// ErrAppNotFound means house_arrest could not find the app, or will not
+	// vend its sandbox.
+	ErrAppNotFound = xerrors.New("app not found")
+	// ErrChecksumMismatch is returned by Verify when a file on the device
+	// differs from its local copy.
+	ErrChecksumMismatch = xerrors.New("checksum mismatch")
+)
+
+// usbmuxd result numbers.
+const (
+	MuxResultOK                = 0
+	MuxResultBadCommand        = 1
+	MuxResultBadDevice         = 2
+	MuxResultConnectionRefused = 3
+	MuxResultBadVersion        = 6
+)
+
+// MuxError is a usbmuxd request that failed with a result number.
+type MuxError struct {
+	// Request is the usbmuxd message type, such as "Connect".
+	Request string
+	// Number is the result number sent by usbmuxd.
+	Number uint32
+}
+
+func (e *MuxError) Error() string {
+	var reason string
+	switch e.Number {
+	case MuxResultBadCommand:
+		reason = "bad command"
+	case MuxResultBadDevice:
+		reason = "bad device"
+	case MuxResultConnectionRefused:
+		reason = "connection refused"
+	case MuxResultBadVersion:
+		reason = "bad version"
+	default:
+		reason = "error"
+	}
+
+	return fmt.Sprintf("usbmuxd %s failed: %s (%d)", e.Request, reason, e.Number)
+}
+
+// Is matches ErrDeviceNotFound and ErrPortRefused, and any *MuxError with
+// the same result number.
+func (e *MuxError) Is(target error) bool {
+	switch target {
+	case ErrDeviceNotFound:
+		return e.Number == MuxResultBadDevice
+	case ErrPortRefused:
+		return e.Number == MuxResultConnectionRefused
+	}
+
+	t, ok := target.(*MuxError)
+	return ok && t.Number == e.Number
+}
+
+// developerServices are only available once the Developer Disk Image is
+// mounted; lockdownd answers InvalidService for them before that.
+var developerServices = map[string]bool{
+	"com.apple.debugserver":                                   true,
+	"com.apple.debugserver.DVTSecureSocketProxy":              true,
+	"com.apple.instruments.remoteserver":                      true,
+	"com.apple.instruments.remoteserver.DVTSecureSocketProxy": true,
+	"com.apple.accessibility.axAuditDaemon.remoteserver":      true,
+	"com.apple.testmanagerd.lockdown":                         true,
+	"com.apple.mobile.screenshotr":                            true,
+}
+
+// lockdownErrors maps lockdownd Error strings to error kinds.
+var lockdownErrors = map[string]error{
+	"PasswordProtected":            ErrPasswordProtected,
+	"PairingDialogResponsePending": ErrPairingDialogResponsePending,
+	"UserDeniedPairing":            ErrUserDeniedPairing,
+	"InvalidHostID":                ErrInvalidHostID,
+	"MissingValue":                 ErrMissingValue,
+	"ServiceProhibited":            ErrServiceProhibited,
+	"InvalidService":               ErrInvalidService,
+}
+
+// LockdownError is an Error string sent by lockdownd.
+type LockdownError struct {
+	// Request is the lockdownd request that failed, such as "StartService".
+	Request string
+	// Name is what the request was about: a service name, or a value
+	// written as domain/key. It may be empty.
+	Name string
+	// Err is the error sent by lockdownd, such as "InvalidHostID".
+	Err string
+}
+
+func (e *LockdownError) Error() string {
+	msg := e.Request
+	if e.Name != "" {
+		msg += " " + e.Name
+	}
+	msg += " failed: " + e.Err
+	if e.developerImageMissing() {
+		msg += " (is the Developer Disk Image mounted?)"
+	}
+
+	return msg
+}
+
+// Is matches the error kind of Err, and any *LockdownError with the same
+// Err.
+func (e *LockdownError) Is(target error) bool {
+	if target == ErrDeveloperImageMissing {
+		return e.developerImageMissing()
+	}
+	if t, ok := target.(*LockdownError); ok {
+		return t.Err == e.Err
+	}
+
+	kind, ok := lockdownErrors[e.Err]
+	return ok && kind == target
+}
+
+func (e *LockdownError) developerImageMissing() bool {
+	return e.Request == "StartService" && e.Err == "InvalidService" && developerServices[e.Name]
+}
+
+// HouseArrestError is an Error string sent by house_arrest.
+type HouseArrestError struct {
+	// Command is VendContainer or VendDocuments.
+	Command string
+	// Identifier is the bundle ID of the app.
+	Identifier string
+	// Err is the error sent by house_arrest, such as
+	// "ApplicationLookupFailed".
+	Err string
+}
+
+func (e *HouseArrestError) Error() string {
+	msg := "house_arrest " + e.Command + " " + e.Identifier + " failed: " + e.Err
+	if e.Command == HouseArrestVendContainer && e.Err == "InstallationLookupFailed" {
+		msg += " (only apps signed for development vend their container, try the Documents directory)"
+	}
+
+	return msg
+}
+
+// Is matches ErrAppNotFound, and any *HouseArrestError with the same Err.
+func (e *HouseArrestError) Is(target error) bool {
+	if t, ok := target.(*HouseArrestError); ok {
+		return t.Err == e.Err
+	}
+
+	return target == ErrAppNotFound && (e.Err == "ApplicationLookupFailed" || e.Err == "InstallationLookupFailed")
+}
+
+// AFCError is a status code sent by the AFC service.
+type AFCError uint64
+
+const (
+	AFC_E_SUCCESS               AFCError = 0
+	AFC_E_UNKNOWN_ERROR         AFCError = 1
+	AFC_E_OP_HEADER_INVALID     AFCError = 2
+	AFC_E_NO_RESOURCES          AFCError = 3
+	AFC_E_READ_ERROR            AFCError = 4
+	AFC_E_WRITE_ERROR           AFCError = 5
+	AFC_E_UNKNOWN_PACKET_TYPE   AFCError = 6
+	AFC_E_INVALID_ARG           AFCError = 7
+	AFC_E_OBJECT_NOT_FOUND      AFCError = 8
+	AFC_E_OBJECT_IS_DIR         AFCError = 9
+	AFC_E_PERM_DENIED           AFCError = 10
+	AFC_E_SERVICE_NOT_CONNECTED AFCError = 11
+	AFC_E_OP_TIMEOUT            AFCError = 12
+	AFC_E_TOO_MUCH_DATA         AFCError = 13
+	AFC_E_END_OF_DATA           AFCError = 14
+	AFC_E_OP_NOT_SUPPORTED      AFCError = 15
+	AFC_E_OBJECT_EXISTS         AFCError = 16
+	AFC_E_OBJECT_BUSY           AFCError = 17
+	AFC_E_NO_SPACE_LEFT         AFCError = 18
+	AFC_E_OP_WOULD_BLOCK        AFCError = 19
+	AFC_E_IO_ERROR              AFCError = 20
+	AFC_E_OP_INTERRUPTED        AFCError = 21
+	AFC_E_OP_IN_PROGRESS        AFCError = 22
+	AFC_E_INTERNAL_ERROR        AFCError = 23
+	AFC_E_MUX_ERROR             AFCError = 30
+	AFC_E_NO_MEM                AFCError = 31
+	AFC_E_NOT_ENOUGH_DATA       AFCError = 32
+	AFC_E_DIR_NOT_EMPTY         AFCError = 33
+)
+
+var afcErrorText = map[AFCError]string{
+	AFC_E_SUCCESS:               "success",
+	AFC_E_UNKNOWN_ERROR:         "unknown error",
+	AFC_E_OP_HEADER_INVALID:     "invalid operation header",
+	AFC_E_NO_RESOURCES:          "no resources",
+	AFC_E_READ_ERROR:            "read error",
+	AFC_E_WRITE_ERROR:           "write error",
+	AFC_E_UNKNOWN_PACKET_TYPE:   "unknown packet type",
+	AFC_E_INVALID_ARG:           "invalid argument",
+	AFC_E_OBJECT_NOT_FOUND:      "no such file or directory",
+	AFC_E_OBJECT_IS_DIR:         "is a directory",
+	AFC_E_PERM_DENIED:           "permission denied",
+	AFC_E_SERVICE_NOT_CONNECTED: "service not connected",
+	AFC_E_OP_TIMEOUT:            "operation timed out",
+	AFC_E_TOO_MUCH_DATA:         "too much data",
+	AFC_E_END_OF_DATA:           "end of data",
+	AFC_E_OP_NOT_SUPPORTED:      "operation not supported",
+	AFC_E_OBJECT_EXISTS:         "file exists",
+	AFC_E_OBJECT_BUSY:           "resource busy",
+	AFC_E_NO_SPACE_LEFT:         "no space left on device",
+	AFC_E_OP_WOULD_BLOCK:        "operation would block",
+	AFC_E_IO_ERROR:              "input/output error",
+	AFC_E_OP_INTERRUPTED:        "operation interrupted",
+	AFC_E_OP_IN_PROGRESS:        "operation in progress",
+	AFC_E_INTERNAL_ERROR:        "internal error",
+	AFC_E_MUX_ERROR:             "usbmuxd error",
+	AFC_E_NO_MEM:                "out of memory",
+	AFC_E_NOT_ENOUGH_DATA:       "not enough data",
+	AFC_E_DIR_NOT_EMPTY:         "directory not empty",
+}
+
+func (e AFCError) Error() string {
+	if text, ok := afcErrorText[e]; ok {
+		return "afc: " + text
+	}
+
+	return fmt.Sprintf("afc: status %d", uint64(e))
+}
+
+// Is lets AFC errors match the io/fs error kinds, so that
+// errors.Is(err, fs.ErrNotExist) works for a missing path.
+func (e AFCError) Is(target error) bool {
+	switch target {
+	case fs.ErrNotExist:
+		return e == AFC_E_OBJECT_NOT_FOUND
+	case fs.ErrExist:
+		return e == AFC_E_OBJECT_EXISTS
+	case fs.ErrPermission:
+		return e == AFC_E_PERM_DENIED
+	}
+
+	return false
+}
+
+// describedError carries its own message but matches kind with errors.Is.
+type describedError struct {
+	msg  string
+	kind error
+}
+
+func (e *describedError) Error() string {
+	return e.msg
+}
+
+func (e *describedError) Unwrap() error {
+	return e.kind
+}
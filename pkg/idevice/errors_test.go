@@ -0,0 +1,81 @@
+package idevice
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func TestMuxErrors(t *testing.T) {
+	_, device := newTestDevice(t)
+
+	conn, err := NewUSBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Connect(device.DeviceID+100, 0x7e00)
+	conn.Close()
+	if !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Connect to unknown device error = %v, want ErrDeviceNotFound", err)
+	}
+
+	conn, err = NewUSBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Connect(device.DeviceID, Ntohs(1))
+	conn.Close()
+	var muxErr *MuxError
+	if !errors.Is(err, ErrPortRefused) || !errors.As(err, &muxErr) || muxErr.Number != MuxResultConnectionRefused {
+		t.Fatalf("Connect to closed port error = %v, want ErrPortRefused", err)
+	}
+}
+
+func TestLockdownErrors(t *testing.T) {
+	srv := newTestServer(t, idevicetest.NewDevice(testUDID))
+	device, err := GetDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := StartService(device, "com.apple.no_such_service"); !errors.Is(err, ErrInvalidService) || errors.Is(err, ErrDeveloperImageMissing) {
+		t.Fatalf("StartService error = %v, want ErrInvalidService", err)
+	}
+
+	_, err = StartService(device, "com.apple.debugserver")
+	var lockdownErr *LockdownError
+	if !errors.Is(err, ErrDeveloperImageMissing) || !errors.As(err, &lockdownErr) || lockdownErr.Err != "InvalidService" {
+		t.Fatalf("StartService error = %v, want ErrDeveloperImageMissing", err)
+	}
+
+	srv.Devices()[0].Lockdown.HostIDs = nil
+	if err := ValidatePair(device); !errors.Is(err, ErrInvalidHostID) {
+		t.Fatalf("ValidatePair error = %v, want ErrInvalidHostID", err)
+	}
+}
+
+func TestAFCErrors(t *testing.T) {
+	_, fileService := newTestFileManager(t)
+
+	_, err := fileService.GetFileInfo("/no/such/file")
+	if !errors.Is(err, AFC_E_OBJECT_NOT_FOUND) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("GetFileInfo error = %v, want AFC_E_OBJECT_NOT_FOUND", err)
+	}
+
+	if _, err := fileService.FileOpen("/no/such/file", AFC_FOPEN_RDONLY); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("FileOpen error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestSelectDevice_NotFound(t *testing.T) {
+	if _, err := SelectDevice(nil, DeviceFilter{}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("SelectDevice error = %v, want ErrDeviceNotFound", err)
+	}
+
+	list := []DeviceEntry{{DeviceID: 1, Properties: DeviceProperties{SerialNumber: testUDID, ConnectionType: ConnectionTypeUSB}}}
+	if _, err := SelectDevice(list, DeviceFilter{UDID: "nope"}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("SelectDevice error = %v, want ErrDeviceNotFound", err)
+	}
+}
@@ -2,8 +2,14 @@ package idevice
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"sync"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/trace"
 
 	"golang.org/x/xerrors"
 )
@@ -52,6 +58,60 @@ const (
 	AFC_OP_FILE_WRITE_OFFSET         = 0x00000028 /* FileRefWriteWithOffset */
 )
 
+// afcOpNames are the operation names used in traces.
+var afcOpNames = map[uint64]string{
+	AFC_OP_INVALID:                   "Invalid",
+	AFC_OP_STATUS:                    "Status",
+	AFC_OP_DATA:                      "Data",
+	AFC_OP_READ_DIR:                  "ReadDir",
+	AFC_OP_READ_FILE:                 "ReadFile",
+	AFC_OP_WRITE_FILE:                "WriteFile",
+	AFC_OP_WRITE_PART:                "WritePart",
+	AFC_OP_TRUNCATE:                  "TruncateFile",
+	AFC_OP_REMOVE_PATH:               "RemovePath",
+	AFC_OP_MAKE_DIR:                  "MakeDir",
+	AFC_OP_GET_FILE_INFO:             "GetFileInfo",
+	AFC_OP_GET_DEVINFO:               "GetDeviceInfo",
+	AFC_OP_WRITE_FILE_ATOM:           "WriteFileAtomic",
+	AFC_OP_FILE_OPEN:                 "FileRefOpen",
+	AFC_OP_FILE_OPEN_RES:             "FileRefOpenResult",
+	AFC_OP_FILE_READ:                 "FileRefRead",
+	AFC_OP_FILE_WRITE:                "FileRefWrite",
+	AFC_OP_FILE_SEEK:                 "FileRefSeek",
+	AFC_OP_FILE_TELL:                 "FileRefTell",
+	AFC_OP_FILE_TELL_RES:             "FileRefTellResult",
+	AFC_OP_FILE_CLOSE:                "FileRefClose",
+	AFC_OP_FILE_SET_SIZE:             "FileRefSetFileSize",
+	AFC_OP_GET_CON_INFO:              "GetConnectionInfo",
+	AFC_OP_SET_CON_OPTIONS:           "SetConnectionOptions",
+	AFC_OP_RENAME_PATH:               "RenamePath",
+	AFC_OP_SET_FS_BS:                 "SetFSBlockSize",
+	AFC_OP_SET_SOCKET_BS:             "SetSocketBlockSize",
+	AFC_OP_FILE_LOCK:                 "FileRefLock",
+	AFC_OP_MAKE_LINK:                 "MakeLink",
+	AFC_OP_GET_FILE_HASH:             "GetFileHash",
+	AFC_OP_SET_FILE_MOD_TIME:         "SetModTime",
+	AFC_OP_GET_FILE_HASH_RANGE:       "GetFileHashWithRange",
+	AFC_OP_FILE_SET_IMMUTABLE_HINT:   "FileRefSetImmutableHint",
+	AFC_OP_GET_SIZE_OF_PATH_CONTENTS: "GetSizeOfPathContents",
+	AFC_OP_REMOVE_PATH_AND_CONTENTS:  "RemovePathAndContents",
+	AFC_OP_DIR_OPEN:                  "DirectoryEnumeratorRefOpen",
+	AFC_OP_DIR_OPEN_RESULT:           "DirectoryEnumeratorRefOpenResult",
+	AFC_OP_DIR_READ:                  "DirectoryEnumeratorRefRead",
+	AFC_OP_DIR_CLOSE:                 "DirectoryEnumeratorRefClose",
+	AFC_OP_FILE_READ_OFFSET:          "FileRefReadWithOffset",
+	AFC_OP_FILE_WRITE_OFFSET:         "FileRefWriteWithOffset",
+}
+
+// AFCOpName returns the name of an AFC operation, such as "FileRefOpen".
+func AFCOpName(op uint64) string {
+	if name, ok := afcOpNames[op]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Op(%#x)", op)
+}
+
 type FileMode uint64
 
 const (
@@ -63,6 +123,14 @@ const (
 	AFC_FOPEN_RDAPPEND FileMode = 0x00000006 /**< a+  O_RDWR   | O_APPEND | O_CREAT */
 )
 
+// LinkType is the kind of link MakeLink creates.
+type LinkType uint64
+
+const (
+	AFC_HARDLINK LinkType = 0x00000001
+	AFC_SYMLINK  LinkType = 0x00000002
+)
+
 var DefaultChunkSize = 1048576
 
 type AFCHeader struct {
@@ -81,22 +149,38 @@ type AFCPacket struct {
 
 type MapResult map[string]interface{}
 
+// FileManagerServiceName is the lockdown name of the AFC service, which
+// serves the media partition.
+const FileManagerServiceName = "com.apple.afc"
+
 type FileManagerService struct {
-	conn       IConn
-	header     *AFCHeader
+	conn   IConn
+	header *AFCHeader
+	// mu serializes requests, so that several AFCFiles can share the
+	// service.
+	mu sync.Mutex
 }
 
 func NewFileManagerService(device *DeviceEntry) (*FileManagerService, error) {
-	conn, err := ConnectToService(device, "com.apple.afc")
+	return NewFileManagerServiceContext(context.Background(), device)
+}
+
+// NewFileManagerServiceContext is NewFileManagerService with a context.
+func NewFileManagerServiceContext(ctx context.Context, device *DeviceEntry) (*FileManagerService, error) {
+	conn, err := ConnectToServiceContext(ctx, device, FileManagerServiceName)
 	if err != nil {
 		return nil, err
 	}
 
+	return newFileManagerService(conn), nil
+}
+
+func newFileManagerService(conn IConn) *FileManagerService {
 	var magic [8]byte
 	copy(magic[:], "CFA6LPAA")
 
 	return &FileManagerService{
-		conn:       conn,
+		conn: conn,
 		header: &AFCHeader{
 			Magic:        magic,
 			EntireLength: 0,
@@ -104,7 +188,7 @@ func NewFileManagerService(device *DeviceEntry) (*FileManagerService, error) {
 			PacketNum:    0,
 			Operation:    0,
 		},
-	}, nil
+	}
 }
 
 func (f *FileManagerService) Close() {
@@ -112,24 +196,26 @@ func (f *FileManagerService) Close() {
 }
 
 func (f *FileManagerService) GetDeviceInfo() (MapResult, error) {
-	if err := f.Send(AFC_OP_GET_DEVINFO, nil, nil); err != nil {
-		return nil, err
-	}
+	return f.GetDeviceInfoContext(context.Background())
+}
 
-	ret, err := f.Recv()
+// GetDeviceInfoContext is GetDeviceInfo with a context.
+func (f *FileManagerService) GetDeviceInfoContext(ctx context.Context) (MapResult, error) {
+	ret, err := f.request(ctx, AFC_OP_GET_DEVINFO, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return f.buildMapResult(ret.param), nil
+	return f.buildMapResult(ret.payload), nil
 }
 
 func (f *FileManagerService) ReadDir(dir string) ([]string, error) {
-	if err := f.Send(AFC_OP_READ_DIR, []byte(dir), nil); err != nil {
-		return nil, err
-	}
+	return f.ReadDirContext(context.Background(), dir)
+}
 
-	ret, err := f.Recv()
+// ReadDirContext is ReadDir with a context.
+func (f *FileManagerService) ReadDirContext(ctx context.Context, dir string) ([]string, error) {
+	ret, err := f.request(ctx, AFC_OP_READ_DIR, afcPath(dir), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -138,11 +224,12 @@ func (f *FileManagerService) ReadDir(dir string) ([]string, error) {
 }
 
 func (f *FileManagerService) MakeDir(dir string) (uint64, error) {
-	if err := f.Send(AFC_OP_MAKE_DIR, []byte(dir), nil); err != nil {
-		return 0, err
-	}
+	return f.MakeDirContext(context.Background(), dir)
+}
 
-	ret, err := f.Recv()
+// MakeDirContext is MakeDir with a context.
+func (f *FileManagerService) MakeDirContext(ctx context.Context, dir string) (uint64, error) {
+	ret, err := f.request(ctx, AFC_OP_MAKE_DIR, afcPath(dir), nil)
 	if err != nil {
 		return 0, err
 	}
@@ -151,11 +238,12 @@ func (f *FileManagerService) MakeDir(dir string) (uint64, error) {
 }
 
 func (f *FileManagerService) RemovePath(path string) (uint64, error) {
-	if err := f.Send(AFC_OP_REMOVE_PATH, []byte(path), nil); err != nil {
-		return 0, err
-	}
+	return f.RemovePathContext(context.Background(), path)
+}
 
-	ret, err := f.Recv()
+// RemovePathContext is RemovePath with a context.
+func (f *FileManagerService) RemovePathContext(ctx context.Context, path string) (uint64, error) {
+	ret, err := f.request(ctx, AFC_OP_REMOVE_PATH, afcPath(path), nil)
 	if err != nil {
 		return 0, err
 	}
@@ -164,11 +252,127 @@ func (f *FileManagerService) RemovePath(path string) (uint64, error) {
 }
 
 func (f *FileManagerService) GetFileInfo(path string) (MapResult, error) {
-	if err := f.Send(AFC_OP_GET_FILE_INFO, []byte(path), nil); err != nil {
+	return f.GetFileInfoContext(context.Background(), path)
+}
+
+// GetFileInfoContext is GetFileInfo with a context.
+func (f *FileManagerService) GetFileInfoContext(ctx context.Context, path string) (MapResult, error) {
+	ret, err := f.request(ctx, AFC_OP_GET_FILE_INFO, afcPath(path), nil)
+	if err != nil {
 		return nil, err
 	}
 
-	ret, err := f.Recv()
+	return f.buildMapResult(ret.payload), nil
+}
+
+// RenamePath moves from to to, replacing to if it is a file.
+func (f *FileManagerService) RenamePath(from, to string) error {
+	return f.RenamePathContext(context.Background(), from, to)
+}
+
+// RenamePathContext is RenamePath with a context.
+func (f *FileManagerService) RenamePathContext(ctx context.Context, from, to string) error {
+	param := append(afcPath(from), afcPath(to)...)
+	_, err := f.request(ctx, AFC_OP_RENAME_PATH, param, nil)
+	return err
+}
+
+// RemovePathAndContents removes path and, if it is a directory, everything
+// in it.
+func (f *FileManagerService) RemovePathAndContents(path string) error {
+	return f.RemovePathAndContentsContext(context.Background(), path)
+}
+
+// RemovePathAndContentsContext is RemovePathAndContents with a context.
+func (f *FileManagerService) RemovePathAndContentsContext(ctx context.Context, path string) error {
+	_, err := f.request(ctx, AFC_OP_REMOVE_PATH_AND_CONTENTS, afcPath(path), nil)
+	return err
+}
+
+// MakeLink creates link pointing at target. A symbolic link target is
+// stored as given, so a relative target is resolved from the link's
+// directory.
+func (f *FileManagerService) MakeLink(linkType LinkType, target, link string) error {
+	return f.MakeLinkContext(context.Background(), linkType, target, link)
+}
+
+// MakeLinkContext is MakeLink with a context.
+func (f *FileManagerService) MakeLinkContext(ctx context.Context, linkType LinkType, target, link string) error {
+	param := afcUint64(uint64(linkType))
+	param = append(param, afcPath(target)...)
+	param = append(param, afcPath(link)...)
+	_, err := f.request(ctx, AFC_OP_MAKE_LINK, param, nil)
+	return err
+}
+
+// Truncate sets the size of the file at path, cutting it short or padding
+// it with zeros.
+func (f *FileManagerService) Truncate(path string, size uint64) error {
+	return f.TruncateContext(context.Background(), path, size)
+}
+
+// TruncateContext is Truncate with a context.
+func (f *FileManagerService) TruncateContext(ctx context.Context, path string, size uint64) error {
+	param := append(afcUint64(size), afcPath(path)...)
+	_, err := f.request(ctx, AFC_OP_TRUNCATE, param, nil)
+	return err
+}
+
+// SetFileModTime sets the modification time of path.
+func (f *FileManagerService) SetFileModTime(path string, mtime time.Time) error {
+	return f.SetFileModTimeContext(context.Background(), path, mtime)
+}
+
+// SetFileModTimeContext is SetFileModTime with a context.
+func (f *FileManagerService) SetFileModTimeContext(ctx context.Context, path string, mtime time.Time) error {
+	param := append(afcUint64(uint64(mtime.UnixNano())), afcPath(path)...)
+	_, err := f.request(ctx, AFC_OP_SET_FILE_MOD_TIME, param, nil)
+	return err
+}
+
+// Hash returns the SHA-1 of the file at path, computed on the device.
+func (f *FileManagerService) Hash(path string) ([]byte, error) {
+	return f.HashContext(context.Background(), path)
+}
+
+// HashContext is Hash with a context.
+func (f *FileManagerService) HashContext(ctx context.Context, path string) ([]byte, error) {
+	ret, err := f.request(ctx, AFC_OP_GET_FILE_HASH, afcPath(path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret.payload, nil
+}
+
+// HashRange returns the SHA-1 of length bytes of the file at path,
+// starting at offset, computed on the device.
+func (f *FileManagerService) HashRange(path string, offset, length uint64) ([]byte, error) {
+	return f.HashRangeContext(context.Background(), path, offset, length)
+}
+
+// HashRangeContext is HashRange with a context.
+func (f *FileManagerService) HashRangeContext(ctx context.Context, path string, offset, length uint64) ([]byte, error) {
+	param := append(afcUint64(offset), afcUint64(length)...)
+	param = append(param, afcPath(path)...)
+	ret, err := f.request(ctx, AFC_OP_GET_FILE_HASH_RANGE, param, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ret.payload, nil
+}
+
+// GetSizeOfPathContents returns how much space path takes, counting
+// everything under it when it is a directory. The result has the keys
+// of GetFileInfo, such as st_size and st_blocks.
+func (f *FileManagerService) GetSizeOfPathContents(path string) (MapResult, error) {
+	return f.GetSizeOfPathContentsContext(context.Background(), path)
+}
+
+// GetSizeOfPathContentsContext is GetSizeOfPathContents with a context.
+func (f *FileManagerService) GetSizeOfPathContentsContext(ctx context.Context, path string) (MapResult, error) {
+	ret, err := f.request(ctx, AFC_OP_GET_SIZE_OF_PATH_CONTENTS, afcPath(path), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -178,15 +382,16 @@ func (f *FileManagerService) GetFileInfo(path string) (MapResult, error) {
 
 // FileOpen /var/mobile/Media
 func (f *FileManagerService) FileOpen(fileName string, mode FileMode) (uint64, error) {
+	return f.FileOpenContext(context.Background(), fileName, mode)
+}
+
+// FileOpenContext is FileOpen with a context.
+func (f *FileManagerService) FileOpenContext(ctx context.Context, fileName string, mode FileMode) (uint64, error) {
 	buf := make([]byte, 8)
 	binary.LittleEndian.PutUint64(buf, uint64(mode))
 	param := append(buf, []byte(fileName)...)
 	param = append(param, []byte{0x00}...)
-	if err := f.Send(AFC_OP_FILE_OPEN, param, nil); err != nil {
-		return 0, err
-	}
-
-	ret, err := f.Recv()
+	ret, err := f.request(ctx, AFC_OP_FILE_OPEN, param, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -195,44 +400,35 @@ func (f *FileManagerService) FileOpen(fileName string, mode FileMode) (uint64, e
 }
 
 func (f *FileManagerService) FileClose(handle uint64) error {
+	return f.FileCloseContext(context.Background(), handle)
+}
+
+// FileCloseContext is FileClose with a context.
+func (f *FileManagerService) FileCloseContext(ctx context.Context, handle uint64) error {
 	data := make([]byte, 8)
 	binary.LittleEndian.PutUint64(data, handle)
-	if err := f.Send(AFC_OP_FILE_CLOSE, data, nil); err != nil {
-		return err
-	}
-
-	ret, err := f.Recv()
-	if err != nil {
-		return err
-	}
-
-	if binary.LittleEndian.Uint64(ret.param) != 0 {
-		return xerrors.New("file close error")
-	}
-
-	return nil
+	_, err := f.request(ctx, AFC_OP_FILE_CLOSE, data, nil)
+	return err
 }
 
 func (f *FileManagerService) FileWrite(handle uint64, data []byte) error {
+	return f.FileWriteContext(context.Background(), handle, data)
+}
+
+// FileWriteContext is FileWrite with a context.
+func (f *FileManagerService) FileWriteContext(ctx context.Context, handle uint64, data []byte) error {
 	bHandle := make([]byte, 8)
 	binary.LittleEndian.PutUint64(bHandle, handle)
-	if err := f.Send(AFC_OP_FILE_WRITE, bHandle, data); err != nil {
-		return err
-	}
-
-	ret, err := f.Recv()
-	if err != nil {
-		return err
-	}
-
-	if binary.LittleEndian.Uint64(ret.param) != 0 {
-		return xerrors.New("file write error")
-	}
-
-	return nil
+	_, err := f.request(ctx, AFC_OP_FILE_WRITE, bHandle, data)
+	return err
 }
 
 func (f *FileManagerService) FileRead(handle, size uint64) ([]byte, error) {
+	return f.FileReadContext(context.Background(), handle, size)
+}
+
+// FileReadContext is FileRead with a context.
+func (f *FileManagerService) FileReadContext(ctx context.Context, handle, size uint64) ([]byte, error) {
 	buf := make([]byte, 0)
 
 	bHandle := make([]byte, 8)
@@ -242,11 +438,7 @@ func (f *FileManagerService) FileRead(handle, size uint64) ([]byte, error) {
 	bHandle = make([]byte, 8)
 	binary.LittleEndian.PutUint64(bHandle, size)
 	buf = append(buf, bHandle...)
-	if err := f.Send(AFC_OP_FILE_READ, buf, nil); err != nil {
-		return nil, err
-	}
-
-	ret, err := f.Recv()
+	ret, err := f.request(ctx, AFC_OP_FILE_READ, buf, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -254,31 +446,49 @@ func (f *FileManagerService) FileRead(handle, size uint64) ([]byte, error) {
 	return ret.payload, nil
 }
 
-func (f *FileManagerService) FileUpload(local io.Reader, remote string, cb func(int)) error {
-	handle, err := f.FileOpen(remote, AFC_FOPEN_WRONLY)
+// FileSeek moves the position of handle to offset, relative to whence:
+// io.SeekStart, io.SeekCurrent or io.SeekEnd.
+func (f *FileManagerService) FileSeek(handle uint64, offset int64, whence int) error {
+	return f.FileSeekContext(context.Background(), handle, offset, whence)
+}
+
+// FileSeekContext is FileSeek with a context.
+func (f *FileManagerService) FileSeekContext(ctx context.Context, handle uint64, offset int64, whence int) error {
+	param := afcUint64(handle)
+	param = append(param, afcUint64(uint64(whence))...)
+	param = append(param, afcUint64(uint64(offset))...)
+	_, err := f.request(ctx, AFC_OP_FILE_SEEK, param, nil)
+	return err
+}
+
+// FileTell returns the position of handle.
+func (f *FileManagerService) FileTell(handle uint64) (uint64, error) {
+	return f.FileTellContext(context.Background(), handle)
+}
+
+// FileTellContext is FileTell with a context.
+func (f *FileManagerService) FileTellContext(ctx context.Context, handle uint64) (uint64, error) {
+	ret, err := f.request(ctx, AFC_OP_FILE_TELL, afcUint64(handle), nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	defer func(f *FileManagerService, handle uint64) {
-		_ = f.FileClose(handle)
-	}(f, handle)
-
-	buf := make([]byte, DefaultChunkSize)
-	amount := 0
-	for {
-		switch nr, err := local.Read(buf[:]); true {
-		case nr < 0:
-			return xerrors.Errorf("cat: error reading: %w", err)
-		case nr == 0:
-			return nil
-		case nr > 0:
-			cb(amount)
-			if err := f.FileWrite(handle, buf); err != nil {
-				return err
-			}
-			amount++
-		}
+	if ret.header.Operation != AFC_OP_FILE_TELL_RES || len(ret.param) < 8 {
+		return 0, xerrors.Errorf("unexpected %s reply to FileRefTell", AFCOpName(ret.header.Operation))
 	}
+
+	return binary.LittleEndian.Uint64(ret.param), nil
+}
+
+// FileTruncate sets the size of the open file handle.
+func (f *FileManagerService) FileTruncate(handle, size uint64) error {
+	return f.FileTruncateContext(context.Background(), handle, size)
+}
+
+// FileTruncateContext is FileTruncate with a context.
+func (f *FileManagerService) FileTruncateContext(ctx context.Context, handle, size uint64) error {
+	param := append(afcUint64(handle), afcUint64(size)...)
+	_, err := f.request(ctx, AFC_OP_FILE_SET_SIZE, param, nil)
+	return err
 }
 
 func (f *FileManagerService) Send(op int, param, payload []byte) error {
@@ -290,7 +500,9 @@ func (f *FileManagerService) Send(op int, param, payload []byte) error {
 	f.header.ThisLength = uint64(40 + paramLen)
 	f.header.Operation = uint64(op)
 
-	// fmt.Printf("req: %#v\n", f.header)
+	if trace.Enabled() {
+		trace.Printf("afc", trace.Send, "#%d %s param=%d payload=%d", f.header.PacketNum, AFCOpName(f.header.Operation), paramLen, payloadLen)
+	}
 
 	wr := f.conn.Writer()
 	if err := binary.Write(wr, binary.LittleEndian, f.header); err != nil {
@@ -312,7 +524,47 @@ func (f *FileManagerService) Send(op int, param, payload []byte) error {
 	return nil
 }
 
+// request sends one AFC packet and reads the reply. ctx bounds the whole
+// exchange. A status reply other than AFC_E_SUCCESS is returned as an
+// AFCError.
+func (f *FileManagerService) request(ctx context.Context, op int, param, payload []byte) (_ AFCPacket, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer withContext(ctx, f.conn)(&err)
+
+	if err := f.Send(op, param, payload); err != nil {
+		return AFCPacket{}, err
+	}
+
+	ret, err := f.Recv()
+	if err != nil {
+		return AFCPacket{}, err
+	}
+	if err := ret.status(); err != nil {
+		return AFCPacket{}, err
+	}
+
+	return ret, nil
+}
+
+// status returns the AFCError of a status reply other than AFC_E_SUCCESS.
+func (p AFCPacket) status() error {
+	if p.header.Operation == AFC_OP_STATUS && len(p.param) >= 8 {
+		if code := AFCError(binary.LittleEndian.Uint64(p.param)); code != AFC_E_SUCCESS {
+			return code
+		}
+	}
+
+	return nil
+}
+
 func (f *FileManagerService) Recv() (AFCPacket, error) {
+	return f.recv(f.header.PacketNum)
+}
+
+// recv reads the reply to packet num. Only pipelined writes have replies to
+// older packets outstanding.
+func (f *FileManagerService) recv(num uint64) (AFCPacket, error) {
 	rr := f.conn.Reader()
 
 	var header AFCHeader
@@ -324,13 +576,11 @@ func (f *FileManagerService) Recv() (AFCPacket, error) {
 		return AFCPacket{}, xerrors.Errorf("Invalid AFC packet received")
 	}
 
-	if header.PacketNum != f.header.PacketNum {
+	if header.PacketNum != num {
 		return AFCPacket{}, xerrors.Errorf("Unexpected packet number (%d != %d) aborting.",
-			header.PacketNum, f.header.PacketNum)
+			header.PacketNum, num)
 	}
 
-	// fmt.Printf("resp: %#v\n", header)
-
 	param := make([]byte, header.ThisLength-40)
 	if _, err := io.ReadFull(rr, param); err != nil {
 		return AFCPacket{}, err
@@ -341,6 +591,14 @@ func (f *FileManagerService) Recv() (AFCPacket, error) {
 		return AFCPacket{}, err
 	}
 
+	if trace.Enabled() {
+		status := ""
+		if header.Operation == AFC_OP_STATUS && len(param) >= 8 {
+			status = " " + AFCError(binary.LittleEndian.Uint64(param)).Error()
+		}
+		trace.Printf("afc", trace.Recv, "#%d %s param=%d payload=%d%s", header.PacketNum, AFCOpName(header.Operation), len(param), len(payload), status)
+	}
+
 	return AFCPacket{
 		header:  header,
 		param:   param,
@@ -348,6 +606,17 @@ func (f *FileManagerService) Recv() (AFCPacket, error) {
 	}, nil
 }
 
+// afcPath encodes a path parameter, which AFC expects NUL terminated.
+func afcPath(path string) []byte {
+	return append([]byte(path), 0)
+}
+
+func afcUint64(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, v)
+	return buf
+}
+
 func (f *FileManagerService) buildSliceResult(buf []byte) []string {
 	result := make([]string, 0)
 	bs := bytes.Split(buf, []byte{0x00})
@@ -1,14 +1,91 @@
 package idevice
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+	"github.com/gofmt/iOSBox/pkg/trace"
 )
 
+func newTestFileManager(t *testing.T) (*idevicetest.AFC, *FileManagerService) {
+	t.Helper()
+
+	dev, device := newTestDevice(t)
+	afc := idevicetest.NewAFC()
+	dev.AddService("com.apple.afc", afc)
+
+	fileService, err := NewFileManagerService(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fileService.Close)
+
+	return afc, fileService
+}
+
+func TestFileManagerService_FileRead(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+	afc.WriteFile("test/test1", []byte("hello afc"))
+
+	handle, err := fileService.FileOpen("./test/test1", AFC_FOPEN_RDONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := fileService.FileRead(handle, 0x1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello afc" {
+		t.Fatalf("FileRead = %q", buf)
+	}
+
+	err = fileService.FileClose(handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestFileManagerService_FileUpload(t *testing.T) {
-	device, err := GetDevice()
+	afc, fileService := newTestFileManager(t)
+
+	if _, err := fileService.MakeDir("PublicStaging"); err != nil {
+		t.Fatal(err)
+	}
+
+	data := bytes.Repeat([]byte("iOSBox"), 100)
+	if err := fileService.FileUpload(bytes.NewReader(data), "PublicStaging/example.ipa", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := afc.ReadFile("PublicStaging/example.ipa"); !ok {
+		t.Fatal("uploaded file is missing")
+	}
+
+	names, err := fileService.ReadDir("PublicStaging")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(names) < 3 || names[2] != "example.ipa" {
+		t.Fatalf("ReadDir = %q", names)
+	}
+}
+
+func TestFileManagerService_Cancel(t *testing.T) {
+	dev, device := newTestDevice(t)
+	// An AFC service that never answers.
+	dev.AddService("com.apple.afc", idevicetest.ServiceFunc(func(conn net.Conn) {
+		_, _ = io.Copy(ioutil.Discard, conn)
+	}))
 
 	fileService, err := NewFileManagerService(device)
 	if err != nil {
@@ -16,21 +93,162 @@ func TestFileManagerService_FileUpload(t *testing.T) {
 	}
 	defer fileService.Close()
 
-	handle, err := fileService.FileOpen("./test/test1", AFC_FOPEN_RDONLY)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := fileService.ReadDirContext(ctx, "/"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadDirContext error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := fileService.ReadDirContext(ctx, "/"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadDirContext after cancel error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFileManagerService_Trace(t *testing.T) {
+	_, fileService := newTestFileManager(t)
+
+	var buf bytes.Buffer
+	trace.SetOutput(&buf)
+	defer trace.SetOutput(nil)
+
+	_, _ = fileService.GetFileInfo("/no/such/file")
+
+	out := buf.String()
+	for _, want := range []string{" afc > #", " GetFileInfo param=14 payload=0", " afc < #", " Status param=8 payload=0 afc: no such file or directory"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFileManagerService_PathOps(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+	afc.WriteFile("a/b/hello.txt", []byte("hello afc"))
+	afc.WriteFile("a/other.txt", []byte("other"))
+
+	if err := fileService.RenamePath("a/b/hello.txt", "a/hello.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if afc.Exists("a/b/hello.txt") || !afc.Exists("a/hello.txt") {
+		t.Fatal("RenamePath did not move the file")
+	}
+
+	if err := fileService.MakeLink(AFC_SYMLINK, "hello.txt", "a/link"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fileService.GetFileInfo("a/link")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if info["st_ifmt"] != "S_IFLNK" || info["LinkTarget"] != "hello.txt" {
+		t.Fatalf("GetFileInfo of the link = %v", info)
+	}
+	if err := fileService.MakeLink(AFC_HARDLINK, "a/hello.txt", "a/hard"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fileService.Truncate("a/hello.txt", 5); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := afc.ReadFile("a/hard"); string(data) != "hello" {
+		t.Fatalf("hard link content after Truncate = %q", data)
+	}
 
-	t.Log(handle)
+	mtime := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	if err := fileService.SetFileModTime("a/hello.txt", mtime); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := afc.ModTime("a/hello.txt"); !got.Equal(mtime) {
+		t.Fatalf("mtime = %v, want %v", got, mtime)
+	}
 
-	buf, err := fileService.FileRead(handle, 0x1000)
+	hash, err := fileService.Hash("a/hello.txt")
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Log(string(buf))
+	if want := sha1.Sum([]byte("hello")); !bytes.Equal(hash, want[:]) {
+		t.Fatalf("Hash = %x, want %x", hash, want)
+	}
 
-	err = fileService.FileClose(handle)
+	size, err := fileService.GetSizeOfPathContents("a")
 	if err != nil {
 		t.Fatal(err)
 	}
+	// hello.txt and its hard link count twice, like du -l.
+	if size["st_size"] != "15" {
+		t.Fatalf("GetSizeOfPathContents = %v, want st_size 15", size)
+	}
+
+	if _, err := fileService.RemovePath("a"); !errors.Is(err, AFC_E_DIR_NOT_EMPTY) {
+		t.Fatalf("RemovePath error = %v, want AFC_E_DIR_NOT_EMPTY", err)
+	}
+	if err := fileService.RemovePathAndContents("a"); err != nil {
+		t.Fatal(err)
+	}
+	if afc.Exists("a") || afc.Exists("a/hello.txt") {
+		t.Fatal("RemovePathAndContents left files behind")
+	}
+	if err := fileService.RenamePath("a", "b"); !errors.Is(err, AFC_E_OBJECT_NOT_FOUND) {
+		t.Fatalf("RenamePath error = %v, want AFC_E_OBJECT_NOT_FOUND", err)
+	}
+}
+
+func TestFileManagerService_SeekTell(t *testing.T) {
+	afc, fileService := newTestFileManager(t)
+	afc.WriteFile("seek.txt", []byte("0123456789"))
+
+	handle, err := fileService.FileOpen("seek.txt", AFC_FOPEN_RW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileService.FileClose(handle)
+
+	for _, step := range []struct {
+		offset int64
+		whence int
+		pos    uint64
+	}{
+		{4, io.SeekStart, 4},
+		{2, io.SeekCurrent, 6},
+		{-3, io.SeekEnd, 7},
+	} {
+		if err := fileService.FileSeek(handle, step.offset, step.whence); err != nil {
+			t.Fatal(err)
+		}
+		pos, err := fileService.FileTell(handle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != step.pos {
+			t.Fatalf("FileTell after FileSeek(%d, %d) = %d, want %d", step.offset, step.whence, pos, step.pos)
+		}
+	}
+
+	buf, err := fileService.FileRead(handle, 10)
+	if err != nil || string(buf) != "789" {
+		t.Fatalf("FileRead = %q, %v", buf, err)
+	}
+
+	if err := fileService.FileTruncate(handle, 3); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := afc.ReadFile("seek.txt"); string(data) != "012" {
+		t.Fatalf("content after FileTruncate = %q", data)
+	}
+	if err := fileService.FileSeek(handle, -1, io.SeekStart); !errors.Is(err, AFC_E_INVALID_ARG) {
+		t.Fatalf("FileSeek error = %v, want AFC_E_INVALID_ARG", err)
+	}
+}
+
+func TestFileManagerService_GetDeviceInfo(t *testing.T) {
+	_, fileService := newTestFileManager(t)
+
+	info, err := fileService.GetDeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["Model"] != "iPhone10,3" || info["FSBlockSize"] != "4096" {
+		t.Fatalf("GetDeviceInfo = %v", info)
+	}
 }
@@ -1,6 +1,7 @@
 package idevice
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -10,12 +11,13 @@ import (
 
 type ForwardService struct {
 	entry  *DeviceEntry
+	client *Client
 	listen net.Listener
 	conn   *USBConn
 }
 
 func NewForwardService(entry *DeviceEntry) *ForwardService {
-	return &ForwardService{entry: entry}
+	return &ForwardService{entry: entry, client: defaultClient}
 }
 
 func (fs *ForwardService) Start(hostPort, remotePort uint16, cb func(string, error)) (err error) {
@@ -27,6 +29,9 @@ func (fs *ForwardService) Start(hostPort, remotePort uint16, cb func(string, err
 	go func() {
 		for {
 			conn, err := fs.listen.Accept()
+			if xerrors.Is(err, net.ErrClosed) {
+				return
+			}
 			if err != nil {
 				cb(fmt.Sprintf("Error accepting new connection %v", err), nil)
 				continue
@@ -48,7 +53,7 @@ func (fs *ForwardService) Close() {
 
 func (fs *ForwardService) startNewProxyConn(conn net.Conn, deviceId int, remotePort uint16, cb func(string, error)) {
 	var err error
-	fs.conn, err = NewUSBConn()
+	fs.conn, err = fs.client.usbConn(context.Background())
 	if err != nil {
 		cb("", xerrors.Errorf("could not connect to usbmuxd: %w", err))
 		_ = conn.Close()
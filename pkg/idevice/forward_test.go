@@ -1,29 +1,69 @@
 package idevice
 
 import (
-	"os"
-	"os/signal"
+	"bufio"
+	"fmt"
+	"io"
+	"net"
 	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
 )
 
 func TestStartForward(t *testing.T) {
-	device, err := GetDevice()
+	dev, device := newTestDevice(t)
+	dev.Handle(27042, idevicetest.ServiceFunc(func(conn net.Conn) {
+		_, _ = io.Copy(conn, conn)
+	}))
+
+	port, err := freePort()
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	errs := make(chan error, 1)
 	service := NewForwardService(device)
-	if err := service.Start(27042, 27042, func(msg string, nerr error) {
+	if err := service.Start(uint16(port), 27042, func(msg string, nerr error) {
 		if nerr != nil {
-			t.Fatal(nerr)
+			errs <- nerr
 		}
-
-		t.Log(msg)
 	}); err != nil {
 		t.Fatal(err)
 	}
+	defer service.Close()
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	reply := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		reply <- line
+	}()
+
+	select {
+	case err := <-errs:
+		t.Fatal(err)
+	case line := <-reply:
+		if line != "ping\n" {
+			t.Fatalf("echo = %q", line)
+		}
+	}
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+	return ln.Addr().(*net.TCPAddr).Port, nil
 }
@@ -0,0 +1,92 @@
+package idevice
+
+import (
+	"context"
+
+	"howett.net/plist"
+)
+
+// HouseArrestServiceName is the lockdown name of house_arrest, which opens
+// AFC on the sandbox of an installed app.
+const HouseArrestServiceName = "com.apple.mobile.house_arrest"
+
+// house_arrest commands. VendContainer serves the whole app container and
+// only works for apps signed for development; VendDocuments serves just the
+// Documents directory, as "/Documents", of apps that enable iTunes file
+// sharing.
+const (
+	HouseArrestVendContainer = "VendContainer"
+	HouseArrestVendDocuments = "VendDocuments"
+)
+
+type houseArrestRequest struct {
+	Command    string
+	Identifier string
+}
+
+type houseArrestResponse struct {
+	Status string
+	Error  string
+}
+
+// NewAppContainerService opens AFC on the container of the app with the
+// given bundle ID.
+func NewAppContainerService(device *DeviceEntry, bundleID string) (*FileManagerService, error) {
+	return NewAppContainerServiceContext(context.Background(), device, bundleID)
+}
+
+// NewAppContainerServiceContext is NewAppContainerService with a context.
+func NewAppContainerServiceContext(ctx context.Context, device *DeviceEntry, bundleID string) (*FileManagerService, error) {
+	return newHouseArrestService(ctx, device, HouseArrestVendContainer, bundleID)
+}
+
+// NewAppDocumentsService opens AFC on the Documents directory of the app
+// with the given bundle ID.
+func NewAppDocumentsService(device *DeviceEntry, bundleID string) (*FileManagerService, error) {
+	return NewAppDocumentsServiceContext(context.Background(), device, bundleID)
+}
+
+// NewAppDocumentsServiceContext is NewAppDocumentsService with a context.
+func NewAppDocumentsServiceContext(ctx context.Context, device *DeviceEntry, bundleID string) (*FileManagerService, error) {
+	return newHouseArrestService(ctx, device, HouseArrestVendDocuments, bundleID)
+}
+
+func newHouseArrestService(ctx context.Context, device *DeviceEntry, command, bundleID string) (*FileManagerService, error) {
+	conn, err := ConnectToServiceContext(ctx, device, HouseArrestServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return vendAFC(ctx, conn, command, bundleID)
+}
+
+// vendAFC asks house_arrest for the sandbox of bundleID. Once it answers, the
+// same connection speaks AFC.
+func vendAFC(ctx context.Context, conn IConn, command, bundleID string) (_ *FileManagerService, err error) {
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	defer withContext(ctx, conn)(&err)
+
+	lockdown := NewLockdownConn(conn)
+	if err := lockdown.Send(houseArrestRequest{Command: command, Identifier: bundleID}); err != nil {
+		return nil, err
+	}
+
+	body, err := lockdown.Recv()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp houseArrestResponse
+	if _, err := plist.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, &HouseArrestError{Command: command, Identifier: bundleID, Err: resp.Error}
+	}
+
+	return newFileManagerService(conn), nil
+}
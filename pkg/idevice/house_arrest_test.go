@@ -0,0 +1,52 @@
+package idevice
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+func TestHouseArrest(t *testing.T) {
+	dev, entry := newTestDevice(t)
+	houseArrest := idevicetest.NewHouseArrest()
+	sandbox := houseArrest.AddApp("com.example.app")
+	sandbox.WriteFile("Documents/notes.db", []byte("sqlite"))
+	sandbox.WriteFile("Library/Caches/cache.bin", []byte("cache"))
+	dev.AddService(HouseArrestServiceName, houseArrest)
+
+	fileService, err := NewAppContainerService(entry, "com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileService.Close()
+
+	data, err := fs.ReadFile(fileService.FS(), "Library/Caches/cache.bin")
+	if err != nil || string(data) != "cache" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+	if _, err := fileService.MakeDir("tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if !sandbox.Exists("tmp") {
+		t.Fatal("MakeDir did not reach the app sandbox")
+	}
+
+	device := NewDevice(entry)
+	defer device.Close()
+	docs, err := device.AppDocuments("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer docs.Close()
+	if data, err := fs.ReadFile(docs.FS(), "Documents/notes.db"); err != nil || string(data) != "sqlite" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+
+	_, err = device.AppContainer("com.example.missing")
+	var haErr *HouseArrestError
+	if !errors.Is(err, ErrAppNotFound) || !errors.As(err, &haErr) || haErr.Identifier != "com.example.missing" {
+		t.Fatalf("AppContainer of a missing app error = %v, want ErrAppNotFound", err)
+	}
+}
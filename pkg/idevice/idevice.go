@@ -1,26 +1,36 @@
 package idevice
 
-import "errors"
+import (
+	"context"
+)
 
 func ConnectLockdownWithSession(entry *DeviceEntry) (*LockdownConn, error) {
-	conn, err := NewUSBConn()
+	return ConnectLockdownWithSessionContext(context.Background(), entry)
+}
+
+// ConnectLockdownWithSessionContext is ConnectLockdownWithSession with a context.
+func ConnectLockdownWithSessionContext(ctx context.Context, entry *DeviceEntry) (*LockdownConn, error) {
+	conn, err := NewUSBConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	// defer conn.Close()
 
-	cert, err := conn.GetCertificate(entry.Properties.SerialNumber)
+	cert, err := conn.GetCertificateContext(ctx, entry.Properties.SerialNumber)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	lockdown, err := conn.ConnectLockdown(entry.DeviceID)
+	lockdown, err := conn.ConnectLockdownContext(ctx, entry.DeviceID)
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	_, err = lockdown.StartSession(cert)
+	_, err = lockdown.StartSessionContext(ctx, cert)
 	if err != nil {
+		lockdown.Close()
 		return nil, err
 	}
 
@@ -28,72 +38,217 @@ func ConnectLockdownWithSession(entry *DeviceEntry) (*LockdownConn, error) {
 }
 
 func StartService(entry *DeviceEntry, name string) (*StartServiceResponse, error) {
-	lockdown, err := ConnectLockdownWithSession(entry)
+	return StartServiceContext(context.Background(), entry, name)
+}
+
+// StartServiceContext is StartService with a context.
+func StartServiceContext(ctx context.Context, entry *DeviceEntry, name string) (*StartServiceResponse, error) {
+	lockdown, err := ConnectLockdownWithSessionContext(ctx, entry)
 	if err != nil {
 		return nil, err
 	}
 	defer lockdown.Close()
 
-	return lockdown.StartService(name)
+	return lockdown.StartServiceContext(ctx, name)
 }
 
 func GetCertificate(udid string) (*Certificate, error) {
-	conn, err := NewUSBConn()
+	return GetCertificateContext(context.Background(), udid)
+}
+
+// GetCertificateContext is GetCertificate with a context.
+func GetCertificateContext(ctx context.Context, udid string) (*Certificate, error) {
+	conn, err := NewUSBConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Close()
 
-	return conn.GetCertificate(udid)
+	return conn.GetCertificateContext(ctx, udid)
 }
 
-func ConnectToService(entry *DeviceEntry, name string) (IConn, error) {
-	resp, err := StartService(entry, name)
+// SavePairRecord stores cert as the pair record of entry.
+func SavePairRecord(entry *DeviceEntry, cert *Certificate) error {
+	return SavePairRecordContext(context.Background(), entry, cert)
+}
+
+// SavePairRecordContext is SavePairRecord with a context.
+func SavePairRecordContext(ctx context.Context, entry *DeviceEntry, cert *Certificate) error {
+	conn, err := NewUSBConnContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer conn.Close()
+
+	return conn.SavePairRecordContext(ctx, entry.Properties.SerialNumber, entry.DeviceID, cert)
+}
+
+// DeletePairRecord removes the pair record of the device with the given udid.
+func DeletePairRecord(udid string) error {
+	return DeletePairRecordContext(context.Background(), udid)
+}
 
-	cert, err := GetCertificate(entry.Properties.SerialNumber)
+// DeletePairRecordContext is DeletePairRecord with a context.
+func DeletePairRecordContext(ctx context.Context, udid string) error {
+	conn, err := NewUSBConnContext(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer conn.Close()
+
+	return conn.DeletePairRecordContext(ctx, udid)
+}
+
+// ReadBUID returns the SystemBUID usbmuxd uses to identify this host.
+func ReadBUID() (string, error) {
+	return ReadBUIDContext(context.Background())
+}
+
+// ReadBUIDContext is ReadBUID with a context.
+func ReadBUIDContext(ctx context.Context) (string, error) {
+	conn, err := NewUSBConnContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return conn.ReadBUIDContext(ctx)
+}
+
+func ConnectToService(entry *DeviceEntry, name string) (IConn, error) {
+	return ConnectToServiceContext(context.Background(), entry, name)
+}
+
+// ConnectToServiceContext is ConnectToService with a context. It sets up a
+// lockdown session just for this call; use a Device to start several
+// services.
+func ConnectToServiceContext(ctx context.Context, entry *DeviceEntry, name string) (IConn, error) {
+	d := NewDevice(entry)
+	defer d.Close()
+
+	return d.ConnectToServiceContext(ctx, name)
+}
 
-	conn, err := NewUSBConn()
+// Listen opens a dedicated usbmuxd connection and streams device events
+// until ctx is done.
+func Listen(ctx context.Context) (<-chan DeviceEvent, error) {
+	conn, err := NewUSBConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := conn.ConnectWithStartServiceResponse(entry.DeviceID, resp, cert); err != nil {
+	events, err := conn.Listen(ctx)
+	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	return conn.Conn, nil
+	return events, nil
+}
+
+// GetDevices returns every device usbmuxd currently knows about.
+func GetDevices() ([]DeviceEntry, error) {
+	return GetDevicesContext(context.Background())
 }
 
-func GetDevice(udid ...string) (device *DeviceEntry, err error) {
-	conn, err := NewUSBConn()
+// GetDevicesContext is GetDevices with a context.
+func GetDevicesContext(ctx context.Context) ([]DeviceEntry, error) {
+	conn, err := NewUSBConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Close()
 
-	list, err := conn.ListDevices()
+	return conn.ListDevicesContext(ctx)
+}
+
+// DeviceFilter narrows down which attached device to use.
+type DeviceFilter struct {
+	// UDID selects a device by its usbmuxd serial number. Empty matches any.
+	UDID string
+	// ConnectionType is ConnectionTypeUSB, ConnectionTypeNetwork or empty for
+	// either.
+	ConnectionType string
+}
+
+// Match reports whether entry satisfies the filter.
+func (f DeviceFilter) Match(entry *DeviceEntry) bool {
+	if f.UDID != "" && entry.Properties.SerialNumber != f.UDID {
+		return false
+	}
+
+	return f.ConnectionType == "" || entry.Properties.ConnectionType == f.ConnectionType
+}
+
+// FilterDevices returns the entries in list that match filter.
+func FilterDevices(list []DeviceEntry, filter DeviceFilter) []DeviceEntry {
+	ret := make([]DeviceEntry, 0, len(list))
+	for i := range list {
+		if filter.Match(&list[i]) {
+			ret = append(ret, list[i])
+		}
+	}
+
+	return ret
+}
+
+// SelectDevice picks one device from list. A phone that is both plugged in
+// and synced over Wi-Fi shows up twice with the same UDID; the USB entry wins
+// unless the filter asks for the network one.
+func SelectDevice(list []DeviceEntry, filter DeviceFilter) (*DeviceEntry, error) {
+	if len(list) == 0 {
+		return nil, &describedError{"no iOS device connected", ErrDeviceNotFound}
+	}
+
+	matched := FilterDevices(list, filter)
+	if len(matched) == 0 {
+		if filter.UDID != "" {
+			return nil, &describedError{"device not found: " + filter.UDID, ErrDeviceNotFound}
+		}
+		return nil, &describedError{"no device connected over " + filter.ConnectionType, ErrDeviceNotFound}
+	}
+
+	device := &matched[0]
+	for i := range matched {
+		if matched[i].Properties.SerialNumber != device.Properties.SerialNumber {
+			continue
+		}
+		if !matched[i].IsNetwork() {
+			device = &matched[i]
+			break
+		}
+	}
+
+	return device, nil
+}
+
+// FindDevice returns the attached device selected by filter.
+func FindDevice(filter DeviceFilter) (*DeviceEntry, error) {
+	return FindDeviceContext(context.Background(), filter)
+}
+
+// FindDeviceContext is FindDevice with a context.
+func FindDeviceContext(ctx context.Context, filter DeviceFilter) (*DeviceEntry, error) {
+	list, err := GetDevicesContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(list) > 0 {
-		device = &list[0]
-		if len(udid) > 0 {
-			for _, entry := range list {
-				if udid[0] == entry.Properties.SerialNumber {
-					device = &entry
-					break
-				}
-			}
-		}
+	return SelectDevice(list, filter)
+}
+
+// GetDevice returns the device with the given udid, or the first attached
+// device when udid is omitted or empty.
+func GetDevice(udid ...string) (*DeviceEntry, error) {
+	return GetDeviceContext(context.Background(), udid...)
+}
 
-		return
+// GetDeviceContext is GetDevice with a context.
+func GetDeviceContext(ctx context.Context, udid ...string) (*DeviceEntry, error) {
+	var filter DeviceFilter
+	if len(udid) > 0 {
+		filter.UDID = udid[0]
 	}
 
-	return nil, errors.New("没有连接任何iOS设备")
+	return FindDeviceContext(ctx, filter)
 }
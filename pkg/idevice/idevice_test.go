@@ -0,0 +1,210 @@
+package idevice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+)
+
+const testUDID = "00008020-001A2B3C4D5E6F01"
+
+// newTestDevice starts a fake usbmuxd with one attached device, points
+// SocketAddress at it and returns the fake together with its DeviceEntry.
+func newTestDevice(t testing.TB) (*idevicetest.Device, *DeviceEntry) {
+	t.Helper()
+
+	dev := idevicetest.NewDevice(testUDID)
+	newTestServer(t, dev)
+
+	entry, err := GetDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dev, entry
+}
+
+// newTestServer starts a fake usbmuxd with devices attached and points
+// SocketAddress at it for the duration of the test.
+func newTestServer(t testing.TB, devices ...*idevicetest.Device) *idevicetest.Server {
+	t.Helper()
+
+	srv, err := idevicetest.NewServer(devices...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := SocketAddress
+	SocketAddress = srv.Addr
+	t.Cleanup(func() {
+		SocketAddress = old
+		srv.Close()
+	})
+
+	return srv
+}
+
+func TestGetDevice(t *testing.T) {
+	_, entry := newTestDevice(t)
+
+	if entry.Properties.SerialNumber != testUDID {
+		t.Fatalf("SerialNumber = %q, want %q", entry.Properties.SerialNumber, testUDID)
+	}
+
+	if _, err := GetDevice("unknown"); err == nil {
+		t.Fatal("expected an error for an unknown udid")
+	}
+}
+
+func TestGetDevice_UDID(t *testing.T) {
+	first := idevicetest.NewDevice(testUDID)
+	second := idevicetest.NewDevice("00008101-000A1B2C3D4E5F02")
+	second.DeviceID = 2
+	newTestServer(t, first, second)
+
+	device, err := GetDevice(second.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.DeviceID != 2 {
+		t.Fatalf("DeviceID = %d, want 2", device.DeviceID)
+	}
+
+	device, err = GetDevice("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.DeviceID != 1 {
+		t.Fatalf("DeviceID = %d, want 1", device.DeviceID)
+	}
+
+	list, err := GetDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Properties.SerialNumber == list[1].Properties.SerialNumber {
+		t.Fatalf("GetDevices = %+v", list)
+	}
+}
+
+func TestSelectDevice_Network(t *testing.T) {
+	usb := idevicetest.NewDevice(testUDID)
+	usb.DeviceID = 3
+	wifi := idevicetest.NewNetworkDevice(testUDID)
+	wifi.DeviceID = 4
+	other := idevicetest.NewNetworkDevice("00008101-000A1B2C3D4E5F02")
+	other.DeviceID = 5
+	newTestServer(t, wifi, other, usb)
+
+	tests := []struct {
+		filter DeviceFilter
+		id     int
+	}{
+		{DeviceFilter{}, 3},
+		{DeviceFilter{UDID: testUDID}, 3},
+		{DeviceFilter{UDID: testUDID, ConnectionType: ConnectionTypeNetwork}, 4},
+		{DeviceFilter{ConnectionType: ConnectionTypeNetwork}, 4},
+		{DeviceFilter{UDID: other.SerialNumber}, 5},
+	}
+	for _, tt := range tests {
+		device, err := FindDevice(tt.filter)
+		if err != nil {
+			t.Fatalf("FindDevice(%+v): %v", tt.filter, err)
+		}
+		if device.DeviceID != tt.id {
+			t.Errorf("FindDevice(%+v) = %d, want %d", tt.filter, device.DeviceID, tt.id)
+		}
+	}
+
+	if _, err := FindDevice(DeviceFilter{UDID: other.SerialNumber, ConnectionType: ConnectionTypeUSB}); err == nil {
+		t.Fatal("expected no USB device for a Wi-Fi only phone")
+	}
+
+	device, err := FindDevice(DeviceFilter{UDID: testUDID, ConnectionType: ConnectionTypeNetwork})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !device.IsNetwork() || device.Properties.IPAddress().String() != "192.168.1.10" {
+		t.Fatalf("network device = %+v", device)
+	}
+
+	lockdown, err := ConnectLockdownWithSession(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockdown.Close()
+}
+
+func TestParseConnectionType(t *testing.T) {
+	for in, want := range map[string]string{"": "", "any": "", "USB": ConnectionTypeUSB, "wifi": ConnectionTypeNetwork, "network": ConnectionTypeNetwork} {
+		got, err := ParseConnectionType(in)
+		if err != nil || got != want {
+			t.Errorf("ParseConnectionType(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseConnectionType("bluetooth"); err == nil {
+		t.Error("expected an error for an unknown connection type")
+	}
+}
+
+func TestConnectToService_Unknown(t *testing.T) {
+	_, entry := newTestDevice(t)
+
+	if _, err := ConnectToService(entry, "com.apple.unknown"); err == nil {
+		t.Fatal("expected an error for an unregistered service")
+	}
+}
+
+func TestListen(t *testing.T) {
+	first := idevicetest.NewDevice(testUDID)
+	srv := newTestServer(t, first)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := Listen(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func() DeviceEvent {
+		t.Helper()
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("event channel closed")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a device event")
+		}
+		return DeviceEvent{}
+	}
+
+	if ev := next(); ev.Type != DeviceAttached || ev.Properties.SerialNumber != testUDID {
+		t.Fatalf("initial event = %+v", ev)
+	}
+
+	second := idevicetest.NewDevice("00008101-000A1B2C3D4E5F02")
+	second.DeviceID = 2
+	srv.Attach(second)
+	if ev := next(); ev.Type != DeviceAttached || ev.DeviceID != 2 {
+		t.Fatalf("attach event = %+v", ev)
+	}
+
+	srv.Paired(2)
+	if ev := next(); ev.Type != DevicePaired || ev.Properties.SerialNumber != second.SerialNumber {
+		t.Fatalf("paired event = %+v", ev)
+	}
+
+	srv.Detach(1)
+	if ev := next(); ev.Type != DeviceDetached || ev.Properties.SerialNumber != testUDID {
+		t.Fatalf("detach event = %+v", ev)
+	}
+
+	cancel()
+	for range events {
+	}
+}
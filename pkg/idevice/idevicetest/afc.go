@@ -0,0 +1,659 @@
+package idevicetest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AFC operations understood by the fake.
+const (
+	afcOpStatus                = 0x01
+	afcOpData                  = 0x02
+	afcOpReadDir               = 0x03
+	afcOpTruncate              = 0x07
+	afcOpRemovePath            = 0x08
+	afcOpMakeDir               = 0x09
+	afcOpGetFileInfo           = 0x0A
+	afcOpGetDevInfo            = 0x0B
+	afcOpFileOpen              = 0x0D
+	afcOpFileOpenRes           = 0x0E
+	afcOpFileRead              = 0x0F
+	afcOpFileWrite             = 0x10
+	afcOpFileSeek              = 0x11
+	afcOpFileTell              = 0x12
+	afcOpFileTellRes           = 0x13
+	afcOpFileClose             = 0x14
+	afcOpFileSetSize           = 0x15
+	afcOpRenamePath            = 0x18
+	afcOpMakeLink              = 0x1C
+	afcOpGetFileHash           = 0x1D
+	afcOpSetFileModTime        = 0x1E
+	afcOpGetFileHashRange      = 0x1F
+	afcOpGetSizeOfPathContents = 0x21
+	afcOpRemovePathAndContents = 0x22
+)
+
+// AFC status codes returned by the fake.
+const (
+	AFCSuccess        = 0
+	AFCUnknownError   = 1
+	AFCInvalidArg     = 7
+	AFCObjectNotFound = 8
+	AFCObjectIsDir    = 9
+	AFCOpNotSupported = 15
+	AFCObjectExists   = 16
+	AFCDirNotEmpty    = 33
+)
+
+const afcMagic = "CFA6LPAA"
+
+type afcHeader struct {
+	Magic        [8]byte
+	EntireLength uint64
+	ThisLength   uint64
+	PacketNum    uint64
+	Operation    uint64
+}
+
+type afcNode struct {
+	dir   bool
+	data  []byte
+	mtime time.Time
+	// link is the target of a symbolic link.
+	link string
+}
+
+type afcHandle struct {
+	name   string
+	pos    int64
+	append bool
+}
+
+// AFC is a fake com.apple.afc backed by an in-memory file tree. Paths are
+// resolved relative to the media root, so "/a", "a" and "./a" are the same.
+type AFC struct {
+	mu      sync.Mutex
+	nodes   map[string]*afcNode
+	handles map[uint64]*afcHandle
+	next    uint64
+	noHash  bool
+}
+
+// NewAFC returns an empty file tree.
+func NewAFC() *AFC {
+	return &AFC{
+		nodes:   map[string]*afcNode{"/": {dir: true, mtime: time.Now()}},
+		handles: make(map[uint64]*afcHandle),
+		next:    1,
+	}
+}
+
+// WriteFile creates name and any missing parent directories.
+func (a *AFC) WriteFile(name string, data []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name = cleanPath(name)
+	a.mkdirAll(path.Dir(name))
+	a.nodes[name] = &afcNode{data: append([]byte(nil), data...), mtime: time.Now()}
+}
+
+// ReadFile returns the content of name.
+func (a *AFC) ReadFile(name string) ([]byte, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n, ok := a.nodes[cleanPath(name)]
+	if !ok || n.dir {
+		return nil, false
+	}
+
+	return append([]byte(nil), n.data...), true
+}
+
+// Symlink creates a symbolic link at name pointing at target.
+func (a *AFC) Symlink(target, name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name = cleanPath(name)
+	a.mkdirAll(path.Dir(name))
+	a.nodes[name] = &afcNode{link: target, mtime: time.Now()}
+}
+
+// DisableHash makes the fake refuse the AFC hash operations, as older
+// devices do.
+func (a *AFC) DisableHash() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.noHash = true
+}
+
+// ModTime returns the modification time of name.
+func (a *AFC) ModTime(name string) (time.Time, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n, ok := a.nodes[cleanPath(name)]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return n.mtime, true
+}
+
+// Exists reports whether name is a file or directory.
+func (a *AFC) Exists(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, ok := a.nodes[cleanPath(name)]
+	return ok
+}
+
+func (a *AFC) Serve(conn net.Conn) {
+	for {
+		var hdr afcHeader
+		if err := binary.Read(conn, binary.LittleEndian, &hdr); err != nil {
+			return
+		}
+		if string(hdr.Magic[:]) != afcMagic || hdr.ThisLength < 40 || hdr.EntireLength < hdr.ThisLength {
+			return
+		}
+
+		param := make([]byte, hdr.ThisLength-40)
+		if _, err := io.ReadFull(conn, param); err != nil {
+			return
+		}
+		payload := make([]byte, hdr.EntireLength-hdr.ThisLength)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+
+		op, rparam, rpayload := a.handle(hdr.Operation, param, payload)
+		if err := writeAFC(conn, hdr.PacketNum, op, rparam, rpayload); err != nil {
+			return
+		}
+	}
+}
+
+func (a *AFC) handle(op uint64, param, payload []byte) (uint64, []byte, []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch op {
+	case afcOpGetDevInfo:
+		return afcOpData, nil, pairs(
+			"Model", "iPhone10,3",
+			"FSTotalBytes", "64000000000",
+			"FSFreeBytes", "32000000000",
+			"FSBlockSize", "4096",
+		)
+	case afcOpReadDir:
+		name := cleanPath(cString(param))
+		n, ok := a.nodes[name]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		if !n.dir {
+			return status(AFCInvalidArg)
+		}
+		return afcOpData, nil, pairs(append([]string{".", ".."}, a.children(name)...)...)
+	case afcOpMakeDir:
+		name := cleanPath(cString(param))
+		if n, ok := a.nodes[name]; ok && !n.dir {
+			return status(AFCObjectExists)
+		}
+		a.mkdirAll(name)
+		return status(AFCSuccess)
+	case afcOpRemovePath:
+		name := cleanPath(cString(param))
+		n, ok := a.nodes[name]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		if n.dir && len(a.children(name)) > 0 {
+			return status(AFCDirNotEmpty)
+		}
+		delete(a.nodes, name)
+		return status(AFCSuccess)
+	case afcOpRemovePathAndContents:
+		name := cleanPath(cString(param))
+		if _, ok := a.nodes[name]; !ok || name == "/" {
+			return status(AFCObjectNotFound)
+		}
+		a.removeAll(name)
+		return status(AFCSuccess)
+	case afcOpRenamePath:
+		from, rest := splitCString(param)
+		to, _ := splitCString(rest)
+		return status(a.rename(cleanPath(from), cleanPath(to)))
+	case afcOpMakeLink:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		target, rest := splitCString(param[8:])
+		link, _ := splitCString(rest)
+		return status(a.makeLink(binary.LittleEndian.Uint64(param), target, cleanPath(link)))
+	case afcOpTruncate:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		n, ok := a.nodes[cleanPath(cString(param[8:]))]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		if n.dir {
+			return status(AFCObjectIsDir)
+		}
+		n.truncate(int64(binary.LittleEndian.Uint64(param)))
+		return status(AFCSuccess)
+	case afcOpSetFileModTime:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		n, ok := a.nodes[cleanPath(cString(param[8:]))]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		n.mtime = time.Unix(0, int64(binary.LittleEndian.Uint64(param)))
+		return status(AFCSuccess)
+	case afcOpGetFileHash:
+		if a.noHash {
+			return status(AFCOpNotSupported)
+		}
+		n, ok := a.nodes[cleanPath(cString(param))]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		if n.dir {
+			return status(AFCObjectIsDir)
+		}
+		sum := sha1.Sum(n.data)
+		return afcOpData, nil, sum[:]
+	case afcOpGetFileHashRange:
+		if a.noHash {
+			return status(AFCOpNotSupported)
+		}
+		if len(param) < 16 {
+			return status(AFCInvalidArg)
+		}
+		n, ok := a.nodes[cleanPath(cString(param[16:]))]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		if n.dir {
+			return status(AFCObjectIsDir)
+		}
+		off := binary.LittleEndian.Uint64(param)
+		end := off + binary.LittleEndian.Uint64(param[8:])
+		if off > uint64(len(n.data)) || end < off || end > uint64(len(n.data)) {
+			return status(AFCInvalidArg)
+		}
+		sum := sha1.Sum(n.data[off:end])
+		return afcOpData, nil, sum[:]
+	case afcOpGetSizeOfPathContents:
+		name := cleanPath(cString(param))
+		if _, ok := a.nodes[name]; !ok {
+			return status(AFCObjectNotFound)
+		}
+		var size int
+		for p, n := range a.nodes {
+			if p == name || strings.HasPrefix(p, strings.TrimSuffix(name, "/")+"/") {
+				size += len(n.data)
+			}
+		}
+		return afcOpData, nil, pairs(
+			"st_size", fmt.Sprintf("%d", size),
+			"st_blocks", fmt.Sprintf("%d", (size+511)/512),
+		)
+	case afcOpGetFileInfo:
+		n, ok := a.nodes[cleanPath(cString(param))]
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+		return afcOpData, nil, fileInfo(n)
+	case afcOpFileOpen:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		return a.open(binary.LittleEndian.Uint64(param), cleanPath(cString(param[8:])))
+	case afcOpFileRead:
+		if len(param) < 16 {
+			return status(AFCInvalidArg)
+		}
+		h, n, code := a.handleNode(binary.LittleEndian.Uint64(param))
+		if code != AFCSuccess {
+			return status(code)
+		}
+		size := int64(binary.LittleEndian.Uint64(param[8:]))
+		if h.pos >= int64(len(n.data)) {
+			return afcOpData, nil, nil
+		}
+		end := h.pos + size
+		if end > int64(len(n.data)) {
+			end = int64(len(n.data))
+		}
+		data := append([]byte(nil), n.data[h.pos:end]...)
+		h.pos = end
+		return afcOpData, nil, data
+	case afcOpFileWrite:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		h, n, code := a.handleNode(binary.LittleEndian.Uint64(param))
+		if code != AFCSuccess {
+			return status(code)
+		}
+		if h.append {
+			h.pos = int64(len(n.data))
+		}
+		if end := h.pos + int64(len(payload)); end > int64(len(n.data)) {
+			n.data = append(n.data, make([]byte, end-int64(len(n.data)))...)
+		}
+		copy(n.data[h.pos:], payload)
+		h.pos += int64(len(payload))
+		n.mtime = time.Now()
+		return status(AFCSuccess)
+	case afcOpFileSeek:
+		if len(param) < 24 {
+			return status(AFCInvalidArg)
+		}
+		h, n, code := a.handleNode(binary.LittleEndian.Uint64(param))
+		if code != AFCSuccess {
+			return status(code)
+		}
+		offset := int64(binary.LittleEndian.Uint64(param[16:]))
+		switch binary.LittleEndian.Uint64(param[8:]) {
+		case 0:
+		case 1:
+			offset += h.pos
+		case 2:
+			offset += int64(len(n.data))
+		default:
+			return status(AFCInvalidArg)
+		}
+		if offset < 0 {
+			return status(AFCInvalidArg)
+		}
+		h.pos = offset
+		return status(AFCSuccess)
+	case afcOpFileTell:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		h, _, code := a.handleNode(binary.LittleEndian.Uint64(param))
+		if code != AFCSuccess {
+			return status(code)
+		}
+		pos := make([]byte, 8)
+		binary.LittleEndian.PutUint64(pos, uint64(h.pos))
+		return afcOpFileTellRes, pos, nil
+	case afcOpFileSetSize:
+		if len(param) < 16 {
+			return status(AFCInvalidArg)
+		}
+		_, n, code := a.handleNode(binary.LittleEndian.Uint64(param))
+		if code != AFCSuccess {
+			return status(code)
+		}
+		n.truncate(int64(binary.LittleEndian.Uint64(param[8:])))
+		return status(AFCSuccess)
+	case afcOpFileClose:
+		if len(param) < 8 {
+			return status(AFCInvalidArg)
+		}
+		fd := binary.LittleEndian.Uint64(param)
+		if _, ok := a.handles[fd]; !ok {
+			return status(AFCInvalidArg)
+		}
+		delete(a.handles, fd)
+		return status(AFCSuccess)
+	}
+
+	return status(AFCOpNotSupported)
+}
+
+func (a *AFC) open(mode uint64, name string) (uint64, []byte, []byte) {
+	n, ok := a.nodes[name]
+	if ok && n.dir {
+		return status(AFCObjectIsDir)
+	}
+
+	switch mode {
+	case 1: // r
+		if !ok {
+			return status(AFCObjectNotFound)
+		}
+	case 2, 5, 6: // r+, a, a+
+		if !ok {
+			n = &afcNode{mtime: time.Now()}
+		}
+	case 3, 4: // w, w+
+		n = &afcNode{mtime: time.Now()}
+	default:
+		return status(AFCInvalidArg)
+	}
+
+	if parent, ok := a.nodes[path.Dir(name)]; !ok || !parent.dir {
+		return status(AFCObjectNotFound)
+	}
+	a.nodes[name] = n
+
+	fd := a.next
+	a.next++
+	a.handles[fd] = &afcHandle{name: name, append: mode == 5 || mode == 6}
+
+	param := make([]byte, 8)
+	binary.LittleEndian.PutUint64(param, fd)
+	return afcOpFileOpenRes, param, nil
+}
+
+func (a *AFC) rename(from, to string) int {
+	n, ok := a.nodes[from]
+	if !ok || from == "/" {
+		return AFCObjectNotFound
+	}
+	if parent, ok := a.nodes[path.Dir(to)]; !ok || !parent.dir {
+		return AFCObjectNotFound
+	}
+	if old, ok := a.nodes[to]; ok && old.dir && (!n.dir || len(a.children(to)) > 0) {
+		return AFCObjectExists
+	}
+
+	if n.dir {
+		prefix := from + "/"
+		for p, child := range a.nodes {
+			if strings.HasPrefix(p, prefix) {
+				delete(a.nodes, p)
+				a.nodes[to+"/"+p[len(prefix):]] = child
+			}
+		}
+	}
+	delete(a.nodes, from)
+	a.nodes[to] = n
+
+	return AFCSuccess
+}
+
+func (a *AFC) makeLink(linkType uint64, target, link string) int {
+	if _, ok := a.nodes[link]; ok {
+		return AFCObjectExists
+	}
+	if parent, ok := a.nodes[path.Dir(link)]; !ok || !parent.dir {
+		return AFCObjectNotFound
+	}
+
+	switch linkType {
+	case 1: // hard link
+		n, ok := a.nodes[cleanPath(target)]
+		if !ok {
+			return AFCObjectNotFound
+		}
+		if n.dir {
+			return AFCObjectIsDir
+		}
+		a.nodes[link] = n
+	case 2: // symbolic link
+		a.nodes[link] = &afcNode{link: target, mtime: time.Now()}
+	default:
+		return AFCInvalidArg
+	}
+
+	return AFCSuccess
+}
+
+func (a *AFC) removeAll(name string) {
+	prefix := name + "/"
+	for p := range a.nodes {
+		if p == name || strings.HasPrefix(p, prefix) {
+			delete(a.nodes, p)
+		}
+	}
+}
+
+func (n *afcNode) truncate(size int64) {
+	if size < int64(len(n.data)) {
+		n.data = n.data[:size]
+	} else {
+		n.data = append(n.data, make([]byte, size-int64(len(n.data)))...)
+	}
+	n.mtime = time.Now()
+}
+
+func (a *AFC) handleNode(fd uint64) (*afcHandle, *afcNode, int) {
+	h, ok := a.handles[fd]
+	if !ok {
+		return nil, nil, AFCInvalidArg
+	}
+
+	n, ok := a.nodes[h.name]
+	if !ok {
+		return nil, nil, AFCObjectNotFound
+	}
+
+	return h, n, AFCSuccess
+}
+
+func (a *AFC) children(dir string) []string {
+	prefix := dir + "/"
+	if dir == "/" {
+		prefix = "/"
+	}
+
+	names := make([]string, 0)
+	for name := range a.nodes {
+		if name == dir || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if rest := name[len(prefix):]; !strings.Contains(rest, "/") {
+			names = append(names, rest)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (a *AFC) mkdirAll(name string) {
+	for p := name; ; p = path.Dir(p) {
+		if _, ok := a.nodes[p]; !ok {
+			a.nodes[p] = &afcNode{dir: true, mtime: time.Now()}
+		}
+		if p == "/" {
+			return
+		}
+	}
+}
+
+func fileInfo(n *afcNode) []byte {
+	ifmt := "S_IFREG"
+	switch {
+	case n.dir:
+		ifmt = "S_IFDIR"
+	case n.link != "":
+		ifmt = "S_IFLNK"
+	}
+	mtime := fmt.Sprintf("%d", n.mtime.UnixNano())
+
+	info := []string{
+		"st_size", fmt.Sprintf("%d", len(n.data)),
+		"st_blocks", fmt.Sprintf("%d", (len(n.data)+511)/512),
+		"st_nlink", "1",
+		"st_ifmt", ifmt,
+		"st_mtime", mtime,
+		"st_birthtime", mtime,
+	}
+	if n.link != "" {
+		info = append(info, "LinkTarget", n.link)
+	}
+
+	return pairs(info...)
+}
+
+func status(code int) (uint64, []byte, []byte) {
+	param := make([]byte, 8)
+	binary.LittleEndian.PutUint64(param, uint64(code))
+	return afcOpStatus, param, nil
+}
+
+func pairs(ss ...string) []byte {
+	buf := new(bytes.Buffer)
+	for _, s := range ss {
+		buf.WriteString(s)
+		buf.WriteByte(0)
+	}
+
+	return buf.Bytes()
+}
+
+func writeAFC(w io.Writer, num, op uint64, param, payload []byte) error {
+	var magic [8]byte
+	copy(magic[:], afcMagic)
+
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, afcHeader{
+		Magic:        magic,
+		EntireLength: uint64(40 + len(param) + len(payload)),
+		ThisLength:   uint64(40 + len(param)),
+		PacketNum:    num,
+		Operation:    op,
+	})
+	buf.Write(param)
+	buf.Write(payload)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
+
+// splitCString returns the NUL terminated string at the start of b and
+// what follows it.
+func splitCString(b []byte) (string, []byte) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return string(b), nil
+	}
+
+	return string(b[:i]), b[i+1:]
+}
+
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
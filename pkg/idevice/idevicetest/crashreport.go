@@ -0,0 +1,46 @@
+package idevicetest
+
+import (
+	"io"
+	"net"
+	"path"
+	"sync"
+)
+
+// CrashReportMover is a fake com.apple.crashreportmover. Reports added with
+// Crash are pending until a client connects, which moves them into Reports,
+// the fake com.apple.crashreportcopymobile.
+type CrashReportMover struct {
+	// Reports is the crash report directory.
+	Reports *AFC
+
+	mu      sync.Mutex
+	pending map[string][]byte
+}
+
+// NewCrashReportMover returns a mover with an empty crash report directory.
+func NewCrashReportMover() *CrashReportMover {
+	return &CrashReportMover{
+		Reports: NewAFC(),
+		pending: make(map[string][]byte),
+	}
+}
+
+// Crash queues a report that the next move puts at name.
+func (m *CrashReportMover) Crash(name string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending[name] = append([]byte(nil), data...)
+}
+
+func (m *CrashReportMover) Serve(conn net.Conn) {
+	m.mu.Lock()
+	for name, data := range m.pending {
+		m.Reports.WriteFile(path.Clean("/"+name), data)
+	}
+	m.pending = make(map[string][]byte)
+	m.mu.Unlock()
+
+	_, _ = io.WriteString(conn, "ping")
+}
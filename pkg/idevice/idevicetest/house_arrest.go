@@ -0,0 +1,58 @@
+package idevicetest
+
+import (
+	"net"
+	"sync"
+)
+
+// HouseArrest is a fake com.apple.mobile.house_arrest. Every app has its own
+// AFC tree, which is served for both VendContainer and VendDocuments once the
+// vend request is answered.
+type HouseArrest struct {
+	mu   sync.Mutex
+	apps map[string]*AFC
+}
+
+// NewHouseArrest returns a house_arrest that knows no apps.
+func NewHouseArrest() *HouseArrest {
+	return &HouseArrest{apps: make(map[string]*AFC)}
+}
+
+// AddApp returns the sandbox of the app with the given bundle ID, creating
+// it if needed.
+func (h *HouseArrest) AddApp(bundleID string) *AFC {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	afc, ok := h.apps[bundleID]
+	if !ok {
+		afc = NewAFC()
+		h.apps[bundleID] = afc
+	}
+
+	return afc
+}
+
+func (h *HouseArrest) Serve(conn net.Conn) {
+	req, format, err := ReadPlist(conn)
+	if err != nil {
+		return
+	}
+
+	id, _ := req["Identifier"].(string)
+	h.mu.Lock()
+	afc, ok := h.apps[id]
+	h.mu.Unlock()
+
+	switch {
+	case req["Command"] != "VendContainer" && req["Command"] != "VendDocuments":
+		_ = WritePlist(conn, map[string]interface{}{"Error": "UnknownCommand"}, format)
+	case !ok:
+		_ = WritePlist(conn, map[string]interface{}{"Error": "ApplicationLookupFailed"}, format)
+	default:
+		if err := WritePlist(conn, map[string]interface{}{"Status": "Complete"}, format); err != nil {
+			return
+		}
+		afc.Serve(conn)
+	}
+}
@@ -0,0 +1,116 @@
+package idevicetest
+
+import (
+	"net"
+	"sync"
+)
+
+// browsePageSize is how many apps are sent per Browse response, small enough
+// that tests exercise the paging logic.
+const browsePageSize = 2
+
+// InstallationProxy is a fake com.apple.mobile.installation_proxy.
+type InstallationProxy struct {
+	mu   sync.Mutex
+	apps []map[string]interface{}
+}
+
+// NewInstallationProxy returns a proxy that knows about apps. Every app needs
+// at least CFBundleIdentifier and ApplicationType ("User" or "System").
+func NewInstallationProxy(apps ...map[string]interface{}) *InstallationProxy {
+	return &InstallationProxy{apps: apps}
+}
+
+// Apps returns the currently installed apps.
+func (p *InstallationProxy) Apps() []map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]map[string]interface{}(nil), p.apps...)
+}
+
+func (p *InstallationProxy) Serve(conn net.Conn) {
+	PlistHandlerFunc(p.handle).Serve(conn)
+}
+
+func (p *InstallationProxy) handle(req map[string]interface{}) []interface{} {
+	switch req["Command"] {
+	case "Browse":
+		appType := ""
+		if opts, ok := req["ClientOptions"].(map[string]interface{}); ok {
+			appType, _ = opts["ApplicationType"].(string)
+		}
+		return p.browse(appType)
+	case "Install":
+		pkg, _ := req["PackagePath"].(string)
+		if pkg == "" {
+			return []interface{}{map[string]interface{}{"Error": "InstallProhibited"}}
+		}
+		// The fake cannot unpack an IPA, so the package path doubles as the
+		// bundle identifier of the installed app.
+		p.mu.Lock()
+		p.apps = append(p.apps, map[string]interface{}{
+			"CFBundleIdentifier": pkg,
+			"ApplicationType":    "User",
+		})
+		p.mu.Unlock()
+		return progress()
+	case "Uninstall":
+		id, _ := req["ApplicationIdentifier"].(string)
+		if !p.remove(id) {
+			return []interface{}{map[string]interface{}{"Error": "APIInternalError"}}
+		}
+		return progress()
+	}
+
+	return []interface{}{map[string]interface{}{"Error": "UnknownCommand"}}
+}
+
+func (p *InstallationProxy) browse(appType string) []interface{} {
+	p.mu.Lock()
+	list := make([]interface{}, 0)
+	for _, app := range p.apps {
+		if appType == "" || appType == "Any" || app["ApplicationType"] == appType {
+			list = append(list, app)
+		}
+	}
+	p.mu.Unlock()
+
+	resps := make([]interface{}, 0)
+	for i := 0; i < len(list); i += browsePageSize {
+		end := i + browsePageSize
+		if end > len(list) {
+			end = len(list)
+		}
+		resps = append(resps, map[string]interface{}{
+			"Status":        "BrowsingApplications",
+			"CurrentIndex":  i,
+			"CurrentAmount": end - i,
+			"CurrentList":   list[i:end],
+		})
+	}
+
+	return append(resps, map[string]interface{}{"Status": "Complete"})
+}
+
+func (p *InstallationProxy) remove(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, app := range p.apps {
+		if app["CFBundleIdentifier"] == id {
+			p.apps = append(p.apps[:i], p.apps[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func progress() []interface{} {
+	return []interface{}{
+		map[string]interface{}{"Status": "PreflightingApplication", "PercentComplete": 10},
+		map[string]interface{}{"Status": "InstallingApplication", "PercentComplete": 60},
+		map[string]interface{}{"Status": "Complete"},
+	}
+}
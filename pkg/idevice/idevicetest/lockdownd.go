@@ -0,0 +1,349 @@
+package idevicetest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Lockdownd is a fake lockdownd. Sessions never enable SSL so the conversation
+// stays in plain text.
+type Lockdownd struct {
+	// Domains holds the values returned by GetValue, keyed by domain. The
+	// empty domain is the global one.
+	Domains map[string]map[string]interface{}
+	// HostIDs are the hosts the device trusts. It starts out trusting the
+	// host in the device's pair record.
+	HostIDs []string
+	// PairErrors are answered, one per attempt, to Pair requests before the
+	// device finally accepts, e.g. "PasswordProtected" followed by
+	// "PairingDialogResponsePending" while the user unlocks and taps Trust.
+	PairErrors []string
+
+	mu       sync.Mutex
+	services map[string]uint16
+	key      *rsa.PrivateKey
+	sessions int
+}
+
+// NewLockdownd returns a lockdownd for d populated with typical device values.
+func NewLockdownd(d *Device) *Lockdownd {
+	return &Lockdownd{
+		Domains: map[string]map[string]interface{}{
+			"": {
+				"UniqueDeviceID":   d.SerialNumber,
+				"DeviceName":       "iPhone",
+				"ProductName":      "iPhone OS",
+				"ProductType":      "iPhone10,3",
+				"ProductVersion":   "14.0.1",
+				"BuildVersion":     "18A393",
+				"CPUArchitecture":  "arm64",
+				"DeviceClass":      "iPhone",
+				"HardwareModel":    "D22AP",
+				"UniqueChipID":     uint64(0x1a2b3c4d5e6f),
+				"ActivationState":  "Activated",
+				"WiFiAddress":      "f0:18:98:00:00:01",
+				"BluetoothAddress": "f0:18:98:00:00:02",
+				"NonVolatileRAM": map[string]interface{}{
+					"auto-boot":       []byte("true"),
+					"backlight-level": []byte("1527"),
+					"boot-args":       "",
+				},
+			},
+			"com.apple.mobile.battery": {
+				"BatteryCurrentCapacity": uint64(87),
+				"BatteryIsCharging":      true,
+			},
+			"com.apple.disk_usage": {
+				"TotalDiskCapacity":   uint64(64000000000),
+				"AmountDataAvailable": uint64(32000000000),
+			},
+		},
+		HostIDs:  []string{d.PairRecord["HostID"].(string)},
+		services: make(map[string]uint16),
+	}
+}
+
+// Sessions returns how many sessions have been started.
+func (l *Lockdownd) Sessions() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.sessions
+}
+
+func (l *Lockdownd) addService(name string, port uint16) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.services[name] = port
+}
+
+func (l *Lockdownd) Serve(conn net.Conn) {
+	PlistHandlerFunc(l.handle).Serve(conn)
+}
+
+func (l *Lockdownd) handle(req map[string]interface{}) []interface{} {
+	request, _ := req["Request"].(string)
+	resp := map[string]interface{}{"Request": request}
+
+	switch request {
+	case "QueryType":
+		resp["Type"] = "com.apple.mobile.lockdown"
+	case "GetValue":
+		domain, _ := req["Domain"].(string)
+		key, _ := req["Key"].(string)
+		if domain != "" {
+			resp["Domain"] = domain
+		}
+		if key != "" {
+			resp["Key"] = key
+		}
+
+		value, ok := l.value(domain, key)
+		if !ok {
+			resp["Error"] = "MissingValue"
+			break
+		}
+		resp["Value"] = value
+	case "SetValue":
+		domain, _ := req["Domain"].(string)
+		key, _ := req["Key"].(string)
+		value, ok := req["Value"]
+		if key == "" || !ok {
+			resp["Error"] = "MissingKey"
+			break
+		}
+		l.setValue(domain, key, value)
+	case "RemoveValue":
+		domain, _ := req["Domain"].(string)
+		key, _ := req["Key"].(string)
+		if !l.removeValue(domain, key) {
+			resp["Error"] = "MissingValue"
+		}
+	case "StartSession":
+		hostID, _ := req["HostID"].(string)
+		if !l.trusts(hostID) {
+			resp["Error"] = "InvalidHostID"
+			break
+		}
+		l.mu.Lock()
+		l.sessions++
+		l.mu.Unlock()
+		resp["SessionID"] = "8E4E1B7C-2A6D-4E0B-9C1F-3D5A7B9C1E2F"
+		resp["EnableSessionSSL"] = false
+	case "StopSession":
+	case "StartService":
+		name, _ := req["Service"].(string)
+		resp["Service"] = name
+
+		l.mu.Lock()
+		port, ok := l.services[name]
+		l.mu.Unlock()
+		if !ok {
+			resp["Error"] = "InvalidService"
+			break
+		}
+		resp["Port"] = port
+		resp["EnableServiceSSL"] = false
+	case "Pair":
+		if e := l.nextPairError(); e != "" {
+			resp["Error"] = e
+			break
+		}
+
+		record, _ := req["PairRecord"].(map[string]interface{})
+		hostID, err := l.checkPairRecord(record)
+		if err != nil {
+			resp["Error"] = "InvalidPairRecord"
+			break
+		}
+
+		l.mu.Lock()
+		l.HostIDs = append(l.HostIDs, hostID)
+		l.mu.Unlock()
+		resp["EscrowBag"] = []byte("escrow-bag")
+	case "ValidatePair":
+		record, _ := req["PairRecord"].(map[string]interface{})
+		hostID, _ := record["HostID"].(string)
+		if !l.trusts(hostID) {
+			resp["Error"] = "InvalidHostID"
+		}
+	case "Unpair":
+		record, _ := req["PairRecord"].(map[string]interface{})
+		hostID, _ := record["HostID"].(string)
+		if !l.untrust(hostID) {
+			resp["Error"] = "InvalidHostID"
+		}
+	default:
+		resp["Error"] = "InvalidRequest"
+	}
+
+	return []interface{}{resp}
+}
+
+func (l *Lockdownd) trusts(hostID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range l.HostIDs {
+		if id == hostID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *Lockdownd) untrust(hostID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, id := range l.HostIDs {
+		if id == hostID {
+			l.HostIDs = append(l.HostIDs[:i], l.HostIDs[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *Lockdownd) nextPairError() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.PairErrors) == 0 {
+		return ""
+	}
+	e := l.PairErrors[0]
+	l.PairErrors = l.PairErrors[1:]
+
+	return e
+}
+
+// deviceKey returns the device key pair, generating it on first use so that
+// tests which never pair don't pay for it.
+func (l *Lockdownd) deviceKey() (*rsa.PrivateKey, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.key == nil {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		l.key = key
+	}
+
+	return l.key, nil
+}
+
+// checkPairRecord verifies that the host and device certificates were issued
+// by the root certificate and that the device certificate carries the device
+// public key, which is what a real device insists on.
+func (l *Lockdownd) checkPairRecord(record map[string]interface{}) (string, error) {
+	hostID, _ := record["HostID"].(string)
+	if hostID == "" {
+		return "", errors.New("missing HostID")
+	}
+
+	root, err := parseCertificate(record["RootCertificate"])
+	if err != nil {
+		return "", err
+	}
+	host, err := parseCertificate(record["HostCertificate"])
+	if err != nil {
+		return "", err
+	}
+	device, err := parseCertificate(record["DeviceCertificate"])
+	if err != nil {
+		return "", err
+	}
+
+	if err := host.CheckSignatureFrom(root); err != nil {
+		return "", err
+	}
+	if err := device.CheckSignatureFrom(root); err != nil {
+		return "", err
+	}
+
+	key, err := l.deviceKey()
+	if err != nil {
+		return "", err
+	}
+	if pub, ok := device.PublicKey.(*rsa.PublicKey); !ok || !pub.Equal(&key.PublicKey) {
+		return "", errors.New("device certificate does not match the device key")
+	}
+
+	return hostID, nil
+}
+
+func parseCertificate(v interface{}) (*x509.Certificate, error) {
+	data, _ := v.([]byte)
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("missing certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func (l *Lockdownd) value(domain, key string) (interface{}, bool) {
+	if domain == "" && key == "DevicePublicKey" {
+		k, err := l.deviceKey()
+		if err != nil {
+			return nil, false
+		}
+		return pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PUBLIC KEY",
+			Bytes: x509.MarshalPKCS1PublicKey(&k.PublicKey),
+		}), true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	values, ok := l.Domains[domain]
+	if !ok {
+		return nil, false
+	}
+	if key == "" {
+		return values, true
+	}
+
+	value, ok := values[key]
+	return value, ok
+}
+
+func (l *Lockdownd) setValue(domain, key string, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	values, ok := l.Domains[domain]
+	if !ok {
+		values = make(map[string]interface{})
+		l.Domains[domain] = values
+	}
+	values[key] = value
+}
+
+func (l *Lockdownd) removeValue(domain, key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	values, ok := l.Domains[domain]
+	if !ok {
+		return false
+	}
+	if _, ok := values[key]; !ok {
+		return false
+	}
+	delete(values, key)
+
+	return true
+}
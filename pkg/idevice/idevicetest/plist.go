@@ -0,0 +1,67 @@
+package idevicetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+
+	"howett.net/plist"
+)
+
+// PlistHandlerFunc serves a lockdown style service where every message is a
+// big endian length prefixed plist. It is called once per request and the
+// returned messages are written back in order, in the request's format.
+type PlistHandlerFunc func(req map[string]interface{}) []interface{}
+
+func (h PlistHandlerFunc) Serve(conn net.Conn) {
+	for {
+		req, format, err := ReadPlist(conn)
+		if err != nil {
+			return
+		}
+
+		for _, resp := range h(req) {
+			if err := WritePlist(conn, resp, format); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ReadPlist reads one length prefixed plist message and reports which plist
+// format it was encoded in.
+func ReadPlist(r io.Reader) (map[string]interface{}, int, error) {
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, 0, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+
+	var msg map[string]interface{}
+	format, err := plist.Unmarshal(payload, &msg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return msg, format, nil
+}
+
+// WritePlist writes msg as one length prefixed plist message.
+func WritePlist(w io.Writer, msg interface{}, format int) error {
+	bs, err := plist.Marshal(msg, format)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(bs)))
+	buf.Write(bs)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
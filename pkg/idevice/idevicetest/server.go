@@ -0,0 +1,543 @@
+// Package idevicetest provides an in-process usbmuxd and fake device services
+// so code built on pkg/idevice can be tested without a phone attached.
+package idevicetest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"howett.net/plist"
+)
+
+const (
+	// LockdownPort is the port lockdownd listens on inside the device.
+	LockdownPort = 62078
+
+	firstServicePort = 49152
+
+	// SystemBUID is the host identity of the fake usbmuxd, shared by the
+	// pair records of devices made with NewDevice.
+	SystemBUID = "5B4B3B1C-8F5E-4C3B-9D0E-6A2B8B7E2F11"
+)
+
+// usbmuxd result codes.
+const (
+	ResultOK          = 0
+	ResultBadCommand  = 1
+	ResultBadDevice   = 2
+	ResultConnRefused = 3
+	ResultBadVersion  = 6
+)
+
+// Service is a fake device side service. Serve owns conn and returns when the
+// client goes away.
+type Service interface {
+	Serve(conn net.Conn)
+}
+
+// ServiceFunc adapts a plain function to a Service.
+type ServiceFunc func(conn net.Conn)
+
+func (f ServiceFunc) Serve(conn net.Conn) {
+	f(conn)
+}
+
+// Device is a fake iOS device attached to a Server.
+type Device struct {
+	DeviceID       int
+	SerialNumber   string
+	ConnectionType string
+	ProductID      int
+	LocationID     int
+	PairRecord     map[string]interface{}
+	Lockdown       *Lockdownd
+
+	mu       sync.Mutex
+	ports    map[uint16]Service
+	nextPort uint16
+}
+
+// NewNetworkDevice returns a device with the given udid that is connected
+// over Wi-Fi. Give it a DeviceID distinct from any USB twin.
+func NewNetworkDevice(udid string) *Device {
+	d := NewDevice(udid)
+	d.ConnectionType = "Network"
+
+	return d
+}
+
+// NewDevice returns a USB attached device with the given udid, a pair record
+// and a lockdownd that answers on LockdownPort.
+func NewDevice(udid string) *Device {
+	d := &Device{
+		DeviceID:       1,
+		SerialNumber:   udid,
+		ConnectionType: "USB",
+		ProductID:      0x12a8,
+		LocationID:     0x14100000,
+		PairRecord: map[string]interface{}{
+			"HostID":         "2CA9E9B4-6C53-4A4F-9F37-4B1B6F3A4C10",
+			"SystemBUID":     SystemBUID,
+			"WiFiMACAddress": "f0:18:98:00:00:01",
+		},
+		ports:    make(map[uint16]Service),
+		nextPort: firstServicePort,
+	}
+	d.Lockdown = NewLockdownd(d)
+	d.ports[LockdownPort] = d.Lockdown
+
+	return d
+}
+
+// NewUnpairedDevice returns a device that has never trusted any host. It has
+// no pair record until one is saved through usbmuxd.
+func NewUnpairedDevice(udid string) *Device {
+	d := NewDevice(udid)
+	d.PairRecord = nil
+	d.Lockdown.HostIDs = nil
+
+	return d
+}
+
+// Handle serves svc on a raw device port, like a daemon listening on the phone.
+func (d *Device) Handle(port uint16, svc Service) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.ports[port] = svc
+}
+
+// AddService registers svc under a lockdown service name so StartService can
+// hand out a port for it.
+func (d *Device) AddService(name string, svc Service) {
+	d.mu.Lock()
+	port := d.nextPort
+	d.nextPort++
+	d.ports[port] = svc
+	d.mu.Unlock()
+
+	d.Lockdown.addService(name, port)
+}
+
+func (d *Device) service(port uint16) (Service, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	svc, ok := d.ports[port]
+	return svc, ok
+}
+
+// Server is a fake usbmuxd.
+type Server struct {
+	// Addr is the address clients should dial.
+	Addr string
+
+	ln        net.Listener
+	dir       string
+	mu        sync.Mutex
+	devices   []*Device
+	conns     map[net.Conn]struct{}
+	listeners map[net.Conn]listener
+	wg        sync.WaitGroup
+}
+
+// NewServer starts a fake usbmuxd with the given devices attached.
+func NewServer(devices ...*Device) (*Server, error) {
+	dir, err := os.MkdirTemp("", "usbmuxd")
+	if err != nil {
+		return nil, err
+	}
+
+	addr := filepath.Join(dir, "usbmuxd")
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := newServer(ln, devices)
+	s.Addr = addr
+	s.dir = dir
+
+	return s, nil
+}
+
+// NewTCPServer starts a fake usbmuxd on a loopback TCP port, like a usbmuxd
+// exposed through socat. Addr is the "host:port" to dial.
+func NewTCPServer(devices ...*Device) (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := newServer(ln, devices)
+	s.Addr = ln.Addr().String()
+
+	return s, nil
+}
+
+func newServer(ln net.Listener, devices []*Device) *Server {
+	s := &Server{
+		ln:        ln,
+		devices:   devices,
+		conns:     make(map[net.Conn]struct{}),
+		listeners: make(map[net.Conn]listener),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close stops the server and drops every client connection.
+func (s *Server) Close() {
+	_ = s.ln.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	if s.dir != "" {
+		_ = os.RemoveAll(s.dir)
+	}
+}
+
+// Devices returns the currently attached devices.
+func (s *Server) Devices() []*Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Device(nil), s.devices...)
+}
+
+// Attach plugs d in and notifies every Listen subscriber.
+func (s *Server) Attach(d *Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.devices = append(s.devices, d)
+	s.broadcast(attachedEntry(d))
+}
+
+// Detach unplugs the device with the given id and notifies every Listen
+// subscriber.
+func (s *Server) Detach(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, d := range s.devices {
+		if d.DeviceID == id {
+			s.devices = append(s.devices[:i], s.devices[i+1:]...)
+			s.broadcast(map[string]interface{}{"MessageType": "Detached", "DeviceID": id})
+			return
+		}
+	}
+}
+
+// Paired tells Listen subscribers that the device with the given id has
+// been trusted.
+func (s *Server) Paired(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.broadcast(map[string]interface{}{"MessageType": "Paired", "DeviceID": id})
+}
+
+// broadcast must be called with s.mu held.
+func (s *Server) broadcast(msg interface{}) {
+	for conn, l := range s.listeners {
+		_ = writeMux(conn, l.tag, msg, l.format)
+	}
+}
+
+func (s *Server) listen(conn net.Conn, tag uint32, format int) {
+	s.mu.Lock()
+	err := writeMux(conn, tag, result(ResultOK), format)
+	for _, d := range s.devices {
+		if err == nil {
+			err = writeMux(conn, tag, attachedEntry(d), format)
+		}
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.listeners[conn] = listener{tag: tag, format: format}
+	s.mu.Unlock()
+
+	// A listening client sends nothing more; wait for it to hang up.
+	_, _ = io.Copy(ioutil.Discard, conn)
+
+	s.mu.Lock()
+	delete(s.listeners, conn)
+	s.mu.Unlock()
+}
+
+func (s *Server) device(id int) *Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.devices {
+		if d.DeviceID == id {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) deviceBySerial(serial string) *Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range s.devices {
+		if d.SerialNumber == serial {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			_ = conn.Close()
+		}()
+	}
+}
+
+type listener struct {
+	tag    uint32
+	format int
+}
+
+type muxHeader struct {
+	Length  uint32
+	Version uint32
+	Request uint32
+	Tag     uint32
+}
+
+func (s *Server) handle(conn net.Conn) {
+	for {
+		var hdr muxHeader
+		if err := binary.Read(conn, binary.LittleEndian, &hdr); err != nil {
+			return
+		}
+		if hdr.Length < 16 {
+			return
+		}
+
+		payload := make([]byte, hdr.Length-16)
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			return
+		}
+
+		// Answer in the plist format the client used.
+		var msg map[string]interface{}
+		format, err := plist.Unmarshal(payload, &msg)
+		if err != nil {
+			_ = writeMux(conn, hdr.Tag, result(ResultBadCommand), plist.XMLFormat)
+			continue
+		}
+
+		switch msg["MessageType"] {
+		case "ListDevices":
+			_ = writeMux(conn, hdr.Tag, s.listDevices(), format)
+		case "Listen":
+			s.listen(conn, hdr.Tag, format)
+			return
+		case "ReadPairRecord":
+			_ = writeMux(conn, hdr.Tag, s.readPairRecord(msg), format)
+		case "SavePairRecord":
+			_ = writeMux(conn, hdr.Tag, s.savePairRecord(msg), format)
+		case "DeletePairRecord":
+			_ = writeMux(conn, hdr.Tag, s.deletePairRecord(msg), format)
+		case "ReadBUID":
+			_ = writeMux(conn, hdr.Tag, map[string]interface{}{"BUID": SystemBUID}, format)
+		case "Connect":
+			svc, number := s.connect(msg)
+			if err := writeMux(conn, hdr.Tag, result(number), format); err != nil {
+				return
+			}
+			if svc != nil {
+				svc.Serve(conn)
+				return
+			}
+		default:
+			_ = writeMux(conn, hdr.Tag, result(ResultBadCommand), format)
+		}
+	}
+}
+
+func (s *Server) listDevices() map[string]interface{} {
+	list := make([]interface{}, 0)
+	for _, d := range s.Devices() {
+		list = append(list, attachedEntry(d))
+	}
+
+	return map[string]interface{}{"DeviceList": list}
+}
+
+func attachedEntry(d *Device) map[string]interface{} {
+	props := map[string]interface{}{
+		"ConnectionType": d.ConnectionType,
+		"DeviceID":       d.DeviceID,
+		"SerialNumber":   d.SerialNumber,
+		"UDID":           d.SerialNumber,
+	}
+	if d.ConnectionType == "Network" {
+		// sockaddr_in for 192.168.1.10, as sent by usbmuxd.
+		props["InterfaceIndex"] = 12
+		props["NetworkAddress"] = []byte{0x10, 0x02, 0, 0, 192, 168, 1, 10, 0, 0, 0, 0, 0, 0, 0, 0}
+	} else {
+		props["ConnectionSpeed"] = 480000000
+		props["LocationID"] = d.LocationID
+		props["ProductID"] = d.ProductID
+		props["USBSerialNumber"] = strings.ReplaceAll(d.SerialNumber, "-", "")
+	}
+
+	return map[string]interface{}{
+		"DeviceID":    d.DeviceID,
+		"MessageType": "Attached",
+		"Properties":  props,
+	}
+}
+
+func (s *Server) readPairRecord(msg map[string]interface{}) map[string]interface{} {
+	id, _ := msg["PairRecordID"].(string)
+	d := s.deviceBySerial(id)
+	if d == nil {
+		return result(ResultBadDevice)
+	}
+
+	d.mu.Lock()
+	record := d.PairRecord
+	d.mu.Unlock()
+	if record == nil {
+		return result(ResultBadDevice)
+	}
+
+	bs, err := plist.Marshal(record, plist.XMLFormat)
+	if err != nil {
+		return result(ResultBadCommand)
+	}
+
+	return map[string]interface{}{"PairRecordData": bs}
+}
+
+func (s *Server) savePairRecord(msg map[string]interface{}) map[string]interface{} {
+	id, _ := msg["PairRecordID"].(string)
+	data, _ := msg["PairRecordData"].([]byte)
+
+	d := s.deviceBySerial(id)
+	if d == nil {
+		return result(ResultBadDevice)
+	}
+
+	var record map[string]interface{}
+	if _, err := plist.Unmarshal(data, &record); err != nil {
+		return result(ResultBadCommand)
+	}
+
+	d.mu.Lock()
+	d.PairRecord = record
+	d.mu.Unlock()
+
+	s.Paired(d.DeviceID)
+
+	return result(ResultOK)
+}
+
+func (s *Server) deletePairRecord(msg map[string]interface{}) map[string]interface{} {
+	id, _ := msg["PairRecordID"].(string)
+	d := s.deviceBySerial(id)
+	if d == nil {
+		return result(ResultBadDevice)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.PairRecord == nil {
+		return result(ResultBadDevice)
+	}
+	d.PairRecord = nil
+
+	return result(ResultOK)
+}
+
+func (s *Server) connect(msg map[string]interface{}) (Service, int) {
+	id, _ := msg["DeviceID"].(uint64)
+	port, _ := msg["PortNumber"].(uint64)
+
+	d := s.device(int(id))
+	if d == nil {
+		return nil, ResultBadDevice
+	}
+
+	// PortNumber travels in network byte order.
+	svc, ok := d.service(swap16(uint16(port)))
+	if !ok {
+		return nil, ResultConnRefused
+	}
+
+	return svc, ResultOK
+}
+
+func result(number int) map[string]interface{} {
+	return map[string]interface{}{"MessageType": "Result", "Number": number}
+}
+
+func writeMux(w io.Writer, tag uint32, msg interface{}, format int) error {
+	bs, err := plist.Marshal(msg, format)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.LittleEndian, muxHeader{
+		Length:  16 + uint32(len(bs)),
+		Version: 1,
+		Request: 8,
+		Tag:     tag,
+	})
+	buf.Write(bs)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func swap16(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,28 @@
+package idevicetest
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+)
+
+// SyslogRelay is a fake com.apple.syslog_relay that replays a fixed set of
+// lines and then stays quiet until the client disconnects.
+type SyslogRelay struct {
+	Lines []string
+}
+
+// NewSyslogRelay returns a relay that sends lines in order.
+func NewSyslogRelay(lines ...string) *SyslogRelay {
+	return &SyslogRelay{Lines: lines}
+}
+
+func (s *SyslogRelay) Serve(conn net.Conn) {
+	for _, line := range s.Lines {
+		if _, err := io.WriteString(conn, line+"\n\x00"); err != nil {
+			return
+		}
+	}
+
+	_, _ = io.Copy(ioutil.Discard, conn)
+}
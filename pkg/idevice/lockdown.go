@@ -1,7 +1,10 @@
 package idevice
 
 import (
-	"golang.org/x/xerrors"
+	"context"
+
+	"github.com/gofmt/iOSBox/pkg/trace"
+
 	"howett.net/plist"
 )
 
@@ -9,8 +12,8 @@ type valutRequest struct {
 	Label   string
 	Key     string `plist:"Key,omitempty"`
 	Request string
-	Domain  string `plist:"Domain,omitempty"`
-	Value   string `plist:"Value,omitempty"`
+	Domain  string      `plist:"Domain,omitempty"`
+	Value   interface{} `plist:"Value,omitempty"`
 }
 
 type ValueResponse struct {
@@ -55,12 +58,35 @@ func (l *LockdownConn) Send(msg interface{}) error {
 	if err != nil {
 		return err
 	}
+	if trace.Enabled() {
+		// Encode prefixes the plist with its length.
+		trace.Printf("lockdown", trace.Send, "%s", trace.FormatPlist(bs[4:]))
+	}
 
 	return l.Conn.Write(bs)
 }
 
 func (l *LockdownConn) Recv() ([]byte, error) {
-	return l.Conn.Decode(l.Conn.Reader())
+	bs, err := l.Conn.Decode(l.Conn.Reader())
+	if err != nil {
+		return nil, err
+	}
+	if trace.Enabled() {
+		trace.Printf("lockdown", trace.Recv, "%s", trace.FormatPlist(bs))
+	}
+
+	return bs, nil
+}
+
+// request sends msg and reads the reply. ctx bounds the whole exchange.
+func (l *LockdownConn) request(ctx context.Context, msg interface{}) (_ []byte, err error) {
+	defer withContext(ctx, l.Conn)(&err)
+
+	if err := l.Send(msg); err != nil {
+		return nil, err
+	}
+
+	return l.Recv()
 }
 
 type startSessionRequest struct {
@@ -75,20 +101,22 @@ type StartSessionResponse struct {
 	EnableSessionSSL bool
 	Request          string
 	SessionID        string
+	Error            string
 }
 
 func (l *LockdownConn) StartSession(cert *Certificate) (*StartSessionResponse, error) {
-	if err := l.Send(startSessionRequest{
+	return l.StartSessionContext(context.Background(), cert)
+}
+
+// StartSessionContext is StartSession with a context.
+func (l *LockdownConn) StartSessionContext(ctx context.Context, cert *Certificate) (*StartSessionResponse, error) {
+	body, err := l.request(ctx, startSessionRequest{
 		Label:           Label,
 		ProtocolVersion: "2",
 		Request:         "StartSession",
 		HostID:          cert.HostID,
 		SystemBUID:      cert.SystemBUID,
-	}); err != nil {
-		return nil, err
-	}
-
-	body, err := l.Recv()
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -97,10 +125,13 @@ func (l *LockdownConn) StartSession(cert *Certificate) (*StartSessionResponse, e
 	if _, err := plist.Unmarshal(body, &resp); err != nil {
 		return nil, err
 	}
+	if resp.Error != "" {
+		return nil, &LockdownError{Request: "StartSession", Err: resp.Error}
+	}
 
 	l.sessionId = resp.SessionID
 	if resp.EnableSessionSSL {
-		if err := l.Conn.EnableSessionSSL(cert); err != nil {
+		if err := l.enableSessionSSL(ctx, cert); err != nil {
 			return nil, err
 		}
 	}
@@ -108,28 +139,233 @@ func (l *LockdownConn) StartSession(cert *Certificate) (*StartSessionResponse, e
 	return &resp, nil
 }
 
+func (l *LockdownConn) enableSessionSSL(ctx context.Context, cert *Certificate) (err error) {
+	defer withContext(ctx, l.Conn)(&err)
+
+	return l.Conn.EnableSessionSSL(cert)
+}
+
 func (l *LockdownConn) GetValues() (map[string]interface{}, error) {
+	return l.GetValuesContext(context.Background())
+}
+
+// GetValuesContext is GetValues with a context.
+func (l *LockdownConn) GetValuesContext(ctx context.Context) (map[string]interface{}, error) {
 	req := valutRequest{
 		Label:   Label,
 		Key:     "",
 		Request: "GetValue",
 	}
 
-	if err := l.Send(req); err != nil {
+	bs, err := l.request(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp map[string]interface{}
+	if _, err := plist.Unmarshal(bs, &resp); err != nil {
 		return nil, err
 	}
 
-	bs, err := l.Recv()
+	return resp, nil
+}
+
+// GetValue returns the value of key in domain. An empty domain means the
+// global domain and an empty key returns the whole domain as a dictionary.
+// Values keep their plist types: nested dictionaries are
+// map[string]interface{}, arrays []interface{} and data blobs []byte.
+func (l *LockdownConn) GetValue(domain, key string) (interface{}, error) {
+	return l.GetValueContext(context.Background(), domain, key)
+}
+
+// GetValueContext is GetValue with a context.
+func (l *LockdownConn) GetValueContext(ctx context.Context, domain, key string) (interface{}, error) {
+	resp, err := l.valueRequest(ctx, valutRequest{
+		Label:   Label,
+		Key:     key,
+		Request: "GetValue",
+		Domain:  domain,
+	})
 	if err != nil {
 		return nil, err
 	}
+	if resp.Value == nil {
+		return nil, &LockdownError{Request: "GetValue", Name: valueName(domain, key), Err: "MissingValue"}
+	}
 
-	var resp map[string]interface{}
+	return resp.Value, nil
+}
+
+// SetValue sets key in domain to value.
+func (l *LockdownConn) SetValue(domain, key string, value interface{}) error {
+	return l.SetValueContext(context.Background(), domain, key, value)
+}
+
+// SetValueContext is SetValue with a context.
+func (l *LockdownConn) SetValueContext(ctx context.Context, domain, key string, value interface{}) error {
+	_, err := l.valueRequest(ctx, valutRequest{
+		Label:   Label,
+		Key:     key,
+		Request: "SetValue",
+		Domain:  domain,
+		Value:   value,
+	})
+
+	return err
+}
+
+// RemoveValue deletes key from domain.
+func (l *LockdownConn) RemoveValue(domain, key string) error {
+	return l.RemoveValueContext(context.Background(), domain, key)
+}
+
+// RemoveValueContext is RemoveValue with a context.
+func (l *LockdownConn) RemoveValueContext(ctx context.Context, domain, key string) error {
+	_, err := l.valueRequest(ctx, valutRequest{
+		Label:   Label,
+		Key:     key,
+		Request: "RemoveValue",
+		Domain:  domain,
+	})
+
+	return err
+}
+
+func (l *LockdownConn) valueRequest(ctx context.Context, req valutRequest) (*ValueResponse, error) {
+	bs, err := l.request(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp ValueResponse
 	if _, err := plist.Unmarshal(bs, &resp); err != nil {
 		return nil, err
 	}
 
-	return resp, nil
+	if resp.Error != "" {
+		return nil, &LockdownError{Request: req.Request, Name: valueName(req.Domain, req.Key), Err: resp.Error}
+	}
+
+	return &resp, nil
+}
+
+func valueName(domain, key string) string {
+	if domain == "" {
+		return key
+	}
+	if key == "" {
+		return domain
+	}
+
+	return domain + "/" + key
+}
+
+// pairRecord is the public half of a Certificate that is sent to the device.
+type pairRecord struct {
+	DeviceCertificate []byte
+	HostCertificate   []byte
+	RootCertificate   []byte
+	HostID            string
+	SystemBUID        string
+}
+
+func newPairRecord(cert *Certificate) pairRecord {
+	return pairRecord{
+		DeviceCertificate: cert.DeviceCertificate,
+		HostCertificate:   cert.HostCertificate,
+		RootCertificate:   cert.RootCertificate,
+		HostID:            cert.HostID,
+		SystemBUID:        cert.SystemBUID,
+	}
+}
+
+type pairingOptions struct {
+	ExtendedPairingErrors bool
+}
+
+type pairRequest struct {
+	Label           string
+	ProtocolVersion string
+	Request         string
+	PairRecord      pairRecord
+	PairingOptions  *pairingOptions `plist:"PairingOptions,omitempty"`
+}
+
+type pairResponse struct {
+	Request   string
+	Error     string
+	EscrowBag []byte
+}
+
+func (l *LockdownConn) pairRequest(ctx context.Context, request string, cert *Certificate) (*pairResponse, error) {
+	req := pairRequest{
+		Label:           Label,
+		ProtocolVersion: "2",
+		Request:         request,
+		PairRecord:      newPairRecord(cert),
+	}
+	if request == "Pair" {
+		req.PairingOptions = &pairingOptions{ExtendedPairingErrors: true}
+	}
+	body, err := l.request(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp pairResponse
+	if _, err := plist.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Error != "" {
+		return nil, &LockdownError{Request: request, Err: resp.Error}
+	}
+
+	return &resp, nil
+}
+
+// Pair asks the device to trust the host in cert, which is usually made by
+// NewPairRecord. On success the device's escrow bag is stored in cert.
+// While the trust dialog is on screen the device answers
+// ErrPasswordProtected or ErrPairingDialogResponsePending; call Pair again
+// to poll for the user's answer.
+func (l *LockdownConn) Pair(cert *Certificate) error {
+	return l.PairContext(context.Background(), cert)
+}
+
+// PairContext is Pair with a context.
+func (l *LockdownConn) PairContext(ctx context.Context, cert *Certificate) error {
+	resp, err := l.pairRequest(ctx, "Pair", cert)
+	if err != nil {
+		return err
+	}
+
+	cert.EscrowBag = resp.EscrowBag
+
+	return nil
+}
+
+// ValidatePair checks that the device still trusts the host in cert.
+func (l *LockdownConn) ValidatePair(cert *Certificate) error {
+	return l.ValidatePairContext(context.Background(), cert)
+}
+
+// ValidatePairContext is ValidatePair with a context.
+func (l *LockdownConn) ValidatePairContext(ctx context.Context, cert *Certificate) error {
+	_, err := l.pairRequest(ctx, "ValidatePair", cert)
+	return err
+}
+
+// Unpair makes the device forget the host in cert. The pair record kept by
+// usbmuxd is left alone.
+func (l *LockdownConn) Unpair(cert *Certificate) error {
+	return l.UnpairContext(context.Background(), cert)
+}
+
+// UnpairContext is Unpair with a context.
+func (l *LockdownConn) UnpairContext(ctx context.Context, cert *Certificate) error {
+	_, err := l.pairRequest(ctx, "Unpair", cert)
+	return err
 }
 
 type StartServiceResponse struct {
@@ -147,15 +383,16 @@ type startServiceRequest struct {
 }
 
 func (l *LockdownConn) StartService(name string) (*StartServiceResponse, error) {
-	if err := l.Send(startServiceRequest{
+	return l.StartServiceContext(context.Background(), name)
+}
+
+// StartServiceContext is StartService with a context.
+func (l *LockdownConn) StartServiceContext(ctx context.Context, name string) (*StartServiceResponse, error) {
+	body, err := l.request(ctx, startServiceRequest{
 		Label:   Label,
 		Request: "StartService",
 		Service: name,
-	}); err != nil {
-		return nil, err
-	}
-
-	body, err := l.Recv()
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -166,7 +403,7 @@ func (l *LockdownConn) StartService(name string) (*StartServiceResponse, error)
 	}
 
 	if resp.Error != "" {
-		return nil, xerrors.Errorf("could not start service: %s with reason: %s.Have you mounted the Developer Image?", name, resp.Error)
+		return nil, &LockdownError{Request: "StartService", Name: name, Err: resp.Error}
 	}
 
 	return &resp, nil
@@ -1,12 +1,35 @@
 package idevice
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestLockdownConn_GetValues(t *testing.T) {
-	device, err := GetDevice()
+	_, device := newTestDevice(t)
+
+	lockdown, err := ConnectLockdownWithSession(device)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer lockdown.Close()
+
+	resp, err := lockdown.GetValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, ok := resp["Value"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Value = %#v", resp["Value"])
+	}
+	if values["UniqueDeviceID"] != testUDID {
+		t.Fatalf("UniqueDeviceID = %v, want %s", values["UniqueDeviceID"], testUDID)
+	}
+}
+
+func TestLockdownConn_Value(t *testing.T) {
+	dev, device := newTestDevice(t)
 
 	lockdown, err := ConnectLockdownWithSession(device)
 	if err != nil {
@@ -14,10 +37,48 @@ func TestLockdownConn_GetValues(t *testing.T) {
 	}
 	defer lockdown.Close()
 
-	resp, err := lockdown.GetValues()
+	name, err := lockdown.GetValue("", "DeviceName")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if name != "iPhone" {
+		t.Fatalf("DeviceName = %v", name)
+	}
 
-	t.Log(resp)
+	nvram, err := lockdown.GetValue("", "NonVolatileRAM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := nvram.(map[string]interface{})["auto-boot"].([]byte); !ok || string(b) != "true" {
+		t.Fatalf("NonVolatileRAM = %#v", nvram)
+	}
+
+	battery, err := lockdown.GetValue("com.apple.mobile.battery", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if battery.(map[string]interface{})["BatteryIsCharging"] != true {
+		t.Fatalf("battery = %#v", battery)
+	}
+
+	if _, err := lockdown.GetValue("", "NoSuchKey"); !errors.Is(err, ErrMissingValue) {
+		t.Fatalf("err = %v, want ErrMissingValue", err)
+	}
+
+	if err := lockdown.SetValue("com.apple.mobile.wireless_lockdown", "EnableWifiDebugging", true); err != nil {
+		t.Fatal(err)
+	}
+	if dev.Lockdown.Domains["com.apple.mobile.wireless_lockdown"]["EnableWifiDebugging"] != true {
+		t.Fatal("SetValue did not reach the device")
+	}
+	if v, err := lockdown.GetValue("com.apple.mobile.wireless_lockdown", "EnableWifiDebugging"); err != nil || v != true {
+		t.Fatalf("GetValue after SetValue = %v, %v", v, err)
+	}
+
+	if err := lockdown.RemoveValue("com.apple.mobile.wireless_lockdown", "EnableWifiDebugging"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockdown.GetValue("com.apple.mobile.wireless_lockdown", "EnableWifiDebugging"); !errors.Is(err, ErrMissingValue) {
+		t.Fatalf("err = %v, want ErrMissingValue", err)
+	}
 }
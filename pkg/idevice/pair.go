@@ -0,0 +1,303 @@
+package idevice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// PairRetryInterval is how long PairDevice waits before asking again while
+// the device is locked or the trust dialog is still on screen.
+var PairRetryInterval = time.Second
+
+// PairDevice pairs the host with entry. It keeps retrying for up to timeout
+// while the device is passcode locked or waiting for the user to tap Trust,
+// calling notify with ErrPasswordProtected or ErrPairingDialogResponsePending
+// on each attempt, then saves the new pair record to usbmuxd.
+func PairDevice(entry *DeviceEntry, timeout time.Duration, notify func(err error)) (*Certificate, error) {
+	return PairDeviceContext(context.Background(), entry, timeout, notify)
+}
+
+// PairDeviceContext is PairDevice with a context. Cancelling ctx stops waiting
+// for the user.
+func PairDeviceContext(ctx context.Context, entry *DeviceEntry, timeout time.Duration, notify func(err error)) (*Certificate, error) {
+	conn, err := NewUSBConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockdown, err := conn.ConnectLockdownContext(ctx, entry.DeviceID)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer lockdown.Close()
+
+	value, err := lockdown.GetValueContext(ctx, "", "DevicePublicKey")
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := value.([]byte)
+	if !ok {
+		return nil, xerrors.New("device did not return a public key")
+	}
+
+	cert, err := NewPairRecord(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	// Share the host identity usbmuxd already uses with other devices.
+	if buid, err := ReadBUIDContext(ctx); err == nil {
+		cert.SystemBUID = buid
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := lockdown.PairContext(ctx, cert)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrPasswordProtected) && !errors.Is(err, ErrPairingDialogResponsePending) {
+			return nil, err
+		}
+		if notify != nil {
+			notify(err)
+		}
+		if time.Now().After(deadline) {
+			return nil, xerrors.Errorf("pairing timed out: %w", err)
+		}
+		select {
+		case <-time.After(PairRetryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if mac, err := lockdown.GetValueContext(ctx, "", "WiFiAddress"); err == nil {
+		cert.WiFiMACAddress, _ = mac.(string)
+	}
+
+	if err := SavePairRecordContext(ctx, entry, cert); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// ValidatePair checks that entry still trusts the host in its usbmuxd pair
+// record.
+func ValidatePair(entry *DeviceEntry) error {
+	return ValidatePairContext(context.Background(), entry)
+}
+
+// ValidatePairContext is ValidatePair with a context.
+func ValidatePairContext(ctx context.Context, entry *DeviceEntry) error {
+	return withPairRecord(ctx, entry, func(lockdown *LockdownConn, cert *Certificate) error {
+		return lockdown.ValidatePairContext(ctx, cert)
+	})
+}
+
+// Unpair makes entry forget the host in its usbmuxd pair record.
+func Unpair(entry *DeviceEntry) error {
+	return UnpairContext(context.Background(), entry)
+}
+
+// UnpairContext is Unpair with a context.
+func UnpairContext(ctx context.Context, entry *DeviceEntry) error {
+	return withPairRecord(ctx, entry, func(lockdown *LockdownConn, cert *Certificate) error {
+		return lockdown.UnpairContext(ctx, cert)
+	})
+}
+
+func withPairRecord(ctx context.Context, entry *DeviceEntry, fn func(lockdown *LockdownConn, cert *Certificate) error) error {
+	conn, err := NewUSBConnContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	cert, err := conn.GetCertificateContext(ctx, entry.Properties.SerialNumber)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	lockdown, err := conn.ConnectLockdownContext(ctx, entry.DeviceID)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer lockdown.Close()
+
+	return fn(lockdown, cert)
+}
+
+// NewPairRecord creates a fresh host identity for pairing with the device
+// whose PEM encoded public key is devicePublicKey. It generates a root CA,
+// a host certificate and a device certificate, all issued by the root.
+func NewPairRecord(devicePublicKey []byte) (*Certificate, error) {
+	deviceKey, err := parsePublicKey(devicePublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rootKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.AddDate(10, 0, 0)
+
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(0),
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, xerrors.Errorf("could not create root certificate: %w", err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := func(pub *rsa.PublicKey) ([]byte, error) {
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(0),
+			NotBefore:             notBefore,
+			NotAfter:              notAfter,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			SubjectKeyId:          keyID(pub),
+		}
+		return x509.CreateCertificate(rand.Reader, template, root, pub, rootKey)
+	}
+
+	hostDER, err := leaf(&hostKey.PublicKey)
+	if err != nil {
+		return nil, xerrors.Errorf("could not create host certificate: %w", err)
+	}
+	deviceDER, err := leaf(deviceKey)
+	if err != nil {
+		return nil, xerrors.Errorf("could not create device certificate: %w", err)
+	}
+
+	return &Certificate{
+		HostID:            newUUID(),
+		SystemBUID:        newUUID(),
+		HostCertificate:   encodePEM("CERTIFICATE", hostDER),
+		HostPrivateKey:    encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(hostKey)),
+		DeviceCertificate: encodePEM("CERTIFICATE", deviceDER),
+		RootCertificate:   encodePEM("CERTIFICATE", rootDER),
+		RootPrivateKey:    encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rootKey)),
+	}, nil
+}
+
+// parsePublicKey accepts the PKCS#1 "RSA PUBLIC KEY" block sent by devices
+// as well as a PKIX "PUBLIC KEY" block.
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, xerrors.New("invalid device public key")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid device public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, xerrors.New("device public key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+func keyID(pub *rsa.PublicKey) []byte {
+	sum := sha1.Sum(x509.MarshalPKCS1PublicKey(pub))
+	return sum[:]
+}
+
+func encodePEM(typ string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+// newUUID returns a random version 4 UUID in the upper case form used for
+// HostID and SystemBUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]))
+}
+
+// UnmarshalPlist decodes a pair record and remembers every key, including
+// the ones Certificate has no field for.
+func (c *Certificate) UnmarshalPlist(unmarshal func(interface{}) error) error {
+	type certificate Certificate
+
+	var known certificate
+	if err := unmarshal(&known); err != nil {
+		return err
+	}
+
+	var raw map[string]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	*c = Certificate(known)
+	c.raw = raw
+
+	return nil
+}
+
+// MarshalPlist encodes the record with the keys it was decoded from. Empty
+// fields are left out unless the original record had them.
+func (c Certificate) MarshalPlist() (interface{}, error) {
+	m := make(map[string]interface{}, len(c.raw)+9)
+	for k, v := range c.raw {
+		m[k] = v
+	}
+
+	set := func(key string, value interface{}, empty bool) {
+		if _, ok := c.raw[key]; ok || !empty {
+			m[key] = value
+		}
+	}
+	set("HostID", c.HostID, c.HostID == "")
+	set("SystemBUID", c.SystemBUID, c.SystemBUID == "")
+	set("HostCertificate", c.HostCertificate, len(c.HostCertificate) == 0)
+	set("HostPrivateKey", c.HostPrivateKey, len(c.HostPrivateKey) == 0)
+	set("DeviceCertificate", c.DeviceCertificate, len(c.DeviceCertificate) == 0)
+	set("EscrowBag", c.EscrowBag, len(c.EscrowBag) == 0)
+	set("WiFiMACAddress", c.WiFiMACAddress, c.WiFiMACAddress == "")
+	set("RootCertificate", c.RootCertificate, len(c.RootCertificate) == 0)
+	set("RootPrivateKey", c.RootPrivateKey, len(c.RootPrivateKey) == 0)
+
+	return m, nil
+}
@@ -0,0 +1,214 @@
+package idevice
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+
+	"howett.net/plist"
+)
+
+func TestNewPairRecord(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	cert, err := NewPairRecord(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.HostID == "" || cert.SystemBUID == "" || cert.HostID == cert.SystemBUID {
+		t.Fatalf("HostID = %q, SystemBUID = %q", cert.HostID, cert.SystemBUID)
+	}
+
+	// The host identity must be usable for the lockdown TLS handshake.
+	if _, err := tls.X509KeyPair(cert.HostCertificate, cert.HostPrivateKey); err != nil {
+		t.Fatalf("host key pair: %v", err)
+	}
+	if _, err := tls.X509KeyPair(cert.RootCertificate, cert.RootPrivateKey); err != nil {
+		t.Fatalf("root key pair: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(cert.RootCertificate)
+	block, _ := pem.Decode(cert.DeviceCertificate)
+	device, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := device.Verify(x509.VerifyOptions{Roots: roots}); err != nil {
+		t.Fatalf("device certificate: %v", err)
+	}
+	if !device.PublicKey.(*rsa.PublicKey).Equal(&key.PublicKey) {
+		t.Fatal("device certificate does not carry the device key")
+	}
+
+	if _, err := NewPairRecord([]byte("garbage")); err == nil {
+		t.Fatal("expected an error for an invalid public key")
+	}
+}
+
+func TestPairDevice(t *testing.T) {
+	dev := idevicetest.NewUnpairedDevice(testUDID)
+	dev.Lockdown.PairErrors = []string{"PasswordProtected", "PairingDialogResponsePending"}
+	newTestServer(t, dev)
+
+	old := PairRetryInterval
+	PairRetryInterval = time.Millisecond
+	defer func() { PairRetryInterval = old }()
+
+	entry, err := GetDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ConnectLockdownWithSession(entry); err == nil {
+		t.Fatal("expected no pair record before pairing")
+	}
+
+	var prompts []error
+	cert, err := PairDevice(entry, time.Minute, func(err error) {
+		prompts = append(prompts, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 2 || !errors.Is(prompts[0], ErrPasswordProtected) || !errors.Is(prompts[1], ErrPairingDialogResponsePending) {
+		t.Fatalf("prompts = %v", prompts)
+	}
+	if string(cert.EscrowBag) != "escrow-bag" || cert.WiFiMACAddress != "f0:18:98:00:00:01" || cert.SystemBUID != idevicetest.SystemBUID {
+		t.Fatalf("cert = %+v", cert)
+	}
+
+	saved, err := GetCertificate(testUDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.HostID != cert.HostID || string(saved.HostPrivateKey) != string(cert.HostPrivateKey) {
+		t.Fatal("saved pair record differs from the generated one")
+	}
+
+	lockdown, err := ConnectLockdownWithSession(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lockdown.Close()
+
+	if err := lockdown.ValidatePair(cert); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockdown.Unpair(cert); err != nil {
+		t.Fatal(err)
+	}
+	if err := lockdown.ValidatePair(cert); err == nil {
+		t.Fatal("expected ValidatePair to fail after Unpair")
+	}
+}
+
+func TestPairDevice_Denied(t *testing.T) {
+	dev := idevicetest.NewUnpairedDevice(testUDID)
+	dev.Lockdown.PairErrors = []string{"UserDeniedPairing"}
+	newTestServer(t, dev)
+
+	entry, err := GetDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PairDevice(entry, time.Minute, nil); !errors.Is(err, ErrUserDeniedPairing) {
+		t.Fatalf("err = %v, want ErrUserDeniedPairing", err)
+	}
+}
+
+func TestCertificate_RoundTrip(t *testing.T) {
+	record := map[string]interface{}{
+		"HostID":            "2CA9E9B4-6C53-4A4F-9F37-4B1B6F3A4C10",
+		"SystemBUID":        idevicetest.SystemBUID,
+		"HostCertificate":   []byte("host"),
+		"HostPrivateKey":    []byte("key"),
+		"DeviceCertificate": []byte("device"),
+		"RootCertificate":   []byte("root"),
+		"RootPrivateKey":    []byte("root key"),
+		"EscrowBag":         []byte{},
+		"WiFiMACAddress":    "f0:18:98:00:00:01",
+		"UDID":              testUDID,
+	}
+	data, err := plist.Marshal(record, plist.XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cert Certificate
+	if _, err := plist.Unmarshal(data, &cert); err != nil {
+		t.Fatal(err)
+	}
+	if cert.HostID != record["HostID"] || string(cert.RootPrivateKey) != "root key" {
+		t.Fatalf("cert = %+v", cert)
+	}
+
+	out, err := plist.Marshal(&cert, plist.XMLFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if _, err := plist.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Fatalf("round trip = %#v, want %#v", got, record)
+	}
+}
+
+func TestPairRecordManagement(t *testing.T) {
+	dev, entry := newTestDevice(t)
+
+	buid, err := ReadBUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buid != idevicetest.SystemBUID {
+		t.Fatalf("BUID = %q", buid)
+	}
+
+	cert, err := GetCertificate(testUDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeletePairRecord(testUDID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetCertificate(testUDID); err == nil {
+		t.Fatal("expected no pair record after delete")
+	}
+	if err := DeletePairRecord(testUDID); err == nil {
+		t.Fatal("expected an error deleting a missing pair record")
+	}
+
+	if err := SavePairRecord(entry, cert); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dev.PairRecord, map[string]interface{}{
+		"HostID":         "2CA9E9B4-6C53-4A4F-9F37-4B1B6F3A4C10",
+		"SystemBUID":     idevicetest.SystemBUID,
+		"WiFiMACAddress": "f0:18:98:00:00:01",
+	}) {
+		t.Fatalf("restored record = %#v", dev.PairRecord)
+	}
+
+	lockdown, err := ConnectLockdownWithSession(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockdown.Close()
+}
@@ -12,6 +12,9 @@ import (
 	"howett.net/plist"
 )
 
+// PcapServiceName is the lockdown name of pcapd.
+const PcapServiceName = "com.apple.pcapd"
+
 const (
 	TcpdumpMagic     = 0xa1b2c3d4
 	PcapVersionMajor = 2
@@ -55,13 +58,20 @@ type IOSPacketHeader struct {
 	Unknown2       [8]byte
 }
 
+// StartPcapService writes the packets captured on entry to wr in pcap format
+// until ctx is done, which is not treated as an error. When procName is set
+// only packets of processes with that name prefix are kept.
 func StartPcapService(ctx context.Context, entry *DeviceEntry, procName string, wr io.Writer, dump func([]byte)) error {
-	service, err := ConnectToService(entry, "com.apple.pcapd")
+	service, err := ConnectToServiceContext(ctx, entry, PcapServiceName)
 	if err != nil {
 		return err
 	}
 	defer service.Close()
 
+	return capturePcap(ctx, service, procName, wr, dump)
+}
+
+func capturePcap(ctx context.Context, service IConn, procName string, wr io.Writer, dump func([]byte)) (err error) {
 	header := PcapGlobalHeader{
 		MagicNumber:  TcpdumpMagic,
 		VersionMajor: PcapVersionMajor,
@@ -76,16 +86,15 @@ func StartPcapService(ctx context.Context, entry *DeviceEntry, procName string,
 		return err
 	}
 
-	stoped := false
-	go func() {
-		<-ctx.Done()
-		stoped = true
-		fmt.Println("exit")
-	}()
+	// Cancelling ctx interrupts the blocked read below.
+	defer withContext(ctx, service)(&err)
 
 	for {
 		bs, err := service.Decode(service.Reader())
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
 			return err
 		}
 
@@ -135,11 +144,5 @@ func StartPcapService(ctx context.Context, entry *DeviceEntry, procName string,
 		if err != nil {
 			return err
 		}
-
-		if stoped {
-			break
-		}
 	}
-
-	return nil
 }
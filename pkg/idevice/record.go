@@ -0,0 +1,572 @@
+package idevice
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/trace"
+
+	"golang.org/x/xerrors"
+	"howett.net/plist"
+)
+
+// Record and Replay capture usbmuxd connections at the IConn layer, so a
+// session with a real device can be turned into a test that runs without
+// one:
+//
+//	stop := idevice.Record(f)
+//	... talk to the device ...
+//	err := stop()
+//
+// and later, in a test:
+//
+//	stop, err := idevice.Replay(f)
+//	defer stop()
+//
+// A fixture is a JSON line per event. Data is recorded above TLS, so it is
+// plaintext and replays without the pair record keys; the private keys and
+// escrow bag of pair records read from or saved to usbmuxd are replaced
+// before they are written.
+// Sessions that depend on randomness, such as SSH, do not replay.
+
+// recordEvent is one line of a fixture.
+type recordEvent struct {
+	Conn int    `json:"conn"`
+	Op   string `json:"op"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// Events of a fixture.
+const (
+	recordDial  = "dial"
+	recordWrite = "write"
+	recordRead  = "read"
+	recordSSL   = "ssl"
+	recordClose = "close"
+)
+
+// redactedKey replaces the private keys of a recorded pair record.
+var redactedKey = []byte("<redacted>")
+
+var (
+	recordMu  sync.Mutex
+	recorder  *sessionRecorder
+	replaying *sessionReplay
+)
+
+// Record writes every usbmuxd connection dialled from now on to w, until
+// stop is called. stop returns the first error writing to w.
+func Record(w io.Writer) (stop func() error) {
+	r := &sessionRecorder{w: bufio.NewWriter(w)}
+
+	recordMu.Lock()
+	recorder = r
+	recordMu.Unlock()
+
+	return func() error {
+		recordMu.Lock()
+		if recorder == r {
+			recorder = nil
+		}
+		recordMu.Unlock()
+
+		return r.stop()
+	}
+}
+
+// Replay serves the connections recorded in r to every usbmuxd connection
+// dialled from now on, in the order they were recorded, until stop is
+// called. Writes must match the recorded ones byte for byte.
+func Replay(r io.Reader) (stop func(), err error) {
+	s, err := readReplay(r)
+	if err != nil {
+		return nil, err
+	}
+
+	recordMu.Lock()
+	replaying = s
+	recordMu.Unlock()
+
+	return func() {
+		recordMu.Lock()
+		if replaying == s {
+			replaying = nil
+		}
+		recordMu.Unlock()
+	}, nil
+}
+
+// sessionConn returns the replayed connection to dial instead of usbmuxd,
+// or wraps conn when a recording is running.
+func sessionConn(dial func() (IConn, error)) (IConn, error) {
+	recordMu.Lock()
+	r, s := recorder, replaying
+	recordMu.Unlock()
+
+	if s != nil {
+		return s.next()
+	}
+
+	conn, err := dial()
+	if err != nil || r == nil {
+		return conn, err
+	}
+
+	return r.wrap(conn), nil
+}
+
+type sessionRecorder struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	conns int
+	err   error
+}
+
+func (r *sessionRecorder) wrap(conn IConn) IConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.conns++
+	c := &recordingConn{IConn: conn, r: r, id: r.conns}
+	r.writeLocked(recordEvent{Conn: c.id, Op: recordDial})
+
+	return c
+}
+
+func (r *sessionRecorder) writeLocked(e recordEvent) {
+	if r.err != nil {
+		return
+	}
+
+	bs, err := json.Marshal(e)
+	if err != nil {
+		r.err = err
+		return
+	}
+	bs = append(bs, '\n')
+	_, r.err = r.w.Write(bs)
+}
+
+func (r *sessionRecorder) stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return r.w.Flush()
+}
+
+// recordingConn records what goes through an IConn. Consecutive reads or
+// writes are merged into one event, which is written once the direction
+// changes.
+type recordingConn struct {
+	IConn
+	r  *sessionRecorder
+	id int
+
+	mu      sync.Mutex
+	op      string
+	pending []byte
+	closed  bool
+}
+
+func (c *recordingConn) add(op string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.op != op {
+		c.flushLocked()
+		c.op = op
+	}
+	c.pending = append(c.pending, data...)
+}
+
+func (c *recordingConn) mark(op string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.flushLocked()
+	c.event(recordEvent{Op: op})
+}
+
+func (c *recordingConn) flushLocked() {
+	if c.op != "" && len(c.pending) > 0 {
+		c.event(recordEvent{Op: c.op, Data: c.pending})
+	}
+	c.op, c.pending = "", nil
+}
+
+func (c *recordingConn) event(e recordEvent) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+
+	e.Conn = c.id
+	c.r.writeLocked(e)
+}
+
+// replace swaps the last bytes read or written, old, for data in the
+// recording. op is recordRead or recordWrite.
+func (c *recordingConn) replace(op string, old, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.op == op && bytes.HasSuffix(c.pending, old) {
+		c.pending = append(c.pending[:len(c.pending)-len(old):len(c.pending)-len(old)], data...)
+	}
+}
+
+func (c *recordingConn) Close() {
+	c.mu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mu.Unlock()
+
+	if !closed {
+		c.mark(recordClose)
+	}
+	c.IConn.Close()
+}
+
+// Reader and Writer ask the wrapped connection on every call, because it
+// switches to TLS in the middle of a session.
+func (c *recordingConn) Reader() io.Reader {
+	return recordingReader{c}
+}
+
+func (c *recordingConn) Writer() io.Writer {
+	return recordingWriter{c}
+}
+
+func (c *recordingConn) Write(data []byte) error {
+	_, err := c.Writer().Write(data)
+	return err
+}
+
+func (c *recordingConn) EnableSessionSSL(cert *Certificate) error {
+	c.mark(recordSSL)
+	return c.IConn.EnableSessionSSL(cert)
+}
+
+func (c *recordingConn) EnableSessionSSLHandshakeOnly(cert *Certificate) error {
+	c.mark(recordSSL)
+	return c.IConn.EnableSessionSSLHandshakeOnly(cert)
+}
+
+type recordingReader struct{ c *recordingConn }
+
+func (r recordingReader) Read(p []byte) (int, error) {
+	n, err := r.c.IConn.Reader().Read(p)
+	if n > 0 {
+		r.c.add(recordRead, p[:n])
+	}
+
+	return n, err
+}
+
+type recordingWriter struct{ c *recordingConn }
+
+func (w recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.c.IConn.Writer().Write(p)
+	if n > 0 {
+		w.c.add(recordWrite, p[:n])
+	}
+
+	return n, err
+}
+
+// redactPairRecordMessage returns msg, a usbmuxd message carrying a pair
+// record, with the values of the pair record keys in trace.RedactedKeys
+// replaced. ok is false when msg carries no pair record.
+func redactPairRecordMessage(msg *USBMessage) (_ *USBMessage, ok bool) {
+	var outer map[string]interface{}
+	format, err := plist.Unmarshal(msg.Payload, &outer)
+	if err != nil {
+		return nil, false
+	}
+	data, ok := outer["PairRecordData"].([]byte)
+	if !ok {
+		return nil, false
+	}
+
+	var record map[string]interface{}
+	recordFormat, err := plist.Unmarshal(data, &record)
+	if err != nil {
+		return nil, false
+	}
+	for key := range record {
+		if trace.RedactedKeys[key] {
+			record[key] = redactedKey
+		}
+	}
+	if outer["PairRecordData"], err = plist.Marshal(record, recordFormat); err != nil {
+		return nil, false
+	}
+	payload, err := plist.Marshal(outer, format)
+	if err != nil {
+		return nil, false
+	}
+
+	header := msg.Header
+	header.Length = 16 + uint32(len(payload))
+
+	return &USBMessage{Header: header, Payload: payload}, true
+}
+
+// redactPairRecord replaces the private keys in msg, a ReadPairRecord
+// reply just read from conn, when conn is being recorded.
+func redactPairRecord(conn IConn, msg *USBMessage) {
+	c, ok := conn.(*recordingConn)
+	if !ok {
+		return
+	}
+	if redacted, ok := redactPairRecordMessage(msg); ok {
+		c.replace(recordRead, frameUSBMessage(msg), frameUSBMessage(redacted))
+	}
+}
+
+// pairRecordWrite returns msg, a SavePairRecord request, as it is to be
+// written to conn. A replayed connection gets it redacted, as it was
+// recorded by redactPairRecordWrite.
+func pairRecordWrite(conn IConn, msg *USBMessage) *USBMessage {
+	if _, ok := conn.(*replayConn); ok {
+		if redacted, ok := redactPairRecordMessage(msg); ok {
+			return redacted
+		}
+	}
+
+	return msg
+}
+
+// redactPairRecordWrite replaces the private keys in msg, a SavePairRecord
+// request just written to conn, when conn is being recorded.
+func redactPairRecordWrite(conn IConn, msg *USBMessage) {
+	c, ok := conn.(*recordingConn)
+	if !ok {
+		return
+	}
+	if redacted, ok := redactPairRecordMessage(msg); ok {
+		c.replace(recordWrite, frameUSBMessage(msg), frameUSBMessage(redacted))
+	}
+}
+
+// frameUSBMessage returns msg as it goes over the wire.
+func frameUSBMessage(msg *USBMessage) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, msg.Header)
+	buf.Write(msg.Payload)
+
+	return buf.Bytes()
+}
+
+// sessionReplay holds the connections of a fixture that are still to be
+// dialled.
+type sessionReplay struct {
+	mu    sync.Mutex
+	conns []*replayConn
+}
+
+func readReplay(r io.Reader) (*sessionReplay, error) {
+	s := &sessionReplay{}
+	conns := make(map[int]*replayConn)
+
+	dec := json.NewDecoder(r)
+	for {
+		var e recordEvent
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, xerrors.Errorf("reading session: %w", err)
+		}
+
+		if e.Op == recordDial {
+			c := &replayConn{id: e.Conn, format: DefaultPlistFormat}
+			conns[e.Conn] = c
+			s.conns = append(s.conns, c)
+			continue
+		}
+
+		c, ok := conns[e.Conn]
+		if !ok {
+			return nil, xerrors.Errorf("reading session: %s on connection %d before it was dialled", e.Op, e.Conn)
+		}
+		switch e.Op {
+		case recordWrite:
+			c.writes = append(c.writes, e.Data...)
+		case recordRead:
+			c.reads = append(c.reads, e.Data...)
+		case recordSSL, recordClose:
+		default:
+			return nil, xerrors.Errorf("reading session: unknown event %q", e.Op)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *sessionReplay) next() (IConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conns) == 0 {
+		return nil, xerrors.New("replay: no more recorded connections")
+	}
+	c := s.conns[0]
+	s.conns = s.conns[1:]
+
+	return c, nil
+}
+
+// replayConn plays back one recorded connection. Reads are served from
+// what was read in the recording and writes are checked against what was
+// written; TLS and deadlines are no-ops.
+type replayConn struct {
+	id     int
+	format int
+
+	mu     sync.Mutex
+	reads  []byte
+	writes []byte
+	// written is how many bytes were written so far, for error messages.
+	written int
+}
+
+func (c *replayConn) Close() {}
+
+func (c *replayConn) Reader() io.Reader {
+	return replayReader{c}
+}
+
+func (c *replayConn) Writer() io.Writer {
+	return replayWriter{c}
+}
+
+func (c *replayConn) Write(data []byte) error {
+	_, err := c.Writer().Write(data)
+	return err
+}
+
+func (c *replayConn) Encode(msg interface{}) ([]byte, error) {
+	return (&Conn{format: c.format}).Encode(msg)
+}
+
+func (c *replayConn) Decode(r io.Reader) ([]byte, error) {
+	return (&Conn{}).Decode(r)
+}
+
+func (c *replayConn) PlistFormat() int {
+	return c.format
+}
+
+func (c *replayConn) SetPlistFormat(format int) {
+	c.format = format
+}
+
+func (c *replayConn) EnableSessionSSL(*Certificate) error {
+	return nil
+}
+
+func (c *replayConn) EnableSessionSSLHandshakeOnly(*Certificate) error {
+	return nil
+}
+
+func (c *replayConn) SetDeadline(time.Time) error {
+	return nil
+}
+
+type replayReader struct{ c *replayConn }
+
+func (r replayReader) Read(p []byte) (int, error) {
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+
+	if len(r.c.reads) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.c.reads)
+	r.c.reads = r.c.reads[n:]
+
+	return n, nil
+}
+
+type replayWriter struct{ c *replayConn }
+
+func (w replayWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+
+	n := len(p)
+	if n > len(w.c.writes) {
+		n = len(w.c.writes)
+	}
+	for i := 0; i < n; i++ {
+		if p[i] != w.c.writes[i] {
+			n = i
+			break
+		}
+	}
+	w.c.writes = w.c.writes[n:]
+	w.c.written += n
+
+	if n < len(p) {
+		return n, xerrors.Errorf("replay: connection %d diverged from the recording at byte %d", w.c.id, w.c.written)
+	}
+
+	return n, nil
+}
+
+// netConn returns conn as a net.Conn for callers that speak their own
+// protocol over it.
+func netConn(conn IConn) net.Conn {
+	if c, ok := conn.(*Conn); ok {
+		return c.conn
+	}
+
+	return iconnNetConn{conn}
+}
+
+// iconnNetConn adapts a recorded or replayed IConn to net.Conn.
+type iconnNetConn struct {
+	IConn
+}
+
+func (c iconnNetConn) Read(p []byte) (int, error) {
+	return c.Reader().Read(p)
+}
+
+func (c iconnNetConn) Write(p []byte) (int, error) {
+	return c.Writer().Write(p)
+}
+
+func (c iconnNetConn) Close() error {
+	c.IConn.Close()
+	return nil
+}
+
+func (c iconnNetConn) LocalAddr() net.Addr {
+	return sessionAddr{}
+}
+
+func (c iconnNetConn) RemoteAddr() net.Addr {
+	return sessionAddr{}
+}
+
+func (c iconnNetConn) SetReadDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+func (c iconnNetConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+type sessionAddr struct{}
+
+func (sessionAddr) Network() string { return "usbmuxd" }
+func (sessionAddr) String() string  { return "usbmuxd" }
@@ -0,0 +1,235 @@
+package idevice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
+
+	"howett.net/plist"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dev := idevicetest.NewDevice(testUDID)
+	afc := idevicetest.NewAFC()
+	afc.WriteFile("DCIM/hello.txt", []byte("hello"))
+	dev.AddService(FileManagerServiceName, afc)
+	srv := newTestServer(t, dev)
+
+	session := func() (string, []byte, error) {
+		device, err := NewClient().Device(DeviceFilter{UDID: testUDID})
+		if err != nil {
+			return "", nil, err
+		}
+		defer device.Close()
+
+		name, err := device.GetValue("", "DeviceName")
+		if err != nil {
+			return "", nil, err
+		}
+		fileService, err := device.AFC()
+		if err != nil {
+			return "", nil, err
+		}
+		defer fileService.Close()
+		handle, err := fileService.FileOpen("DCIM/hello.txt", AFC_FOPEN_RDONLY)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := fileService.FileRead(handle, 1024)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return name.(string), data, fileService.FileClose(handle)
+	}
+
+	var fixture bytes.Buffer
+	stop := Record(&fixture)
+	name, data, err := session()
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The device is gone; the session comes from the fixture.
+	srv.Close()
+
+	stopReplay, err := Replay(bytes.NewReader(fixture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayedName, replayedData, err := session()
+	stopReplay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayedName != name || !bytes.Equal(replayedData, data) {
+		t.Fatalf("replayed %q, %q, want %q, %q", replayedName, replayedData, name, data)
+	}
+
+	// A session that asks for something else fails instead of making up an
+	// answer.
+	stopReplay, err = Replay(bytes.NewReader(fixture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopReplay()
+	device, err := NewClient().Device(DeviceFilter{UDID: testUDID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer device.Close()
+	if _, err := device.GetValue("", "ProductVersion"); err == nil {
+		t.Fatal("expected an error for a request that was not recorded")
+	}
+}
+
+func TestRecord_RedactsPairRecord(t *testing.T) {
+	dev, _ := newTestDevice(t)
+	dev.PairRecord["HostPrivateKey"] = []byte("host private key")
+
+	var fixture bytes.Buffer
+	stop := Record(&fixture)
+	want, err := GetCertificate(testUDID)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopReplay, err := Replay(&fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopReplay()
+	got, err := GetCertificate(testUDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HostID != want.HostID || string(got.HostPrivateKey) != "<redacted>" {
+		t.Fatalf("replayed HostID %q, HostPrivateKey %q", got.HostID, got.HostPrivateKey)
+	}
+}
+
+func TestRecord_RedactsSavedPairRecord(t *testing.T) {
+	dev, entry := newTestDevice(t)
+	cert := &Certificate{
+		HostID:         "2CA9E9B4-6C53-4A4F-9F37-4B1B6F3A4C10",
+		HostPrivateKey: []byte("host private key"),
+		RootPrivateKey: []byte("root private key"),
+		EscrowBag:      []byte("escrow bag"),
+	}
+
+	var fixture bytes.Buffer
+	stop := Record(&fixture)
+	err := SavePairRecord(entry, cert)
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	// usbmuxd got the keys, the recording did not.
+	if key, _ := dev.PairRecord["HostPrivateKey"].([]byte); string(key) != "host private key" {
+		t.Fatalf("saved HostPrivateKey = %q", key)
+	}
+	records := recordedPairRecords(t, fixture.Bytes())
+	if len(records) != 1 {
+		t.Fatalf("recorded %d pair records, want 1", len(records))
+	}
+	for _, key := range []string{"HostPrivateKey", "RootPrivateKey", "EscrowBag"} {
+		if v, _ := records[0][key].([]byte); string(v) != "<redacted>" {
+			t.Errorf("recorded %s = %q", key, v)
+		}
+	}
+
+	// Replay expects the save redacted, as it was recorded.
+	stopReplay, err := Replay(bytes.NewReader(fixture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopReplay()
+	if err := SavePairRecord(entry, cert); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordedPairRecords returns the pair records written in fixture.
+func recordedPairRecords(t *testing.T, fixture []byte) []map[string]interface{} {
+	t.Helper()
+
+	writes := make(map[int][]byte)
+	dec := json.NewDecoder(bytes.NewReader(fixture))
+	for dec.More() {
+		var e recordEvent
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Op == recordWrite {
+			writes[e.Conn] = append(writes[e.Conn], e.Data...)
+		}
+	}
+
+	var records []map[string]interface{}
+	for _, buf := range writes {
+		for len(buf) >= 16 {
+			n := binary.LittleEndian.Uint32(buf)
+			if n < 16 || int(n) > len(buf) {
+				break
+			}
+			var msg struct{ PairRecordData []byte }
+			if _, err := plist.Unmarshal(buf[16:n], &msg); err == nil && msg.PairRecordData != nil {
+				var record map[string]interface{}
+				if _, err := plist.Unmarshal(msg.PairRecordData, &record); err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, record)
+			}
+			buf = buf[n:]
+		}
+	}
+
+	return records
+}
+
+// testdata/info.jsonl was recorded with `iosbox --record testdata/info.jsonl
+// info`.
+func TestReplay_Info(t *testing.T) {
+	f, err := os.Open("testdata/info.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stop, err := Replay(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	entry, err := GetDevice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockdown, err := ConnectLockdownWithSession(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lockdown.Close()
+
+	value, err := lockdown.GetValue("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := value.(map[string]interface{})
+	if info["ProductVersion"] != "14.0.1" || info["UniqueDeviceID"] != testUDID {
+		t.Fatalf("GetValue = %v", value)
+	}
+}
@@ -3,6 +3,7 @@ package idevice
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"strings"
 )
 
@@ -14,6 +15,9 @@ type LogMessage struct {
 	Body       string
 }
 
+// SyslogServiceName is the lockdown name of syslog_relay.
+const SyslogServiceName = "com.apple.syslog_relay"
+
 type SyslogService struct {
 	conn   IConn
 	br     *bufio.Reader
@@ -21,16 +25,25 @@ type SyslogService struct {
 }
 
 func NewSyslogService(entry *DeviceEntry) (*SyslogService, error) {
-	conn, err := ConnectToService(entry, "com.apple.syslog_relay")
+	return NewSyslogServiceContext(context.Background(), entry)
+}
+
+// NewSyslogServiceContext is NewSyslogService with a context.
+func NewSyslogServiceContext(ctx context.Context, entry *DeviceEntry) (*SyslogService, error) {
+	conn, err := ConnectToServiceContext(ctx, entry, SyslogServiceName)
 	if err != nil {
 		return &SyslogService{}, err
 	}
 
+	return newSyslogService(conn), nil
+}
+
+func newSyslogService(conn IConn) *SyslogService {
 	return &SyslogService{
 		conn:   conn,
 		br:     bufio.NewReader(conn.Reader()),
 		closed: false,
-	}, nil
+	}
 }
 
 func (s *SyslogService) Close() {
@@ -39,6 +52,14 @@ func (s *SyslogService) Close() {
 }
 
 func (s *SyslogService) GetSyslog() (LogMessage, error) {
+	return s.GetSyslogContext(context.Background())
+}
+
+// GetSyslogContext is GetSyslog with a context. It returns ctx.Err() once ctx
+// is done, even when the service is idle.
+func (s *SyslogService) GetSyslogContext(ctx context.Context) (_ LogMessage, err error) {
+	defer withContext(ctx, s.conn)(&err)
+
 	bs, err := s.br.ReadBytes(0)
 	if err != nil {
 		if s.closed {
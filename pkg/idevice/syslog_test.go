@@ -1,14 +1,20 @@
 package idevice
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/gofmt/iOSBox/pkg/idevice/idevicetest"
 )
 
 func TestSyslogService_GetLog(t *testing.T) {
-	device, err := GetDevice()
-	if err != nil {
-		t.Fatal(err)
-	}
+	dev, device := newTestDevice(t)
+	dev.AddService("com.apple.syslog_relay", idevicetest.NewSyslogRelay(
+		`Jun  3 18:45:44 iPhone wifid(WiFiPolicy)[51] <Notice>: Copy current network requested by "WirelessRadioMan"`,
+		`Jun  3 18:45:45 iPhone SpringBoard(FrontBoard)[62] <Error>: scene \M-b\M^@\M-& failed`,
+	))
 
 	service, err := NewSyslogService(device)
 	if err != nil {
@@ -16,11 +22,36 @@ func TestSyslogService_GetLog(t *testing.T) {
 	}
 	defer service.Close()
 
-	for {
+	want := []LogMessage{
+		{Time: "Jun 3 18:45:44", DeviceName: "iPhone", ProcInfo: "wifid(WiFiPolicy)[51]", Level: "Notice", Body: `Copy current network requested by "WirelessRadioMan"`},
+		{Time: "Jun 3 18:45:45", DeviceName: "iPhone", ProcInfo: "SpringBoard(FrontBoard)[62]", Level: "Error", Body: "scene … failed"},
+	}
+	for _, w := range want {
 		line, err := service.GetSyslog()
 		if err != nil {
 			t.Fatal(err)
 		}
-		t.Log(line)
+		if line != w {
+			t.Fatalf("GetSyslog = %+v, want %+v", line, w)
+		}
+	}
+}
+
+func TestSyslogService_GetSyslogContext(t *testing.T) {
+	dev, device := newTestDevice(t)
+	dev.AddService("com.apple.syslog_relay", idevicetest.NewSyslogRelay())
+
+	service, err := NewSyslogService(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The relay has nothing to send, so only the deadline can end the read.
+	if _, err := service.GetSyslogContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetSyslogContext error = %v, want %v", err, context.DeadlineExceeded)
 	}
 }
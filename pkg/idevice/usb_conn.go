@@ -1,8 +1,13 @@
 package idevice
 
 import (
+	"context"
 	"encoding/binary"
 	"io"
+	"net"
+	"strings"
+
+	"github.com/gofmt/iOSBox/pkg/trace"
 
 	"golang.org/x/xerrors"
 	"howett.net/plist"
@@ -31,6 +36,11 @@ type USBResponse struct {
 	Number      uint32
 }
 
+const (
+	ConnectionTypeUSB     = "USB"
+	ConnectionTypeNetwork = "Network"
+)
+
 type DeviceProperties struct {
 	ConnectionSpeed int
 	ConnectionType  string
@@ -38,6 +48,10 @@ type DeviceProperties struct {
 	LocationID      int
 	ProductID       int
 	SerialNumber    string
+	UDID            string
+	USBSerialNumber string
+	InterfaceIndex  int
+	NetworkAddress  []byte
 }
 
 type DeviceEntry struct {
@@ -46,6 +60,48 @@ type DeviceEntry struct {
 	Properties  DeviceProperties
 }
 
+// IsNetwork reports whether the device is reached over Wi-Fi rather than USB.
+func (e *DeviceEntry) IsNetwork() bool {
+	return e.Properties.ConnectionType == ConnectionTypeNetwork
+}
+
+// IPAddress decodes NetworkAddress, a raw BSD sockaddr, for Wi-Fi devices.
+// It returns nil for USB devices.
+func (p DeviceProperties) IPAddress() net.IP {
+	addr := p.NetworkAddress
+	if len(addr) < 2 {
+		return nil
+	}
+
+	switch addr[1] {
+	case 0x02: // AF_INET
+		if len(addr) >= 8 {
+			return net.IP(addr[4:8])
+		}
+	case 0x0a, 0x1e: // AF_INET6 on Linux and Darwin
+		if len(addr) >= 24 {
+			return net.IP(addr[8:24])
+		}
+	}
+
+	return nil
+}
+
+// ParseConnectionType normalizes a user supplied connection type. "" and
+// "any" match every device; "wifi" is accepted as an alias for "network".
+func ParseConnectionType(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "any", "all":
+		return "", nil
+	case "usb":
+		return ConnectionTypeUSB, nil
+	case "network", "net", "wifi", "wi-fi":
+		return ConnectionTypeNetwork, nil
+	}
+
+	return "", xerrors.Errorf("unknown connection type %q, expected usb, network or any", s)
+}
+
 type ListDevicesMessage struct {
 	MessageType         string
 	ProgName            string
@@ -58,7 +114,16 @@ type USBConn struct {
 }
 
 func NewUSBConn() (*USBConn, error) {
-	conn, err := NewConn()
+	return NewUSBConnContext(context.Background())
+}
+
+// NewUSBConnContext is NewUSBConn with a context that bounds the dial.
+func NewUSBConnContext(ctx context.Context) (*USBConn, error) {
+	return newUSBConn(ctx, SocketAddress)
+}
+
+func newUSBConn(ctx context.Context, addr string) (*USBConn, error) {
+	conn, err := dialConn(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +146,12 @@ type connectMessage struct {
 }
 
 func (u *USBConn) Connect(deviceId int, port uint16) error {
-	if err := u.Send(connectMessage{
+	return u.ConnectContext(context.Background(), deviceId, port)
+}
+
+// ConnectContext is Connect with a context.
+func (u *USBConn) ConnectContext(ctx context.Context, deviceId int, port uint16) error {
+	msg, err := u.request(ctx, connectMessage{
 		BundleID:            Label,
 		ClientVersionString: ClientVersionString,
 		MessageType:         "Connect",
@@ -89,11 +159,7 @@ func (u *USBConn) Connect(deviceId int, port uint16) error {
 		LibUSBMuxVersion:    3,
 		DeviceID:            uint32(deviceId),
 		PortNumber:          port,
-	}); err != nil {
-		return err
-	}
-
-	msg, err := u.Recv()
+	})
 	if err != nil {
 		return err
 	}
@@ -103,13 +169,17 @@ func (u *USBConn) Connect(deviceId int, port uint16) error {
 		return err
 	}
 	if resp.Number != 0 {
-		return xerrors.Errorf("failed connecting to service, error code: %d", resp.Number)
+		return &MuxError{Request: "Connect", Number: resp.Number}
 	}
 
 	return nil
 }
 
 func (u *USBConn) SendWithMessage(msg *USBMessage) error {
+	if trace.Enabled() {
+		trace.Printf("usbmuxd", trace.Send, "tag=%d %s", msg.Header.Tag, trace.FormatPlist(msg.Payload))
+	}
+
 	if err := binary.Write(u.Conn.Writer(), binary.LittleEndian, msg.Header); err != nil {
 		return err
 	}
@@ -120,22 +190,43 @@ func (u *USBConn) SendWithMessage(msg *USBMessage) error {
 func (u *USBConn) Send(msg interface{}) error {
 	u.tag++
 
-	bs, err := plist.Marshal(msg, plist.XMLFormat)
+	bs, err := plist.Marshal(msg, u.Conn.PlistFormat())
 	if err != nil {
 		return err
 	}
 
-	header := USBHeader{
-		Length:  16 + uint32(len(bs)),
-		Version: 1,
-		Request: 8,
-		Tag:     u.tag,
+	m := &USBMessage{
+		Header: USBHeader{
+			Length:  16 + uint32(len(bs)),
+			Version: 1,
+			Request: 8,
+			Tag:     u.tag,
+		},
+		Payload: bs,
+	}
+	if _, ok := msg.(savePairRecordRequest); !ok {
+		return u.SendWithMessage(m)
 	}
-	if err := binary.Write(u.Conn.Writer(), binary.LittleEndian, header); err != nil {
+
+	// The private keys of the pair record stay out of recordings.
+	m = pairRecordWrite(u.Conn, m)
+	if err := u.SendWithMessage(m); err != nil {
 		return err
 	}
+	redactPairRecordWrite(u.Conn, m)
+
+	return nil
+}
+
+// request sends msg and reads the reply. ctx bounds the whole exchange.
+func (u *USBConn) request(ctx context.Context, msg interface{}) (_ *USBMessage, err error) {
+	defer withContext(ctx, u.Conn)(&err)
+
+	if err := u.Send(msg); err != nil {
+		return nil, err
+	}
 
-	return binary.Write(u.Conn.Writer(), binary.LittleEndian, bs)
+	return u.Recv()
 }
 
 func (u *USBConn) Recv() (*USBMessage, error) {
@@ -148,10 +239,15 @@ func (u *USBConn) Recv() (*USBMessage, error) {
 	if _, err := io.ReadFull(u.Conn.Reader(), payload); err != nil {
 		return nil, err
 	}
+	if trace.Enabled() {
+		trace.Printf("usbmuxd", trace.Recv, "tag=%d %s", header.Tag, trace.FormatPlist(payload))
+	}
 
 	return &USBMessage{Header: header, Payload: payload}, nil
 }
 
+// Certificate is a usbmuxd pair record. Keys it doesn't know about are kept
+// so a record survives a read/write round trip unchanged.
 type Certificate struct {
 	HostID            string
 	SystemBUID        string
@@ -162,6 +258,8 @@ type Certificate struct {
 	WiFiMACAddress    string
 	RootCertificate   []byte
 	RootPrivateKey    []byte
+
+	raw map[string]interface{}
 }
 
 type ReadPairRecordRequest struct {
@@ -175,21 +273,24 @@ type ReadPairRecordRequest struct {
 
 type PairRecordData struct {
 	PairRecordData []byte
+	// Number is the usbmuxd result code sent instead of a record.
+	Number uint32
 }
 
 func (u *USBConn) GetCertificate(udid string) (*Certificate, error) {
-	if err := u.Send(ReadPairRecordRequest{
+	return u.GetCertificateContext(context.Background(), udid)
+}
+
+// GetCertificateContext is GetCertificate with a context.
+func (u *USBConn) GetCertificateContext(ctx context.Context, udid string) (*Certificate, error) {
+	msg, err := u.request(ctx, ReadPairRecordRequest{
 		BundleID:            Label,
 		ClientVersionString: ClientVersionString,
 		MessageType:         "ReadPairRecord",
 		ProgName:            ProgName,
 		LibUSBMuxVersion:    3,
 		PairRecordID:        udid,
-	}); err != nil {
-		return nil, err
-	}
-
-	msg, err := u.Recv()
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -198,6 +299,10 @@ func (u *USBConn) GetCertificate(udid string) (*Certificate, error) {
 	if _, err := plist.Unmarshal(msg.Payload, &data); err != nil {
 		return nil, err
 	}
+	if len(data.PairRecordData) == 0 {
+		return nil, xerrors.Errorf("no pair record for %s, pair the device first: %w", udid, &MuxError{Request: "ReadPairRecord", Number: data.Number})
+	}
+	redactPairRecord(u.Conn, msg)
 
 	var cert Certificate
 	if _, err := plist.Unmarshal(data.PairRecordData, &cert); err != nil {
@@ -207,16 +312,146 @@ func (u *USBConn) GetCertificate(udid string) (*Certificate, error) {
 	return &cert, nil
 }
 
-func (u *USBConn) ListDevices() ([]DeviceEntry, error) {
-	if err := u.Send(ListDevicesMessage{
-		MessageType:         "ListDevices",
+type savePairRecordRequest struct {
+	BundleID            string
+	ClientVersionString string
+	MessageType         string
+	ProgName            string
+	LibUSBMuxVersion    uint32 `plist:"kLibUSBMuxVersion"`
+	PairRecordID        string
+	PairRecordData      []byte
+	DeviceID            int
+}
+
+// SavePairRecord stores cert as the pair record of the device with the
+// given udid, so that usbmuxd and every other client can use it.
+func (u *USBConn) SavePairRecord(udid string, deviceId int, cert *Certificate) error {
+	return u.SavePairRecordContext(context.Background(), udid, deviceId, cert)
+}
+
+// SavePairRecordContext is SavePairRecord with a context.
+func (u *USBConn) SavePairRecordContext(ctx context.Context, udid string, deviceId int, cert *Certificate) error {
+	data, err := plist.Marshal(cert, plist.XMLFormat)
+	if err != nil {
+		return err
+	}
+
+	msg, err := u.request(ctx, savePairRecordRequest{
+		BundleID:            Label,
+		ClientVersionString: ClientVersionString,
+		MessageType:         "SavePairRecord",
+		ProgName:            ProgName,
+		LibUSBMuxVersion:    3,
+		PairRecordID:        udid,
+		PairRecordData:      data,
+		DeviceID:            deviceId,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp USBResponse
+	if _, err := plist.Unmarshal(msg.Payload, &resp); err != nil {
+		return err
+	}
+	if resp.Number != 0 {
+		return &MuxError{Request: "SavePairRecord", Number: resp.Number}
+	}
+
+	return nil
+}
+
+type pairRecordRequest struct {
+	BundleID            string
+	ClientVersionString string
+	MessageType         string
+	ProgName            string
+	LibUSBMuxVersion    uint32 `plist:"kLibUSBMuxVersion"`
+	PairRecordID        string
+}
+
+// DeletePairRecord removes the pair record of the device with the given
+// udid. The device itself still trusts the host until it is unpaired.
+func (u *USBConn) DeletePairRecord(udid string) error {
+	return u.DeletePairRecordContext(context.Background(), udid)
+}
+
+// DeletePairRecordContext is DeletePairRecord with a context.
+func (u *USBConn) DeletePairRecordContext(ctx context.Context, udid string) error {
+	msg, err := u.request(ctx, pairRecordRequest{
+		BundleID:            Label,
+		ClientVersionString: ClientVersionString,
+		MessageType:         "DeletePairRecord",
 		ProgName:            ProgName,
+		LibUSBMuxVersion:    3,
+		PairRecordID:        udid,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp USBResponse
+	if _, err := plist.Unmarshal(msg.Payload, &resp); err != nil {
+		return err
+	}
+	if resp.Number != 0 {
+		return &MuxError{Request: "DeletePairRecord", Number: resp.Number}
+	}
+
+	return nil
+}
+
+type readBUIDRequest struct {
+	BundleID            string
+	ClientVersionString string
+	MessageType         string
+	ProgName            string
+	LibUSBMuxVersion    uint32 `plist:"kLibUSBMuxVersion"`
+}
+
+// ReadBUID returns the SystemBUID usbmuxd uses to identify this host.
+func (u *USBConn) ReadBUID() (string, error) {
+	return u.ReadBUIDContext(context.Background())
+}
+
+// ReadBUIDContext is ReadBUID with a context.
+func (u *USBConn) ReadBUIDContext(ctx context.Context) (string, error) {
+	msg, err := u.request(ctx, readBUIDRequest{
+		BundleID:            Label,
 		ClientVersionString: ClientVersionString,
-	}); err != nil {
-		return nil, err
+		MessageType:         "ReadBUID",
+		ProgName:            ProgName,
+		LibUSBMuxVersion:    3,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		BUID   string
+		Number uint32
+	}
+	if _, err := plist.Unmarshal(msg.Payload, &resp); err != nil {
+		return "", err
+	}
+	if resp.BUID == "" {
+		return "", &MuxError{Request: "ReadBUID", Number: resp.Number}
 	}
 
-	msg, err := u.Recv()
+	return resp.BUID, nil
+}
+
+func (u *USBConn) ListDevices() ([]DeviceEntry, error) {
+	return u.ListDevicesContext(context.Background())
+}
+
+// ListDevicesContext is ListDevices with a context.
+func (u *USBConn) ListDevicesContext(ctx context.Context) ([]DeviceEntry, error) {
+	msg, err := u.request(ctx, ListDevicesMessage{
+		MessageType:         "ListDevices",
+		ProgName:            ProgName,
+		ClientVersionString: ClientVersionString,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -232,8 +467,122 @@ func (u *USBConn) ListDevices() ([]DeviceEntry, error) {
 	return deviceList.DeviceList, nil
 }
 
+type listenMessage struct {
+	BundleID            string
+	ClientVersionString string
+	MessageType         string
+	ProgName            string
+	LibUSBMuxVersion    uint32 `plist:"kLibUSBMuxVersion"`
+}
+
+type DeviceEventType string
+
+const (
+	DeviceAttached DeviceEventType = "Attached"
+	DeviceDetached DeviceEventType = "Detached"
+	DevicePaired   DeviceEventType = "Paired"
+)
+
+// DeviceEvent is a device notification sent by usbmuxd after Listen.
+// Properties is only sent with Attached events; Listen fills it in for
+// Detached and Paired events from the matching Attached event.
+type DeviceEvent struct {
+	Type       DeviceEventType
+	DeviceID   int
+	Properties DeviceProperties
+}
+
+type deviceEventMessage struct {
+	MessageType string
+	DeviceID    int
+	Properties  DeviceProperties
+}
+
+// Listen subscribes to usbmuxd device notifications. usbmuxd starts by
+// reporting every attached device. The channel is closed when ctx is done
+// or the connection fails, and the connection can't be reused afterwards.
+func (u *USBConn) Listen(ctx context.Context) (<-chan DeviceEvent, error) {
+	msg, err := u.request(ctx, listenMessage{
+		BundleID:            Label,
+		ClientVersionString: ClientVersionString,
+		MessageType:         "Listen",
+		ProgName:            ProgName,
+		LibUSBMuxVersion:    3,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp USBResponse
+	if _, err := plist.Unmarshal(msg.Payload, &resp); err != nil {
+		return nil, err
+	}
+	if resp.MessageType != "Result" || resp.Number != 0 {
+		return nil, &MuxError{Request: "Listen", Number: resp.Number}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			u.Close()
+		case <-done:
+		}
+	}()
+
+	events := make(chan DeviceEvent)
+	go func() {
+		defer close(events)
+		defer close(done)
+		defer u.Close()
+
+		known := make(map[int]DeviceProperties)
+		for {
+			msg, err := u.Recv()
+			if err != nil {
+				return
+			}
+
+			var em deviceEventMessage
+			if _, err := plist.Unmarshal(msg.Payload, &em); err != nil {
+				continue
+			}
+
+			event := DeviceEvent{
+				Type:       DeviceEventType(em.MessageType),
+				DeviceID:   em.DeviceID,
+				Properties: em.Properties,
+			}
+			switch event.Type {
+			case DeviceAttached:
+				known[em.DeviceID] = em.Properties
+			case DeviceDetached:
+				event.Properties = known[em.DeviceID]
+				delete(known, em.DeviceID)
+			case DevicePaired:
+				event.Properties = known[em.DeviceID]
+			default:
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
 func (u *USBConn) ConnectLockdown(devicdId int) (*LockdownConn, error) {
-	if err := u.Connect(devicdId, 32498); err != nil {
+	return u.ConnectLockdownContext(context.Background(), devicdId)
+}
+
+// ConnectLockdownContext is ConnectLockdown with a context.
+func (u *USBConn) ConnectLockdownContext(ctx context.Context, devicdId int) (*LockdownConn, error) {
+	if err := u.ConnectContext(ctx, devicdId, 32498); err != nil {
 		return nil, err
 	}
 
@@ -248,9 +597,16 @@ var serviceConfigurations = map[string]bool{
 }
 
 func (u *USBConn) ConnectWithStartServiceResponse(deviceId int, response *StartServiceResponse, cert *Certificate) error {
-	if err := u.Connect(deviceId, Ntohs(response.Port)); err != nil {
+	return u.ConnectWithStartServiceResponseContext(context.Background(), deviceId, response, cert)
+}
+
+// ConnectWithStartServiceResponseContext is ConnectWithStartServiceResponse
+// with a context, which also bounds the TLS handshake.
+func (u *USBConn) ConnectWithStartServiceResponseContext(ctx context.Context, deviceId int, response *StartServiceResponse, cert *Certificate) (err error) {
+	if err := u.ConnectContext(ctx, deviceId, Ntohs(response.Port)); err != nil {
 		return err
 	}
+	defer withContext(ctx, u.Conn)(&err)
 
 	if response.EnableServiceSSL {
 		var sslerr error
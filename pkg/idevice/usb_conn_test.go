@@ -2,13 +2,39 @@ package idevice
 
 import (
 	"testing"
+
+	"howett.net/plist"
 )
 
 func TestUSBConn_ListDevices(t *testing.T) {
-	device, err := GetDevice()
+	dev, device := newTestDevice(t)
+
+	conn, err := NewUSBConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	list, err := conn.ListDevices()
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(list) != 1 || list[0].DeviceID != dev.DeviceID {
+		t.Fatalf("ListDevices = %+v", list)
+	}
+
+	cert, err := conn.GetCertificate(device.Properties.SerialNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.HostID != dev.PairRecord["HostID"] {
+		t.Fatalf("HostID = %q, want %q", cert.HostID, dev.PairRecord["HostID"])
+	}
+}
+
+func TestUSBConn_ConnectRefused(t *testing.T) {
+	_, device := newTestDevice(t)
 
 	conn, err := NewUSBConn()
 	if err != nil {
@@ -16,10 +42,39 @@ func TestUSBConn_ListDevices(t *testing.T) {
 	}
 	defer conn.Close()
 
-	cert, err := conn.GetCertificate(device.Properties.SerialNumber)
+	if err := conn.Connect(device.DeviceID, Ntohs(1)); err == nil {
+		t.Fatal("expected connecting to a closed port to fail")
+	}
+}
+
+func TestUSBConn_BinaryPlist(t *testing.T) {
+	_, device := newTestDevice(t)
+
+	conn, err := NewUSBConn()
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer conn.Close()
+	conn.Conn.SetPlistFormat(plist.BinaryFormat)
 
-	t.Logf("%#v\n", cert)
+	list, err := conn.ListDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Properties.SerialNumber != device.Properties.SerialNumber {
+		t.Fatalf("ListDevices = %+v", list)
+	}
+
+	lockdown, err := conn.ConnectLockdown(device.DeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := lockdown.GetValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp["Value"].(map[string]interface{}); !ok {
+		t.Fatalf("GetValues = %#v", resp)
+	}
 }
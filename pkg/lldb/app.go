@@ -31,7 +31,7 @@ func (m *mainWindow) Draw() {
 	m.BoxLayout.Draw()
 }
 
-func Run() error {
+func Run(titleText, quitText string) error {
 	mainBox := views.NewBoxLayout(views.Horizontal)
 
 	l := views.NewText()
@@ -59,8 +59,8 @@ func Run() error {
 	title.SetStyle(tcell.StyleDefault.
 		Background(tcell.ColorGray).
 		Foreground(tcell.ColorWhite))
-	title.SetLeft("lldb 浏览器", tcell.StyleDefault)
-	title.SetRight("CTRL+C 退出", tcell.StyleDefault)
+	title.SetLeft(titleText, tcell.StyleDefault)
+	title.SetRight(quitText, tcell.StyleDefault)
 
 	window.SetOrientation(views.Vertical)
 	window.AddWidget(title, 0)
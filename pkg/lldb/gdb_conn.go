@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gofmt/iOSBox/pkg/trace"
+
 	"golang.org/x/xerrors"
 )
 
@@ -317,6 +319,7 @@ func (g *gdbConn) send(cmd []byte) error {
 
 	attemt := 0
 	for {
+		trace.Packet("gdb", trace.Send, cmd)
 		_, err := g.conn.Write(cmd)
 		if err != nil {
 			return err
@@ -352,6 +355,9 @@ func (g *gdbConn) recv(cmd []byte) (resp []byte, err error) {
 		if err != nil {
 			return nil, err
 		}
+		if trace.Enabled() {
+			trace.Packet("gdb", trace.Recv, append(resp[:len(resp):len(resp)], g.inbuf[:2]...))
+		}
 
 		if !g.ack {
 			break
@@ -392,6 +398,7 @@ func (g *gdbConn) readack() bool {
 	if err != nil {
 		return false
 	}
+	trace.Packet("gdb", trace.Recv, []byte{b})
 
 	return b == '+'
 }
@@ -400,6 +407,7 @@ func (g *gdbConn) sendack(c byte) {
 	if c != '+' && c != '-' {
 		panic(xerrors.Errorf("sendack(%c)", c))
 	}
+	trace.Packet("gdb", trace.Send, []byte{c})
 	_, _ = g.conn.Write([]byte{c})
 }
 
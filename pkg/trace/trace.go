@@ -0,0 +1,176 @@
+// Package trace writes a wire-level log of the device protocols: usbmuxd
+// messages, lockdown plists, AFC packet headers and gdb-remote packets.
+//
+// Tracing is off until Open or SetOutput is called. Each entry is one line
+// with a timestamp, the protocol, the direction and the message:
+//
+//	2021-06-01T10:00:00.000000+08:00 lockdown > {"Key":"DeviceName","Label":"idevice.ios.control","Request":"GetValue"}
+//
+// Fields that hold private keys or pairing secrets are replaced with
+// "<redacted>".
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"howett.net/plist"
+)
+
+// Directions of a traced message.
+const (
+	// Send is a message from the host to the device.
+	Send = ">"
+	// Recv is a message from the device to the host.
+	Recv = "<"
+)
+
+// MaxPacket is how many bytes of a raw packet are written before it is cut
+// short.
+var MaxPacket = 512
+
+// RedactedKeys are the plist keys whose values never make it into the
+// trace.
+var RedactedKeys = map[string]bool{
+	"HostPrivateKey":   true,
+	"RootPrivateKey":   true,
+	"DevicePrivateKey": true,
+	"PairRecordData":   true,
+	"EscrowBag":        true,
+}
+
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+var (
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+)
+
+// Open starts tracing to the file at path, appending to it if it exists.
+func Open(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	closeLocked()
+	out, closer = f, f
+
+	return nil
+}
+
+// SetOutput starts tracing to w, or stops tracing when w is nil.
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	closeLocked()
+	out = w
+}
+
+// Close stops tracing and closes the file opened by Open.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return closeLocked()
+}
+
+func closeLocked() error {
+	var err error
+	if closer != nil {
+		err = closer.Close()
+	}
+	out, closer = nil, nil
+
+	return err
+}
+
+// Enabled reports whether tracing is on. Callers check it before doing any
+// work to build an entry.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return out != nil
+}
+
+// Printf writes one entry for proto in direction dir.
+func Printf(proto, dir, format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if out == nil {
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "%s %s %s %s\n", time.Now().Format(timeFormat), proto, dir, fmt.Sprintf(format, args...))
+}
+
+// Packet writes a raw text packet, quoted and cut to MaxPacket bytes.
+func Packet(proto, dir string, data []byte) {
+	if !Enabled() {
+		return
+	}
+
+	if len(data) > MaxPacket {
+		Printf(proto, dir, "%s... (%d bytes)", strconv.Quote(string(data[:MaxPacket])), len(data))
+		return
+	}
+	Printf(proto, dir, "%s", strconv.Quote(string(data)))
+}
+
+// FormatPlist renders an XML or binary plist as one line of JSON, with
+// RedactedKeys removed and data blobs replaced by their length.
+func FormatPlist(data []byte) string {
+	var v interface{}
+	if _, err := plist.Unmarshal(data, &v); err != nil {
+		return fmt.Sprintf("<invalid plist %d bytes: %v>", len(data), err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(Redact(v)); err != nil {
+		return fmt.Sprintf("<plist %d bytes: %v>", len(data), err)
+	}
+
+	return string(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+// Redact returns a copy of a decoded plist value with the values of
+// RedactedKeys replaced by "<redacted>" and data blobs by "<data N bytes>".
+func Redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if RedactedKeys[key] {
+				m[key] = "<redacted>"
+				continue
+			}
+			m[key] = Redact(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = Redact(value)
+		}
+		return s
+	case []byte:
+		return fmt.Sprintf("<data %d bytes>", len(v))
+	}
+
+	return v
+}